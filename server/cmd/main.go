@@ -2,101 +2,244 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"runtime"
+	"sync"
+	"syscall"
 	"time"
 
 	"github/abdallemo/solveit-saas/internal/ai"
+	"github/abdallemo/solveit-saas/internal/announcement"
 	"github/abdallemo/solveit-saas/internal/api"
+	"github/abdallemo/solveit-saas/internal/api/websocket"
 	"github/abdallemo/solveit-saas/internal/cache"
 	"github/abdallemo/solveit-saas/internal/chat"
+	"github/abdallemo/solveit-saas/internal/comment"
 	"github/abdallemo/solveit-saas/internal/editor"
 	"github/abdallemo/solveit-saas/internal/file"
+	"github/abdallemo/solveit-saas/internal/mailer"
+	"github/abdallemo/solveit-saas/internal/notification"
+	"github/abdallemo/solveit-saas/internal/scan"
+	"github/abdallemo/solveit-saas/internal/storage"
 	"github/abdallemo/solveit-saas/internal/task"
+	"github/abdallemo/solveit-saas/internal/taskfeed"
+	"github/abdallemo/solveit-saas/internal/turn"
+	"github/abdallemo/solveit-saas/internal/upload"
 	"github/abdallemo/solveit-saas/internal/utils"
+	"github/abdallemo/solveit-saas/internal/webpush"
 	"github/abdallemo/solveit-saas/internal/worker"
 	"github/abdallemo/solveit-saas/internal/workspace"
 
 	"github/abdallemo/solveit-saas/internal/database"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sashabaranov/go-openai"
 )
 
+// jobsTimeout bounds how long the background jobs get to finish their
+// current run on shutdown
+const jobsTimeout = time.Minute
+
 func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	// ctx lives as long as the process, it is cancelled on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	startCtx, cancelStart := context.WithTimeout(ctx, 20*time.Second)
+	defer cancelStart()
 	log.Println("Go version:", runtime.Version())
 
 	utils.LoadEnvs()
 
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion("auto"),
-		config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(
-				utils.GetenvWithDefault("S3_ACCESS_KEY_ID", ""),
-				utils.GetenvWithDefault("S3_SECRTE_ACCESS_KEY_ID", ""),
-				"",
-			),
-		),
-	)
+	// STORAGE_BACKEND is s3, local or memory, the last two serve their
+	// presigned urls under STORAGE_PUBLIC_URL/storage/
+	objectStorage, err := storage.New(startCtx, storage.Config{
+		Backend:         utils.GetenvWithDefault("STORAGE_BACKEND", storage.BackendS3),
+		Bucket:          utils.GetenvWithDefault("STORAGE_BUCKET", "solveit"),
+		Prefix:          utils.GetenvWithDefault("STORAGE_PREFIX", ""),
+		Endpoint:        utils.GetenvWithDefault("S3_ENDPOINT", ""),
+		Region:          utils.GetenvWithDefault("S3_REGION", "auto"),
+		AccessKeyID:     utils.GetenvWithDefault("S3_ACCESS_KEY_ID", ""),
+		SecretAccessKey: utils.GetenvWithDefault("S3_SECRTE_ACCESS_KEY_ID", ""),
+		Dir:             utils.GetenvWithDefault("STORAGE_DIR", "./data/storage"),
+		PublicURL:       utils.GetenvWithDefault("STORAGE_PUBLIC_URL", "http://localhost:3030/api/v1"),
+		SigningKey:      utils.GetenvWithDefault("STORAGE_SIGNING_KEY", ""),
+	})
 	if err != nil {
-		log.Fatalf("failed to S3 load config: %v", err)
+		return fmt.Errorf("failed to init storage: %w", err)
 	}
 
 	dbURL := utils.GetenvWithDefault("DATABASE_URL", "")
-	db, err := pgxpool.New(ctx, dbURL)
+	db, err := pgxpool.New(startCtx, dbURL)
 	if err != nil {
-		log.Fatal("unable to connect to database:", err)
+		return fmt.Errorf("unable to connect to database: %w", err)
 	}
 	defer db.Close()
 	store := database.New(db)
 
 	opt, err := redis.ParseURL(utils.GetenvWithDefault("REDIS_URL", ""))
 	if err != nil {
-		log.Fatal("unable to parse redis url")
+		return fmt.Errorf("unable to parse redis url: %w", err)
 	}
 	redisClient := redis.NewClient(opt)
-	_, err = redisClient.Ping(ctx).Result()
+	_, err = redisClient.Ping(startCtx).Result()
 	if err != nil {
-		log.Fatal("unable to connect to redis instance")
+		return fmt.Errorf("unable to connect to redis instance: %w", err)
 	}
 	defer redisClient.Close()
 
-	s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(utils.GetenvWithDefault("S3_ENDPOINT", ""))
-	})
-
 	openaiClient := openai.NewClient(utils.GetenvWithDefault("OPENAI_API_KEY", ""))
 
-	fileService := file.NewService(s3Client)
-	chatService := chat.NewService(store, db, fileService)
+	mailerService, err := mailer.NewService(mailer.Config{
+		Host:     utils.GetenvWithDefault("SMTP_HOST", ""),
+		Port:     utils.GetenvWithDefault("SMTP_PORT", "587"),
+		Username: utils.GetenvWithDefault("SMTP_USERNAME", ""),
+		Password: utils.GetenvWithDefault("SMTP_PASSWORD", ""),
+		From:     utils.GetenvWithDefault("SMTP_FROM", "SolveIt <no-reply@solveit.app>"),
+		TLS:      utils.GetenvWithDefault("SMTP_TLS", "false") == "true",
+		AppURL:   utils.GetenvWithDefault("BETTER_AUTH_URL", "http://localhost:3000"),
+	}, redisClient, store)
+	if err != nil {
+		return fmt.Errorf("failed to init mailer: %w", err)
+	}
+
+	filePolicy, err := file.ParsePolicy(utils.GetenvWithDefault("FILE_TYPE_ALLOWLIST", ""))
+	if err != nil {
+		return fmt.Errorf("invalid FILE_TYPE_ALLOWLIST: %w", err)
+	}
+	fileService := file.NewService(store, objectStorage, filePolicy)
 	taskService := task.NewTaskService(store, fileService)
 	workspaceService := workspace.NewService(store, fileService)
 	cacheService := cache.NewService(redisClient)
 	AIService := ai.NewService(openaiClient, store, cacheService)
 	editorService := editor.NewService(store, fileService)
+	commentService := comment.NewService(store, AIService)
 
-	srvCfg := api.NewConfigs(utils.GetenvWithDefault("GO_PORT", ":3030"))
+	pushService, err := webpush.NewService(store, webpush.Config{
+		PublicKey:  utils.GetenvWithDefault("VAPID_PUBLIC_KEY", ""),
+		PrivateKey: utils.GetenvWithDefault("VAPID_PRIVATE_KEY", ""),
+		Subject:    utils.GetenvWithDefault("VAPID_SUBJECT", "mailto:solveit@org.com"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to init web push: %w", err)
+	}
+
+	// WS_LIMITS is a JSON websocket.LimitConfig overriding the defaults
+	wsLimits, err := websocket.ParseLimits(utils.GetenvWithDefault("WS_LIMITS", ""))
+	if err != nil {
+		return fmt.Errorf("invalid WS_LIMITS: %w", err)
+	}
+	websockets := websocket.NewWebSockets(redisClient, store, commentService, wsLimits)
+	if pushService.Enabled() {
+		websockets.Notif.OnOffline(pushService.NotifyOffline)
+	}
+	notificationService := notification.NewService(store, websockets.Notif, websockets.Presence, mailerService)
+	announcementService := announcement.NewService(store, websockets.Announce)
+	websockets.Announce.OnJoin(announcementService.Active)
+	taskFeedService := taskfeed.NewService(store, redisClient, websockets.TaskFeed)
+	chatService := chat.NewService(store, db, fileService, notificationService)
+	// CLAMD_ADDRESS is unix:/path/to/clamd.sock or tcp://host:port, standin
+	// runs a local stand-in that only knows the EICAR test file
+	clamdClient, err := newClamdClient(utils.GetenvWithDefault("CLAMD_ADDRESS", ""))
+	if err != nil {
+		return fmt.Errorf("invalid CLAMD_ADDRESS: %w", err)
+	}
+	scanService := scan.NewService(store, objectStorage, clamdClient, websockets.Notif)
+	uploadService := upload.NewService(store, objectStorage, redisClient, fileService, taskService, workspaceService, editorService, chatService)
 
-	server := api.NewServer(srvCfg, &api.Services{
-		FileService:      fileService,
-		ChatService:      chatService,
-		TaskService:      taskService,
-		AIService:        AIService,
-		WorkspaceService: workspaceService,
-		EditorService:    editorService,
+	turnTTL, err := time.ParseDuration(utils.GetenvWithDefault("TURN_CREDENTIAL_TTL", "1h"))
+	if err != nil {
+		return fmt.Errorf("invalid TURN_CREDENTIAL_TTL: %w", err)
+	}
+	turnService := turn.NewService(store, turn.Config{
+		Secret:   utils.GetenvWithDefault("TURN_SECRET", ""),
+		TurnURLs: turn.ParseURLs(utils.GetenvWithDefault("TURN_URLS", "")),
+		StunURLs: turn.ParseURLs(utils.GetenvWithDefault("STUN_URLS", "stun:stun.cloudflare.com:3478")),
+		TTL:      turnTTL,
 	})
 
-	worker := worker.NewWorker(database.New(db), s3Client, redisClient, server.WebSockets.Notif, db)
-	go worker.StartDeadlineEnforcerJob(ctx, 50, 10*time.Minute)
-	go worker.StartDraftMediaCleanupJob(ctx, time.Hour)
-	go worker.StartFileGarbageCollectorJob(ctx, time.Hour*24)
+	srvCfg := api.NewConfigs(utils.GetenvWithDefault("GO_PORT", ":3030"))
 
-	log.Fatal(server.Run())
+	server, err := api.NewServer(srvCfg, &api.Services{
+		Storage:             objectStorage,
+		FileService:         fileService,
+		ChatService:         chatService,
+		TaskService:         taskService,
+		AIService:           AIService,
+		WorkspaceService:    workspaceService,
+		EditorService:       editorService,
+		CommentService:      commentService,
+		TurnService:         turnService,
+		NotificationService: notificationService,
+		PushService:         pushService,
+		AnnouncementService: announcementService,
+		TaskFeedService:     taskFeedService,
+		UploadService:       uploadService,
+	}, websockets)
+	if err != nil {
+		return err
+	}
+
+	worker := worker.NewWorker(database.New(db), objectStorage, redisClient, notificationService, taskFeedService, db)
+	var jobs sync.WaitGroup
+	for _, job := range []func(context.Context){
+		func(ctx context.Context) { worker.StartDeadlineEnforcerJob(ctx, 50, 10*time.Minute) },
+		func(ctx context.Context) { worker.StartDraftMediaCleanupJob(ctx, time.Hour) },
+		func(ctx context.Context) { worker.StartFileGarbageCollectorJob(ctx, time.Hour*24) },
+		func(ctx context.Context) { worker.StartUploadCleanupJob(ctx, 15*time.Minute) },
+		func(ctx context.Context) { worker.StartNotificationDigestJob(ctx, 5*time.Minute) },
+		func(ctx context.Context) { mailerService.StartDeliveryJob(ctx, 15*time.Second) },
+		func(ctx context.Context) { scanService.StartScanJob(ctx, 10*time.Second) },
+		func(ctx context.Context) { taskFeedService.StartPublishJob(ctx, 15*time.Second) },
+		func(ctx context.Context) { websockets.Signal.StartRingTimeoutJob(ctx, 10*time.Second) },
+	} {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job(ctx)
+		}()
+	}
+
+	serverErr := server.Run(ctx)
+	// the jobs stop with the server, also when it failed on its own
+	stop()
+
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+		log.Println("background jobs stopped")
+	case <-time.After(jobsTimeout):
+		log.Println("background jobs did not stop in time")
+	}
+	return serverErr
+}
+
+// newClamdClient connects the malware scans, which are disabled without an
+// address
+func newClamdClient(address string) (*scan.Client, error) {
+	switch address {
+	case "":
+		return nil, nil
+	case "standin":
+		standIn, err := scan.ListenStandIn("127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("clamd stand-in listening on %s", standIn.Address())
+		address = standIn.Address()
+	}
+	return scan.NewClient(address)
 }
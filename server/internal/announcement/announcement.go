@@ -0,0 +1,150 @@
+// Package announcement holds the system notices admins broadcast to every
+// user or to a role
+package announcement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/api/websocket"
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxLifetime      = 30 * 24 * time.Hour
+	maxTitleLength   = 200
+	maxContentLength = 5000
+)
+
+var (
+	ErrNotFound            = errors.New("announcement not found")
+	ErrInvalidAnnouncement = errors.New("invalid announcement")
+)
+
+type CreateParams struct {
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Role      string    `json:"role"` // everyone when empty
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type Service struct {
+	store      *database.Queries
+	wsAnnounce *websocket.WsAnnouncements
+}
+
+func NewService(store *database.Queries, wsAnnounce *websocket.WsAnnouncements) *Service {
+	return &Service{store: store, wsAnnounce: wsAnnounce}
+}
+
+func MapAnnouncement(a database.Announcement) websocket.Announcement {
+	msg := websocket.Announcement{
+		MessageType: "announcement",
+		ID:          a.ID.String(),
+		Title:       a.Title,
+		Content:     a.Content,
+		ExpiresAt:   a.ExpiresAt.Format(time.RFC3339Nano),
+		CreatedAt:   a.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if a.Role.Valid {
+		msg.Role = string(a.Role.Role)
+	}
+	return msg
+}
+
+// Create stores the announcement and pushes it to the connected users of
+// its audience, the others get it when they connect before it expires.
+func (s *Service) Create(ctx context.Context, senderID uuid.UUID, params CreateParams) (websocket.Announcement, error) {
+	params.Title = strings.TrimSpace(params.Title)
+	params.Content = strings.TrimSpace(params.Content)
+	if err := validate(params); err != nil {
+		return websocket.Announcement{}, err
+	}
+
+	arg := database.CreateAnnouncementParams{
+		SenderID:  &senderID,
+		Title:     params.Title,
+		Content:   params.Content,
+		ExpiresAt: params.ExpiresAt,
+	}
+	if params.Role != "" {
+		arg.Role = database.NullRole{Role: database.Role(params.Role), Valid: true}
+	}
+	row, err := s.store.CreateAnnouncement(ctx, arg)
+	if err != nil {
+		return websocket.Announcement{}, err
+	}
+
+	msg := MapAnnouncement(row)
+	s.wsAnnounce.Publish(msg)
+	return msg, nil
+}
+
+func validate(params CreateParams) error {
+	if params.Title == "" || len(params.Title) > maxTitleLength {
+		return fmt.Errorf("%w: title must be 1 to %d characters", ErrInvalidAnnouncement, maxTitleLength)
+	}
+	if params.Content == "" || len(params.Content) > maxContentLength {
+		return fmt.Errorf("%w: content must be 1 to %d characters", ErrInvalidAnnouncement, maxContentLength)
+	}
+	switch database.Role(params.Role) {
+	case "", database.RoleADMIN, database.RoleMODERATOR, database.RolePOSTER, database.RoleSOLVER:
+	default:
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidAnnouncement, params.Role)
+	}
+	if until := time.Until(params.ExpiresAt); until <= 0 || until > maxLifetime {
+		return fmt.Errorf("%w: expiresAt must be in the next %d days", ErrInvalidAnnouncement, int(maxLifetime.Hours()/24))
+	}
+	return nil
+}
+
+// Active lists the unexpired announcements for a role, newest first
+func (s *Service) Active(ctx context.Context, role string) ([]websocket.Announcement, error) {
+	rows, err := s.store.ListActiveAnnouncements(ctx, database.NullRole{
+		Role:  database.Role(role),
+		Valid: role != "",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapAnnouncements(rows), nil
+}
+
+// AllActive lists the unexpired announcements of every audience
+func (s *Service) AllActive(ctx context.Context) ([]websocket.Announcement, error) {
+	rows, err := s.store.ListAllActiveAnnouncements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapAnnouncements(rows), nil
+}
+
+// Expire withdraws an active announcement and tells its audience so
+func (s *Service) Expire(ctx context.Context, id uuid.UUID) (websocket.Announcement, error) {
+	row, err := s.store.ExpireAnnouncement(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return websocket.Announcement{}, ErrNotFound
+	}
+	if err != nil {
+		return websocket.Announcement{}, err
+	}
+
+	msg := MapAnnouncement(row)
+	msg.MessageType = "announcement_expired"
+	s.wsAnnounce.Publish(msg)
+	return msg, nil
+}
+
+func mapAnnouncements(rows []database.Announcement) []websocket.Announcement {
+	announcements := make([]websocket.Announcement, len(rows))
+	for i, row := range rows {
+		announcements[i] = MapAnnouncement(row)
+	}
+	return announcements
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/announcement"
+	"github/abdallemo/solveit-saas/internal/middleware"
+
+	"github.com/google/uuid"
+)
+
+// Announcement Resource
+func (s *Server) handleGetAnnouncements(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	announcements, err := s.AnnouncementService.Active(r.Context(), claims.Role)
+	if err != nil {
+		sendAnnouncementError(w, err)
+		return
+	}
+	WriteJSON(w, announcements, http.StatusOK)
+}
+
+// Announcement Resource
+func (s *Server) handleListAllAnnouncements(w http.ResponseWriter, r *http.Request) {
+	announcements, err := s.AnnouncementService.AllActive(r.Context())
+	if err != nil {
+		sendAnnouncementError(w, err)
+		return
+	}
+	WriteJSON(w, announcements, http.StatusOK)
+}
+
+// Announcement Resource
+func (s *Server) handleCreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := announcement.CreateParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		sendHTTPError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.AnnouncementService.Create(r.Context(), userID, params)
+	if err != nil {
+		sendAnnouncementError(w, err)
+		return
+	}
+	WriteJSON(w, created, http.StatusCreated)
+}
+
+// Announcement Resource
+func (s *Server) handleExpireAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("announcementId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid announcement id", http.StatusBadRequest)
+		return
+	}
+
+	expired, err := s.AnnouncementService.Expire(r.Context(), id)
+	if err != nil {
+		sendAnnouncementError(w, err)
+		return
+	}
+	WriteJSON(w, expired, http.StatusOK)
+}
+
+func sendAnnouncementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, announcement.ErrNotFound):
+		sendHTTPError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, announcement.ErrInvalidAnnouncement):
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("announcement request failed: %v", err)
+		sendHTTPError(w, "Failed to process announcement", http.StatusInternalServerError)
+	}
+}
@@ -2,30 +2,54 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github/abdallemo/solveit-saas/internal/ai"
+	"github/abdallemo/solveit-saas/internal/announcement"
 	"github/abdallemo/solveit-saas/internal/api/websocket"
 	"github/abdallemo/solveit-saas/internal/chat"
+	"github/abdallemo/solveit-saas/internal/comment"
+	"github/abdallemo/solveit-saas/internal/database"
 	"github/abdallemo/solveit-saas/internal/editor"
 	"github/abdallemo/solveit-saas/internal/file"
 	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/notification"
+	"github/abdallemo/solveit-saas/internal/storage"
 	"github/abdallemo/solveit-saas/internal/task"
+	"github/abdallemo/solveit-saas/internal/taskfeed"
+	"github/abdallemo/solveit-saas/internal/turn"
+	"github/abdallemo/solveit-saas/internal/upload"
 	"github/abdallemo/solveit-saas/internal/utils"
+	"github/abdallemo/solveit-saas/internal/webpush"
 	"github/abdallemo/solveit-saas/internal/workspace"
 )
 
 type Services struct {
-	FileService      *file.Service
-	ChatService      *chat.Service
-	TaskService      *task.Service
-	AIService        *ai.Service
-	WorkspaceService *workspace.Service
-	EditorService    *editor.Service
+	Storage             storage.Storage
+	FileService         *file.Service
+	ChatService         *chat.Service
+	TaskService         *task.Service
+	AIService           *ai.Service
+	WorkspaceService    *workspace.Service
+	EditorService       *editor.Service
+	CommentService      *comment.Service
+	TurnService         *turn.Service
+	NotificationService *notification.Service
+	PushService         *webpush.Service
+	AnnouncementService *announcement.Service
+	TaskFeedService     *taskfeed.Service
+	UploadService       *upload.Service
 }
 
+// shutdownTimeout bounds how long open requests and websocket connections
+// get to finish once the server is asked to stop
+const shutdownTimeout = 30 * time.Second
+
 type Configs struct {
 	addr string
 }
@@ -42,14 +66,14 @@ type Server struct {
 	WebSockets *websocket.WebSockets
 
 	middleware *middleware.Middleware
+	serviceKey string
 }
 
 func NewServer(
 	configs *Configs,
 	services *Services,
-) *Server {
-	websocket := websocket.NewWebSockets()
-
+	websockets *websocket.WebSockets,
+) (*Server, error) {
 	jwksUrl := utils.GetenvWithDefault("BETTER_AUTH_JWKS_URL",
 		"http://localhost:3000/api/auth/jwks")
 	allowedOrigins := []string{utils.GetenvWithDefault(
@@ -58,14 +82,15 @@ func NewServer(
 
 	md, err := middleware.NewMiddleware(jwksUrl, allowedOrigins)
 	if err != nil {
-		log.Fatalf("failed to init middleware: %v", err)
+		return nil, fmt.Errorf("failed to init middleware: %w", err)
 	}
 	return &Server{
 		configs:    configs,
-		WebSockets: websocket,
+		WebSockets: websockets,
 		middleware: md,
+		serviceKey: utils.GetenvWithDefault("SERVICE_API_KEY", ""),
 		Services:   services,
-	}
+	}, nil
 }
 
 func (s *Server) routes() http.Handler {
@@ -102,40 +127,135 @@ func sendHTTPError(w http.ResponseWriter, message string, statusCode int) {
 }
 
 func (s *Server) registerWebsocketRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /notification", s.WebSockets.Notif.HandleNotification)
-	mux.HandleFunc("GET /comments", s.WebSockets.Comments.HandleComments)
-	mux.HandleFunc("GET /mentorship", s.WebSockets.Chat.HandleMentorChats)
-	mux.HandleFunc("GET /signaling", s.WebSockets.Signal.HandleSignaling)
+	wsAuth := s.middleware.CreateStack(s.middleware.IsAuthorizedWs)
+
+	mux.Handle("GET /notification", wsAuth(http.HandlerFunc(s.WebSockets.Notif.HandleNotification)))
+	mux.Handle("GET /announce", wsAuth(http.HandlerFunc(s.WebSockets.Announce.HandleAnnouncements)))
+	mux.Handle("GET /task-feed", wsAuth(http.HandlerFunc(s.WebSockets.TaskFeed.HandleTaskFeed)))
+	mux.Handle("GET /comments", wsAuth(http.HandlerFunc(s.WebSockets.Comments.HandleComments)))
+	mux.Handle("GET /mentorship", wsAuth(http.HandlerFunc(s.WebSockets.Chat.HandleMentorChats)))
+	mux.Handle("GET /signaling", wsAuth(http.HandlerFunc(s.WebSockets.Signal.HandleSignaling)))
 }
 
 func (s *Server) registerPublicRoutes(mux *http.ServeMux) {
 	s.registerWebsocketRoutes(mux)
 	mux.HandleFunc("GET /healthz", s.healthz)
+
+	// presigned urls of the local and memory backends, the signature
+	// authorizes the request
+	if signed := storage.SignedHandler(s.Storage); signed != nil {
+		mux.Handle("/storage/", signed)
+	}
+	// tus clients discover the server without credentials
+	mux.HandleFunc("OPTIONS /tus", s.handleTusOptions)
+
+	// the web app routes the notifications it stored, whoever raised them
+	serviceOnly := middleware.RequireServiceKey(s.serviceKey)
+	mux.Handle("POST /send-notification", serviceOnly(http.HandlerFunc(s.handleSendNotification)))
 }
 
 func (s *Server) registerSecuredRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("POST /send-notification", s.WebSockets.Notif.HandleSendNotification)
+	mux.HandleFunc("GET /notification/stream", s.WebSockets.Notif.HandleNotificationStream)
+	mux.HandleFunc("GET /presence", s.WebSockets.Presence.HandleGetPresence)
+
+	mux.HandleFunc("GET /notifications", s.handleListNotifications)
+	mux.HandleFunc("GET /notifications/unread-count", s.handleGetUnreadNotificationCount)
+	mux.HandleFunc("GET /notifications/preferences", s.handleGetNotificationPreferences)
+	mux.HandleFunc("PUT /notifications/preferences", s.handleUpdateNotificationPreferences)
+	mux.HandleFunc("PATCH /notifications/{notificationId}/read", s.handleMarkNotificationRead)
+	mux.HandleFunc("POST /notifications/read-all", s.handleMarkAllNotificationsRead)
+	mux.HandleFunc("DELETE /notifications/{notificationId}", s.handleDeleteNotification)
+
+	adminOnly := middleware.RequireRole(string(database.RoleADMIN))
+	mux.HandleFunc("GET /announcements", s.handleGetAnnouncements)
+	mux.Handle("GET /admin/announcements", adminOnly(http.HandlerFunc(s.handleListAllAnnouncements)))
+	mux.Handle("POST /admin/announcements", adminOnly(http.HandlerFunc(s.handleCreateAnnouncement)))
+	mux.Handle("DELETE /admin/announcements/{announcementId}", adminOnly(http.HandlerFunc(s.handleExpireAnnouncement)))
+	mux.Handle("GET /metrics/websocket", adminOnly(http.HandlerFunc(s.WebSockets.Hub.HandleStats)))
+
+	solverOnly := middleware.RequireRole(string(database.RoleSOLVER))
+	mux.Handle("GET /task-feed/subscription", solverOnly(http.HandlerFunc(s.handleGetTaskFeedSubscription)))
+	mux.Handle("PUT /task-feed/subscription", solverOnly(http.HandlerFunc(s.handleUpdateTaskFeedSubscription)))
+	mux.Handle("DELETE /task-feed/subscription", solverOnly(http.HandlerFunc(s.handleDeleteTaskFeedSubscription)))
+	mux.HandleFunc("POST /task-feed/tasks/{taskId}", s.handlePublishTaskToFeed)
+
+	mux.HandleFunc("GET /push/vapid-public-key", s.handleGetVAPIDPublicKey)
+	mux.HandleFunc("POST /push/subscriptions", s.handleCreatePushSubscription)
+	mux.HandleFunc("DELETE /push/subscriptions", s.handleDeletePushSubscription)
 
 	mux.HandleFunc("GET /media/{filePath}", s.handleGetFiles) //done
 
 	mux.HandleFunc("POST /tasks/draft/files", s.handleCreateDraftTaskFiles)             //done
 	mux.HandleFunc("DELETE /tasks/draft/files/{filePath}", s.handleDeleteDraftTaskFile) //yet
 
+	mux.HandleFunc("POST /uploads", s.handleCreateUploadIntent)
+	mux.HandleFunc("POST /uploads/{uploadId}/confirm", s.handleConfirmUpload)
+
+	mux.HandleFunc("POST /tus", s.handleCreateTusUpload)
+	mux.HandleFunc("HEAD /tus/{uploadId}", s.handleHeadTusUpload)
+	mux.HandleFunc("PATCH /tus/{uploadId}", s.handlePatchTusUpload)
+	mux.HandleFunc("DELETE /tus/{uploadId}", s.handleDeleteTusUpload)
+
 	mux.HandleFunc("POST /editor/files", s.handleCreateEditorFiles)
 	mux.HandleFunc("DELETE /editor/files/{filePath}", s.handleDeleteEditorFile)
 
 	mux.HandleFunc("POST /chats", s.handleCreateChat)
+	mux.HandleFunc("GET /chats/unread", s.handleGetUnreadChats)
+	mux.HandleFunc("GET /chats/search", s.handleSearchChats)
+	mux.HandleFunc("GET /chats/{chatId}/context", s.handleGetChatContext)
 	mux.HandleFunc("DELETE /chats/{chatId}/{filePath}", s.handleDeleteChat)
+	mux.HandleFunc("PATCH /chats/{chatId}", s.handleEditChat)
+	mux.HandleFunc("PUT /chats/{chatId}/reactions/{emoji}", s.handleAddChatReaction)
+	mux.HandleFunc("DELETE /chats/{chatId}/reactions/{emoji}", s.handleRemoveChatReaction)
 
 	mux.HandleFunc("POST /workspaces/{workspaceId}/files", s.handleCreateWorkspaceFiles)              //done
 	mux.HandleFunc("DELETE /workspaces/{workspaceId}/files/{filePath}", s.handleDeleteWorkspaceFiles) //yet
 
+	mux.HandleFunc("GET /tasks/{taskId}/comments", s.handleListComments)
+	mux.HandleFunc("PATCH /comments/{commentId}", s.handleUpdateComment)
+	mux.HandleFunc("DELETE /comments/{commentId}", s.handleDeleteComment)
+
+	mux.HandleFunc("GET /mentorship/sessions/{sessionId}/ice-servers", s.handleGetIceServers)
+	mux.HandleFunc("GET /mentorship/sessions/{sessionId}/chats", s.handleGetSessionChats)
+
 	mux.HandleFunc("POST /openai", s.hanleOpenAi)
 }
 
-func (s *Server) Run() error {
-	log.Printf("server running on port:%s", s.configs.addr)
-	return http.ListenAndServe(s.configs.addr, s.routes())
+// Run serves until ctx is done, then stops accepting connections, closes
+// the websockets and drains the requests in flight.
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    s.configs.addr,
+		Handler: s.routes(),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("server running on port:%s", s.configs.addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("server shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// hijacked websockets are not tracked by Shutdown, and event streams
+	// would hold it up until the timeout, so the hub closes both once the
+	// listeners are closed
+	hubClosed := make(chan struct{})
+	srv.RegisterOnShutdown(func() {
+		s.WebSockets.Hub.Shutdown(shutdownCtx)
+		close(hubClosed)
+	})
+	err := srv.Shutdown(shutdownCtx)
+	<-hubClosed
+	return err
 }
 
 func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
@@ -1,10 +1,16 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"github/abdallemo/solveit-saas/internal/api/websocket"
+	"github/abdallemo/solveit-saas/internal/chat"
 	"github/abdallemo/solveit-saas/internal/database"
 	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/utils"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 )
@@ -20,10 +26,9 @@ func (s *Server) handleCreateChat(w http.ResponseWriter, r *http.Request) {
 	files := r.MultipartForm.File["files"]
 	message := r.FormValue("message")
 	sessionIDStr := r.FormValue("sessionId")
-	sentToStr := r.FormValue("sentTo")
 
-	if sessionIDStr == "" || sentToStr == "" {
-		sendHTTPError(w, "sessionId and sentTo are required", http.StatusBadRequest)
+	if sessionIDStr == "" {
+		sendHTTPError(w, "sessionId is required", http.StatusBadRequest)
 		return
 	}
 
@@ -33,13 +38,33 @@ func (s *Server) handleCreateChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sentTo, err := uuid.Parse(sentToStr)
+	var replyTo *uuid.UUID
+	if replyToStr := r.FormValue("replyTo"); replyToStr != "" {
+		id, err := uuid.Parse(replyToStr)
+		if err != nil {
+			sendHTTPError(w, "Invalid replyTo ID", http.StatusBadRequest)
+			return
+		}
+		replyTo = &id
+	}
+
+	// only a participant can send, and only to the other one
+	sentTo, err := s.ChatService.Counterpart(r.Context(), sessionID, userID)
 	if err != nil {
-		sendHTTPError(w, "Invalid sentTo ID", http.StatusBadRequest)
+		sendChatError(w, err)
 		return
 	}
 
-	uploadedFiles, _ := s.FileService.ProcessBatchUpload(files, "mentorship", uuid.New())
+	uploadedFiles, failed := s.FileService.ProcessBatchUpload(files, "mentorship", uuid.New(), userID)
+	if len(failed) > 0 {
+		for _, f := range uploadedFiles {
+			if err := s.FileService.DeleteFile(f.FilePath); err != nil {
+				log.Printf("storage failed to delete %s: %v", f.FilePath, err)
+			}
+		}
+		sendHTTPError(w, failed[0].File.FileName+": "+failed[0].Error, http.StatusBadRequest)
+		return
+	}
 
 	chatWithFiles, err := s.ChatService.CreateChatWithFiles(r.Context(),
 		message,
@@ -47,8 +72,13 @@ func (s *Server) handleCreateChat(w http.ResponseWriter, r *http.Request) {
 		sessionID,
 		userID,
 		sentTo,
+		replyTo,
 		uploadedFiles)
 
+	if errors.Is(err, chat.ErrInvalidReply) {
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Printf("failed to create chat: %v", err)
 		sendHTTPError(w, "Failed to create chat", http.StatusInternalServerError)
@@ -60,6 +90,214 @@ func (s *Server) handleCreateChat(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, chatWithFiles, 201)
 }
 
+// Chat Resource
+func (s *Server) handleEditChat(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, err := uuid.Parse(r.PathValue("chatId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendHTTPError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	edited, err := s.ChatService.EditChat(r.Context(), chatID, userID, req.Message)
+	if err != nil {
+		sendChatError(w, err)
+		return
+	}
+	s.WebSockets.Chat.SendToParticipants(edited, edited)
+
+	WriteJSON(w, edited, http.StatusOK)
+}
+
+// Chat Resource
+func (s *Server) handleAddChatReaction(w http.ResponseWriter, r *http.Request) {
+	s.reactToChat(w, r, true)
+}
+
+// Chat Resource
+func (s *Server) handleRemoveChatReaction(w http.ResponseWriter, r *http.Request) {
+	s.reactToChat(w, r, false)
+}
+
+func (s *Server) reactToChat(w http.ResponseWriter, r *http.Request, add bool) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, err := uuid.Parse(r.PathValue("chatId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	emoji := r.PathValue("emoji")
+
+	reacted, err := s.ChatService.React(r.Context(), chatID, userID, emoji, add)
+	if err != nil {
+		sendChatError(w, err)
+		return
+	}
+	s.WebSockets.Chat.SendToParticipants(reacted, websocket.ReactionEvent{
+		MessageType: "chat_reaction",
+		SessionID:   reacted.SessionID,
+		ChatID:      reacted.ID,
+		UserID:      userID,
+		Emoji:       emoji,
+		Added:       add,
+		Reactions:   reacted.Reactions,
+	})
+
+	WriteJSON(w, reacted, http.StatusOK)
+}
+
+// Chat Resource
+func (s *Server) handleGetSessionChats(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sessionID, err := uuid.Parse(r.PathValue("sessionId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	limit, ok := parseLimit(w, q.Get("limit"))
+	if !ok {
+		return
+	}
+	if q.Get("before") != "" && q.Get("after") != "" {
+		sendHTTPError(w, "Only one of before and after is allowed", http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.ChatService.History(r.Context(), sessionID, userID, q.Get("before"), q.Get("after"), limit)
+	if err != nil {
+		sendChatError(w, err)
+		return
+	}
+	WriteJSON(w, page, http.StatusOK)
+}
+
+// Chat Resource
+func (s *Server) handleGetChatContext(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, err := uuid.Parse(r.PathValue("chatId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	limit, ok := parseLimit(w, r.URL.Query().Get("limit"))
+	if !ok {
+		return
+	}
+
+	chatContext, err := s.ChatService.Context(r.Context(), chatID, userID, limit)
+	if err != nil {
+		sendChatError(w, err)
+		return
+	}
+	WriteJSON(w, chatContext, http.StatusOK)
+}
+
+// Chat Resource
+func (s *Server) handleSearchChats(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	limit, ok := parseLimit(w, q.Get("limit"))
+	if !ok {
+		return
+	}
+	var sessionID *uuid.UUID
+	if v := q.Get("sessionId"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			sendHTTPError(w, "Invalid session ID", http.StatusBadRequest)
+			return
+		}
+		sessionID = &id
+	}
+
+	page, err := s.ChatService.Search(r.Context(), userID, q.Get("q"), sessionID, q.Get("cursor"), limit)
+	if err != nil {
+		sendChatError(w, err)
+		return
+	}
+	WriteJSON(w, page, http.StatusOK)
+}
+
+// parseLimit reads the optional limit query parameter, answering the
+// request itself when it is invalid
+func parseLimit(w http.ResponseWriter, v string) (int, bool) {
+	if v == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil {
+		sendHTTPError(w, "Invalid limit", http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
+
+func sendChatError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, chat.ErrNotFound):
+		sendHTTPError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, chat.ErrForbidden):
+		sendHTTPError(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, chat.ErrInvalidEdit),
+		errors.Is(err, chat.ErrInvalidReaction),
+		errors.Is(err, chat.ErrInvalidReply),
+		errors.Is(err, chat.ErrInvalidSearch),
+		errors.Is(err, utils.ErrInvalidCursor):
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("chat request failed: %v", err)
+		sendHTTPError(w, "Failed to process chat", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleGetUnreadChats(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	counts, err := s.ChatService.GetUnreadCounts(r.Context(), userID)
+	if err != nil {
+		log.Printf("failed to count unread chats: %v", err)
+		sendHTTPError(w, "Failed to count unread chats", http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSON(w, counts, http.StatusOK)
+}
+
 // Chat Resource
 func (s *Server) handleDeleteChat(w http.ResponseWriter, r *http.Request) {
 
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github/abdallemo/solveit-saas/internal/comment"
+	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// Comment Resource
+func (s *Server) handleListComments(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, _ := uuid.Parse(claims.ID)
+
+	taskID, err := uuid.Parse(r.PathValue("taskId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			sendHTTPError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.CommentService.CanView(r.Context(), taskID, userID, claims.Role); err != nil {
+		sendCommentError(w, err)
+		return
+	}
+
+	page, err := s.CommentService.ListComments(r.Context(), taskID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		sendCommentError(w, err)
+		return
+	}
+
+	WriteJSON(w, page, http.StatusOK)
+}
+
+// Comment Resource
+func (s *Server) handleUpdateComment(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := uuid.Parse(r.PathValue("commentId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendHTTPError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.CommentService.UpdateComment(r.Context(), commentID, userID, body.Content)
+	if err != nil {
+		sendCommentError(w, err)
+		return
+	}
+	s.WebSockets.Comments.SendUpdated(updated)
+
+	WriteJSON(w, updated, http.StatusOK)
+}
+
+// Comment Resource
+func (s *Server) handleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetUserClaims(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, _ := uuid.Parse(claims.ID)
+
+	commentID, err := uuid.Parse(r.PathValue("commentId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := s.CommentService.DeleteComment(r.Context(), commentID, userID, claims.Role)
+	if err != nil {
+		sendCommentError(w, err)
+		return
+	}
+	s.WebSockets.Comments.SendDeleted(deleted)
+
+	WriteJSON(w, deleted, http.StatusOK)
+}
+
+func sendCommentError(w http.ResponseWriter, err error) {
+	var rejected *comment.RejectedError
+	switch {
+	case errors.As(err, &rejected):
+		sendHTTPError(w, rejected.Reason, http.StatusUnprocessableEntity)
+	case errors.Is(err, comment.ErrInvalid), errors.Is(err, utils.ErrInvalidCursor):
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, comment.ErrNotFound), errors.Is(err, comment.ErrTaskNotFound):
+		sendHTTPError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, comment.ErrForbidden):
+		sendHTTPError(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("comment request failed: %v", err)
+		sendHTTPError(w, "Failed to process comment", http.StatusInternalServerError)
+	}
+}
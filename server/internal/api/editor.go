@@ -1,25 +1,40 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/editor"
+	"github/abdallemo/solveit-saas/internal/middleware"
 )
 
 // Editor Resoucre
 func (s *Server) handleCreateEditorFiles(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		sendHTTPError(w, "Unable to parse form", http.StatusBadRequest)
 		return
 	}
 	files := r.MultipartForm.File["files"]
 
-	status := http.StatusOK
-	UploadFileRes, err := s.EditorService.CreateEditorFiles(r.Context(), files, "editor-images")
+	UploadFileRes, err := s.EditorService.CreateEditorFiles(r.Context(), userID, files, "editor-images")
+	if errors.Is(err, editor.ErrUploadFailed) {
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		status = http.StatusInternalServerError
+		log.Printf("failed to create editor file: %v", err)
+		sendHTTPError(w, "Failed to upload", http.StatusInternalServerError)
+		return
 	}
 
-	WriteJSON(w, UploadFileRes, status)
+	WriteJSON(w, UploadFileRes, http.StatusOK)
 }
 
 // Editor Resource
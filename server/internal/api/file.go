@@ -1,12 +1,17 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
+
+	"github/abdallemo/solveit-saas/internal/file"
+	"github/abdallemo/solveit-saas/internal/storage"
 )
 
 type DeleteKey struct {
@@ -26,6 +31,12 @@ func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// only files that passed the malware scan leave the store
+	if err := s.FileService.CheckScanned(r.Context(), filePath); err != nil {
+		sendScanError(w, err)
+		return
+	}
+
 	if respType == "presigned" {
 		res, err := s.FileService.GetPresignedURL(r.Context(), filePath)
 		log.Println("requested presigned ur")
@@ -37,7 +48,15 @@ func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fileData, err := s.FileService.GetFile(r.Context(), filePath)
+	fileData, err := s.FileService.GetFile(r.Context(), filePath, parseRange(r.Header.Get("Range")))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrInvalidRange) {
+		http.Error(w, "Invalid range", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
 	if err != nil {
 
 		http.Error(w, fmt.Sprintf("error fetching file: %v", err), http.StatusInternalServerError)
@@ -57,8 +76,53 @@ func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", fileData.ContentType)
 	w.Header().Set("Content-Length", strconv.FormatInt(fileData.ContentLength, 10))
 	w.Header().Set("Content-Disposition", contentDisposition)
+	w.Header().Set("Accept-Ranges", "bytes")
+	if fileData.Range != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d",
+			fileData.Range.Start, fileData.Range.End, fileData.TotalSize))
+		w.WriteHeader(http.StatusPartialContent)
+	}
 
 	if _, err := io.Copy(w, fileData.Body); err != nil {
 		fmt.Printf("Stream error for %s: %v\n", filePath, err)
 	}
 }
+
+func sendScanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		sendHTTPError(w, "File not found", http.StatusNotFound)
+	case errors.Is(err, file.ErrScanPending):
+		w.Header().Set("Retry-After", "10")
+		sendHTTPError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, file.ErrScanFailed):
+		sendHTTPError(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("file scan lookup failed: %v", err)
+		sendHTTPError(w, "Failed to fetch file", http.StatusInternalServerError)
+	}
+}
+
+// parseRange reads a single "bytes=start-end" range, the players seeking in
+// recordings only send those. Anything else gets the whole file.
+func parseRange(header string) *storage.Range {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok || first == "" {
+		return nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return nil
+	}
+	end := int64(-1)
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil {
+			return nil
+		}
+	}
+	return &storage.Range{Start: start, End: end}
+}
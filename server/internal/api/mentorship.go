@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/turn"
+
+	"github.com/google/uuid"
+)
+
+// Mentorship Resource
+func (s *Server) handleGetIceServers(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	creds, err := s.TurnService.IssueCredentials(r.Context(), sessionID, userID)
+	switch {
+	case errors.Is(err, turn.ErrNotConfigured):
+		sendHTTPError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, turn.ErrNotFound):
+		sendHTTPError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, turn.ErrNotParticipant):
+		sendHTTPError(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, turn.ErrInactive):
+		sendHTTPError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("failed to issue turn credentials: %v", err)
+		sendHTTPError(w, "Failed to issue turn credentials", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	WriteJSON(w, creds, http.StatusOK)
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/notification"
+	"github/abdallemo/solveit-saas/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// Notification Resource
+func (s *Server) handleListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			sendHTTPError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	filter := notification.Filter{
+		Subject:    q.Get("subject"),
+		Method:     q.Get("method"),
+		UnreadOnly: q.Get("unread") == "true",
+	}
+
+	page, err := s.NotificationService.ListNotifications(r.Context(), userID, filter, q.Get("cursor"), limit)
+	if err != nil {
+		sendNotificationError(w, err)
+		return
+	}
+
+	WriteJSON(w, page, http.StatusOK)
+}
+
+// Notification Resource
+func (s *Server) handleGetUnreadNotificationCount(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := s.NotificationService.UnreadCount(r.Context(), userID)
+	if err != nil {
+		sendNotificationError(w, err)
+		return
+	}
+
+	WriteJSON(w, struct {
+		UnreadCount int `json:"unreadCount"`
+	}{UnreadCount: count}, http.StatusOK)
+}
+
+// Notification Resource
+func (s *Server) handleMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	notificationID, err := uuid.Parse(r.PathValue("notificationId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := s.NotificationService.MarkRead(r.Context(), userID, notificationID)
+	if err != nil {
+		sendNotificationError(w, err)
+		return
+	}
+
+	WriteJSON(w, msg, http.StatusOK)
+}
+
+// Notification Resource
+func (s *Server) handleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	updated, err := s.NotificationService.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		sendNotificationError(w, err)
+		return
+	}
+
+	WriteJSON(w, struct {
+		Updated int `json:"updated"`
+	}{Updated: updated}, http.StatusOK)
+}
+
+// Notification Resource
+func (s *Server) handleDeleteNotification(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	notificationID, err := uuid.Parse(r.PathValue("notificationId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.NotificationService.DeleteNotification(r.Context(), userID, notificationID); err != nil {
+		sendNotificationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Notification Resource
+// handleSendNotification routes a notification the web app stored by the
+// preferences of its receiver, only its id is read
+func (s *Server) handleSendNotification(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		ID string `json:"id"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendHTTPError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	notificationID, err := uuid.Parse(body.ID)
+	if err != nil {
+		sendHTTPError(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.NotificationService.Dispatch(r.Context(), notificationID); err != nil {
+		sendNotificationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Notification sent"))
+}
+
+// Notification Resource
+func (s *Server) handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefs, err := s.NotificationService.GetPreferences(r.Context(), userID)
+	if err != nil {
+		sendNotificationError(w, err)
+		return
+	}
+
+	WriteJSON(w, prefs, http.StatusOK)
+}
+
+// Notification Resource
+func (s *Server) handleUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	update := notification.PreferencesUpdate{}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		sendHTTPError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := s.NotificationService.UpdatePreferences(r.Context(), userID, update)
+	if err != nil {
+		sendNotificationError(w, err)
+		return
+	}
+
+	WriteJSON(w, prefs, http.StatusOK)
+}
+
+func sendNotificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notification.ErrNotFound):
+		sendHTTPError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, notification.ErrInvalidMethod), errors.Is(err, notification.ErrInvalidPreference),
+		errors.Is(err, utils.ErrInvalidCursor):
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("notification request failed: %v", err)
+		sendHTTPError(w, "Failed to process notification", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/webpush"
+)
+
+// Push Resource
+func (s *Server) handleGetVAPIDPublicKey(w http.ResponseWriter, r *http.Request) {
+	key, err := s.PushService.PublicKey()
+	if err != nil {
+		sendPushError(w, err)
+		return
+	}
+
+	WriteJSON(w, struct {
+		PublicKey string `json:"publicKey"`
+	}{PublicKey: key}, http.StatusOK)
+}
+
+// Push Resource
+func (s *Server) handleCreatePushSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sub := webpush.Subscription{}
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		sendHTTPError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := s.PushService.Subscribe(r.Context(), userID, sub, r.UserAgent())
+	if err != nil {
+		sendPushError(w, err)
+		return
+	}
+
+	WriteJSON(w, struct {
+		ID       string `json:"id"`
+		Endpoint string `json:"endpoint"`
+	}{ID: saved.ID.String(), Endpoint: saved.Endpoint}, http.StatusCreated)
+}
+
+// Push Resource
+func (s *Server) handleDeletePushSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body := struct {
+		Endpoint string `json:"endpoint"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Endpoint == "" {
+		sendHTTPError(w, "endpoint is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.PushService.Unsubscribe(r.Context(), userID, body.Endpoint); err != nil {
+		sendPushError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sendPushError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webpush.ErrNotConfigured):
+		sendHTTPError(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, webpush.ErrInvalidSubscription):
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, webpush.ErrNotFound):
+		sendHTTPError(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("push subscription request failed: %v", err)
+		sendHTTPError(w, "Failed to process push subscription", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/taskfeed"
+
+	"github.com/google/uuid"
+)
+
+// Task Feed Resource
+func (s *Server) handleGetTaskFeedSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sub, err := s.TaskFeedService.GetSubscription(r.Context(), userID)
+	if err != nil {
+		sendTaskFeedError(w, err)
+		return
+	}
+	WriteJSON(w, sub, http.StatusOK)
+}
+
+// Task Feed Resource
+func (s *Server) handleUpdateTaskFeedSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sub := taskfeed.Subscription{}
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		sendHTTPError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := s.TaskFeedService.Subscribe(r.Context(), userID, sub)
+	if err != nil {
+		sendTaskFeedError(w, err)
+		return
+	}
+	WriteJSON(w, saved, http.StatusOK)
+}
+
+// Task Feed Resource
+func (s *Server) handleDeleteTaskFeedSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.TaskFeedService.Unsubscribe(r.Context(), userID); err != nil {
+		sendTaskFeedError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Task Feed Resource
+func (s *Server) handlePublishTaskToFeed(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	taskID, err := uuid.Parse(r.PathValue("taskId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	sent, err := s.TaskFeedService.PublishOpen(r.Context(), taskID, userID)
+	if err != nil {
+		sendTaskFeedError(w, err)
+		return
+	}
+	WriteJSON(w, struct {
+		Recipients int `json:"recipients"`
+	}{Recipients: sent}, http.StatusOK)
+}
+
+func sendTaskFeedError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, taskfeed.ErrNotSubscribed), errors.Is(err, taskfeed.ErrTaskNotOpen):
+		sendHTTPError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, taskfeed.ErrForbidden):
+		sendHTTPError(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, taskfeed.ErrInvalidSubscription):
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("task feed request failed: %v", err)
+		sendHTTPError(w, "Failed to process task feed request", http.StatusInternalServerError)
+	}
+}
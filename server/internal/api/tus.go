@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/file"
+	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/upload"
+
+	"github.com/google/uuid"
+)
+
+// tusPath is where the uploads are created, the clients follow the
+// Location returned for the rest
+const tusPath = "/api/v1/tus"
+
+// Tus Resource
+func (s *Server) handleTusOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", upload.TusVersion)
+	w.Header().Set("Tus-Version", upload.TusVersion)
+	w.Header().Set("Tus-Extension", "creation,expiration,termination")
+	w.Header().Set("Tus-Max-Size", strconv.Itoa(file.MaxFileSize-1))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Tus Resource
+func (s *Server) handleCreateTusUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		sendHTTPError(w, "Upload-Defer-Length is not supported", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		sendHTTPError(w, "Invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if length >= file.MaxFileSize {
+		sendHTTPError(w, "Exceeded server limit (50MB)", http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := upload.IntentParams{
+		Scope:    database.UploadScope(metadata["scope"]),
+		FileName: firstOf(metadata["filename"], metadata["name"]),
+		FileType: firstOf(metadata["filetype"], metadata["type"]),
+		FileSize: length,
+	}
+	if targetID := metadata["targetId"]; targetID != "" {
+		id, err := uuid.Parse(targetID)
+		if err != nil {
+			sendHTTPError(w, "Invalid targetId", http.StatusBadRequest)
+			return
+		}
+		params.TargetID = &id
+	}
+
+	created, err := s.UploadService.CreateTus(r.Context(), userID, params)
+	if err != nil {
+		sendTusError(w, err)
+		return
+	}
+	setTusHeaders(w, created)
+	w.Header().Set("Location", tusPath+"/"+created.ID.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Tus Resource
+func (s *Server) handleHeadTusUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	userID, id, ok := tusRequest(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := s.UploadService.GetTus(r.Context(), userID, id)
+	if err != nil {
+		sendTusError(w, err)
+		return
+	}
+	setTusHeaders(w, current)
+	w.Header().Set("Upload-Length", strconv.FormatInt(current.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// Tus Resource
+func (s *Server) handlePatchTusUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	userID, id, ok := tusRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		sendHTTPError(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		sendHTTPError(w, "Invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+
+	written, err := s.UploadService.WriteTus(r.Context(), userID, id, offset, r.Body)
+	if err != nil {
+		sendTusError(w, err)
+		return
+	}
+	setTusHeaders(w, written)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Tus Resource
+func (s *Server) handleDeleteTusUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	userID, id, ok := tusRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.UploadService.DeleteTus(r.Context(), userID, id); err != nil {
+		sendTusError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkTusVersion answers the requests of the clients speaking another
+// version of the protocol
+func checkTusVersion(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", upload.TusVersion)
+	if r.Header.Get("Tus-Resumable") != upload.TusVersion {
+		w.Header().Set("Tus-Version", upload.TusVersion)
+		sendHTTPError(w, "Unsupported tus version", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func tusRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(r.PathValue("uploadId"))
+	if err != nil {
+		sendHTTPError(w, "Upload not found", http.StatusNotFound)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+func setTusHeaders(w http.ResponseWriter, u upload.TusUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	if !u.Completed {
+		w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseTusMetadata decodes the Upload-Metadata header, comma separated
+// pairs of a key and a base64 value, the value may be left out
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %s", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func sendTusError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, upload.ErrOffsetMismatch):
+		sendHTTPError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, upload.ErrLocked):
+		sendHTTPError(w, err.Error(), http.StatusLocked)
+	case errors.Is(err, upload.ErrTooLarge):
+		sendHTTPError(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, upload.ErrNotFound), errors.Is(err, upload.ErrForbidden),
+		errors.Is(err, upload.ErrExpired), errors.Is(err, upload.ErrInvalidUpload):
+		sendUploadError(w, err)
+	default:
+		log.Printf("tus request failed: %v", err)
+		sendHTTPError(w, "Failed to process upload", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/upload"
+
+	"github.com/google/uuid"
+)
+
+// Upload Resource
+func (s *Server) handleCreateUploadIntent(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := upload.IntentParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		sendHTTPError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	intent, err := s.UploadService.CreateIntent(r.Context(), userID, params)
+	if err != nil {
+		sendUploadError(w, err)
+		return
+	}
+	WriteJSON(w, intent, http.StatusCreated)
+}
+
+// Upload Resource
+func (s *Server) handleConfirmUpload(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		sendHTTPError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	uploadID, err := uuid.Parse(r.PathValue("uploadId"))
+	if err != nil {
+		sendHTTPError(w, "Invalid upload ID", http.StatusBadRequest)
+		return
+	}
+
+	confirmed, err := s.UploadService.Confirm(r.Context(), userID, uploadID)
+	if err != nil {
+		sendUploadError(w, err)
+		return
+	}
+	if msg := confirmed.Chat; msg != nil {
+		s.WebSockets.Chat.SendToUser(msg.SessionID, msg.SentTo, *msg)
+		s.WebSockets.Chat.SendToUser(msg.SessionID, msg.SentBy, *msg)
+	}
+	WriteJSON(w, confirmed, http.StatusCreated)
+}
+
+func sendUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, upload.ErrNotFound):
+		sendHTTPError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, upload.ErrForbidden):
+		sendHTTPError(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, upload.ErrExpired):
+		sendHTTPError(w, err.Error(), http.StatusGone)
+	case errors.Is(err, upload.ErrInvalidUpload), errors.Is(err, upload.ErrNotUploaded):
+		sendHTTPError(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("upload request failed: %v", err)
+		sendHTTPError(w, "Failed to process upload", http.StatusInternalServerError)
+	}
+}
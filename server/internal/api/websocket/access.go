@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/middleware"
+	"github/abdallemo/solveit-saas/internal/task"
+	"github/abdallemo/solveit-saas/internal/user"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errUnauthenticated = errors.New("unauthenticated")
+	errForbidden       = errors.New("access to channel denied")
+	errNotFound        = errors.New("channel resource not found")
+	errInvalidID       = errors.New("invalid id")
+)
+
+// writeAccessError maps a channel authorization error to its http status
+func writeAccessError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUnauthenticated):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, errForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, errNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errInvalidID):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("channel authorization failed: %v", err)
+		http.Error(w, "unable to authorize channel", http.StatusInternalServerError)
+	}
+}
+
+func authenticatedUser(ctx context.Context) (*user.UserClaims, uuid.UUID, error) {
+	claims, err := middleware.GetUserClaims(ctx)
+	if err != nil {
+		return nil, uuid.Nil, errUnauthenticated
+	}
+	userID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, uuid.Nil, errUnauthenticated
+	}
+	return claims, userID, nil
+}
+
+// authorizeUserChannel allows a user to join channels that belong to them only
+func authorizeUserChannel(ctx context.Context, requestedUserID string) (uuid.UUID, error) {
+	_, userID, err := authenticatedUser(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if requestedUserID != "" && requestedUserID != userID.String() {
+		return uuid.Nil, errForbidden
+	}
+	return userID, nil
+}
+
+// authorizeSessionParticipant allows the solver and the student of the
+// booking behind a mentorship session only.
+func authorizeSessionParticipant(ctx context.Context, store *database.Queries, sessionID string) (uuid.UUID, database.GetMentorSessionParticipantsRow, error) {
+	_, userID, err := authenticatedUser(ctx)
+	if err != nil {
+		return uuid.Nil, database.GetMentorSessionParticipantsRow{}, err
+	}
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return uuid.Nil, database.GetMentorSessionParticipantsRow{}, errInvalidID
+	}
+
+	session, err := store.GetMentorSessionParticipants(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, database.GetMentorSessionParticipantsRow{}, errNotFound
+	}
+	if err != nil {
+		return uuid.Nil, database.GetMentorSessionParticipantsRow{}, err
+	}
+
+	if userID != session.SolverID && userID != session.StudentID {
+		return uuid.Nil, database.GetMentorSessionParticipantsRow{}, errForbidden
+	}
+	return userID, session, nil
+}
+
+// sessionCounterpart returns the other participant of the session
+func sessionCounterpart(ctx context.Context, store *database.Queries, sessionID, userID uuid.UUID) (uuid.UUID, error) {
+	session, err := store.GetMentorSessionParticipants(ctx, sessionID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if userID == session.SolverID {
+		return session.StudentID, nil
+	}
+	return session.SolverID, nil
+}
+
+// authorizeTaskViewer allows everyone on public tasks, and only the poster,
+// the assigned solver and staff on private ones.
+func authorizeTaskViewer(ctx context.Context, store *database.Queries, taskID string) (uuid.UUID, error) {
+	claims, userID, err := authenticatedUser(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id, err := uuid.Parse(taskID)
+	if err != nil {
+		return uuid.Nil, errInvalidID
+	}
+
+	access, err := store.GetTaskAccess(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, errNotFound
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !task.CanView(access, userID, claims.Role) {
+		return uuid.Nil, errForbidden
+	}
+	return userID, nil
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/database"
+)
+
+// announceRoles are the audiences of announcements, one for everyone goes
+// out on the channel of each of them
+var announceRoles = []database.Role{
+	database.RoleADMIN,
+	database.RoleMODERATOR,
+	database.RolePOSTER,
+	database.RoleSOLVER,
+}
+
+// Announcement is a system notice sent to every user or to a role
+type Announcement struct {
+	MessageType string `json:"messageType"` // "announcement", or "announcement_expired" once withdrawn
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	Role        string `json:"role,omitempty"` // empty for everyone
+	ExpiresAt   string `json:"expiresAt"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+type WsAnnouncements struct {
+	hub             *WsHub
+	announceChannel chan IncomingMessage
+	limiter         *limiter
+	active          func(ctx context.Context, role string) ([]Announcement, error)
+}
+
+func NewWsAnnouncements(hub *WsHub, limits Limits) *WsAnnouncements {
+	return &WsAnnouncements{
+		hub:             hub,
+		announceChannel: make(chan IncomingMessage, 100),
+		limiter:         newLimiter(hub, "announcements", limits),
+	}
+}
+
+func announceChannel(role string) string {
+	return "announce:" + role
+}
+
+// OnJoin sets where the active announcements every new connection gets
+// come from.
+func (s *WsAnnouncements) OnJoin(active func(ctx context.Context, role string) ([]Announcement, error)) {
+	s.active = active
+}
+
+// HandleAnnouncements joins the announce channel of the user's role. The
+// announcements still active are sent first, one published meanwhile may
+// arrive twice and is told apart by its id.
+func (s *WsAnnouncements) HandleAnnouncements(w http.ResponseWriter, r *http.Request) {
+	claims, _, err := authenticatedUser(r.Context())
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	q.Set("channel", announceChannel(claims.Role))
+	r.URL.RawQuery = q.Encode()
+
+	c := s.hub.handleWS(w, r, s.announceChannel, s.limiter)
+	if c == nil || s.active == nil {
+		return
+	}
+	active, err := s.active(r.Context(), claims.Role)
+	if err != nil {
+		log.Printf("Failed to list active announcements for %s: %v", c.channelID, err)
+		return
+	}
+	for _, a := range active {
+		s.hub.sendToClient(c, a)
+	}
+}
+
+// Publish sends a to the channel of its role, or of every role
+func (s *WsAnnouncements) Publish(a Announcement) {
+	if a.Role != "" {
+		s.hub.sendToChannel(announceChannel(a.Role), a)
+		return
+	}
+	for _, role := range announceRoles {
+		s.hub.sendToChannel(announceChannel(string(role)), a)
+	}
+}
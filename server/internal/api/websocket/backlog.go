@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	backlogSize = 500                // Messages kept per channel for replay
+	backlogTTL  = 24 * time.Hour     // Backlog of an idle channel is dropped after this
+	seqTTL      = 7 * 24 * time.Hour // Sequence counter of an idle channel is dropped after this
+
+	seqKeyPrefix     = "ws:seq:"
+	backlogKeyPrefix = "ws:backlog:"
+)
+
+// publishScript assigns the next sequence of a channel, appends the message to
+// the channel backlog and publishes it. Running it as one script keeps the
+// publish order identical to the sequence order.
+//
+// KEYS: seq counter, backlog stream, pub/sub channel
+// ARGV: message body following the seq field, backlog size, backlog ttl, seq ttl
+var publishScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+local msg = '{"seq":' .. seq .. ARGV[1]
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], seq .. '-0', 'data', msg)
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+redis.call('PUBLISH', KEYS[3], msg)
+return seq
+`)
+
+// publishSequenced stores data in the channel backlog and publishes it with a
+// "seq" field prepended to the JSON object.
+func (h *WsHub) publishSequenced(ctx context.Context, channelID string, data []byte) error {
+	keys := []string{seqKeyPrefix + channelID, backlogKeyPrefix + channelID, redisChannelPrefix + channelID}
+	return publishScript.Run(ctx, h.redis, keys,
+		sequencedBody(data),
+		backlogSize,
+		int(backlogTTL.Seconds()),
+		int(seqTTL.Seconds()),
+	).Err()
+}
+
+// ResyncEvent tells a client resuming after last_seq that some of what it
+// missed is gone, the backlog was trimmed or expired or the sequence started
+// over, so it has to reload the channel. Seq is where it resumes from.
+type ResyncEvent struct {
+	MessageType string `json:"messageType"`
+	Seq         int64  `json:"seq"`
+}
+
+// resume returns the sequence a client resuming after lastSeq continues from
+// and the messages to replay to it first, led by a ResyncEvent when the
+// backlog can not fill the gap.
+func (h *WsHub) resume(ctx context.Context, channelID string, lastSeq int64) (int64, []seqMessage) {
+	current, err := h.redis.Get(ctx, seqKeyPrefix+channelID).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("Replay failed for %s: %v", channelID, err)
+		return lastSeq, nil
+	}
+	if lastSeq > current {
+		// the counter expired and counts from 1 again, or the client sent
+		// a sequence this channel never reached
+		return current, []seqMessage{resyncMessage(current)}
+	}
+
+	backlog, err := h.readBacklog(ctx, channelID, lastSeq)
+	if err != nil {
+		log.Printf("Replay failed for %s: %v", channelID, err)
+		return lastSeq, nil
+	}
+	if lastSeq < current && (len(backlog) == 0 || backlog[0].seq > lastSeq+1) {
+		return lastSeq, append([]seqMessage{resyncMessage(current)}, backlog...)
+	}
+	return lastSeq, backlog
+}
+
+// resyncMessage has no sequence, so it is written whatever the client saw
+func resyncMessage(seq int64) seqMessage {
+	data, _ := json.Marshal(ResyncEvent{MessageType: "resync", Seq: seq})
+	return seqMessage{data: data}
+}
+
+// readBacklog returns the messages of channelID with a sequence above lastSeq.
+func (h *WsHub) readBacklog(ctx context.Context, channelID string, lastSeq int64) ([]seqMessage, error) {
+	entries, err := h.redis.XRangeN(ctx, backlogKeyPrefix+channelID, strconv.FormatInt(lastSeq+1, 10), "+", backlogSize).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backlog of %s: %w", channelID, err)
+	}
+
+	backlog := make([]seqMessage, 0, len(entries))
+	for _, entry := range entries {
+		data, ok := entry.Values["data"].(string)
+		if !ok {
+			continue
+		}
+		backlog = append(backlog, seqMessage{seq: parseSeq([]byte(data)), data: []byte(data)})
+	}
+	return backlog, nil
+}
+
+// sequencedBody returns what follows the seq field once it is prepended to the
+// JSON object in data. Anything other than an object is wrapped in "payload".
+func sequencedBody(data []byte) string {
+	if len(data) > 1 && data[0] == '{' {
+		body := bytes.TrimSpace(data[1:])
+		if len(body) > 0 && body[0] == '}' {
+			return "}"
+		}
+		return "," + string(body)
+	}
+	return `,"payload":` + string(data) + "}"
+}
+
+// parseSeq reads the sequence of a message built by publishScript, or 0 if
+// the message carries none.
+func parseSeq(data []byte) int64 {
+	rest, ok := bytes.CutPrefix(data, []byte(`{"seq":`))
+	if !ok {
+		return 0
+	}
+	end := bytes.IndexAny(rest, ",}")
+	if end < 0 {
+		return 0
+	}
+	seq, err := strconv.ParseInt(string(rest[:end]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seq
+}
@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const sendQueueSize = 256 // Messages queued per connection before it is evicted as a slow consumer
+
+// seqMessage is an encoded hub message together with its channel sequence.
+// A zero seq marks a message that bypassed the backlog.
+type seqMessage struct {
+	seq  int64
+	data []byte
+}
+
+// client is a single connection registered on a hub channel. Messages are
+// queued on send and written by the client's own writer goroutine, so a slow
+// connection never holds up a broadcast.
+type client struct {
+	id        string
+	userID    uuid.UUID
+	conn      *websocket.Conn // nil for event streams
+	channelID string
+	send      chan seqMessage
+	done      chan struct{}
+	exited    chan struct{} // closed once the writer goroutine returned
+	closeOnce sync.Once
+
+	// lastSeq is the sequence of the last message written, used to drop
+	// messages that show up both in the replayed backlog and live.
+	// Only the writer goroutine touches it once started.
+	lastSeq int64
+
+	// rate limiting state, only touched by the read goroutine
+	limiter   *limiter
+	buckets   map[string]*bucket
+	throttled bool // messages are being dropped, the client was told so
+}
+
+func newClient(conn *websocket.Conn, channelID string, userID uuid.UUID) *client {
+	return &client{
+		id:        uuid.NewString(),
+		userID:    userID,
+		conn:      conn,
+		channelID: channelID,
+		send:      make(chan seqMessage, sendQueueSize),
+		buckets:   make(map[string]*bucket),
+		done:      make(chan struct{}),
+		exited:    make(chan struct{}),
+	}
+}
+
+// enqueue hands msg to the writer goroutine without blocking. It reports
+// false when the queue is full.
+func (c *client) enqueue(msg seqMessage) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+	}
+
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// evict tells the peer why it is being disconnected and stops the client.
+func (c *client) evict(code int, reason string) {
+	c.closeOnce.Do(func() {
+		if c.conn != nil {
+			msg := websocket.FormatCloseMessage(code, reason)
+			if err := c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait)); err != nil {
+				log.Printf("Close frame failed for %s: %v", c.channelID, err)
+			}
+		}
+		close(c.done)
+	})
+}
+
+// stop ends the writer goroutine, which closes the connection on its way out.
+func (c *client) stop() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// writePump writes the replayed backlog, then queued messages and pings until
+// the client is stopped or a write fails. heartbeat runs after every ping.
+func (c *client) writePump(backlog []seqMessage, heartbeat func(*client)) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.stop()
+		c.conn.Close()
+		close(c.exited)
+	}()
+
+	for _, msg := range backlog {
+		if err := c.write(msg); err != nil {
+			log.Printf("Write error to %s: %v (Closing connection)", c.channelID, err)
+			return
+		}
+	}
+
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.write(msg); err != nil {
+				log.Printf("Write error to %s: %v (Closing connection)", c.channelID, err)
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				log.Printf("Ping failed for %s: %v (will retry next tick)", c.channelID, err)
+				continue
+			}
+			heartbeat(c)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *client) write(msg seqMessage) error {
+	if msg.seq != 0 && msg.seq <= c.lastSeq {
+		return nil
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.conn.WriteMessage(websocket.TextMessage, msg.data); err != nil {
+		return err
+	}
+	if msg.seq != 0 {
+		c.lastSeq = msg.seq
+	}
+	return nil
+}
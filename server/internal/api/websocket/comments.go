@@ -1,33 +1,37 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
-	"github/abdallemo/solveit-saas/internal/user"
+	"github/abdallemo/solveit-saas/internal/comment"
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/google/uuid"
 )
 
-type Comment struct {
-	ID        string          `json:"id"`
-	Content   string          `json:"content"`
-	CreatedAt string          `json:"createdAt"`
-	UserID    string          `json:"userId"`
-	TaskID    string          `json:"taskId"`
-	Owner     user.PublicUser `json:"owner"`
-}
+const moderationTimeout = 30 * time.Second
 
 type WsComments struct {
 	hub             *WsHub
-	comment         []Comment
+	store           *database.Queries
+	commentService  *comment.Service
 	commentsChannel chan IncomingMessage
+	limiter         *limiter
 }
 
-func NewWsComments(hub *WsHub) *WsComments {
+func NewWsComments(hub *WsHub, store *database.Queries, commentService *comment.Service, limits Limits) *WsComments {
 	s := &WsComments{
 		hub:             hub,
-		comment:         make([]Comment, 0, 1<<10),
+		store:           store,
+		commentService:  commentService,
 		commentsChannel: make(chan IncomingMessage, 100),
+		limiter:         newLimiter(hub, "comments", limits),
 	}
 	go s.listenForMessages()
 	return s
@@ -35,16 +39,54 @@ func NewWsComments(hub *WsHub) *WsComments {
 
 func (s *WsComments) listenForMessages() {
 	for incMsg := range s.commentsChannel {
-
-		comment := Comment{}
-
-		if err := json.Unmarshal(incMsg.Payload, &comment); err != nil {
-			log.Printf("Chat Unmarshal Failed: %v", err)
+		if incMsg.Type != "MESSAGE" {
 			continue
 		}
+		// moderation is slow, one comment must not hold up the others
+		go s.createComment(incMsg)
+	}
+}
 
-		s.sendToTask(comment.TaskID, comment)
+// createComment stores a comment sent over the socket and broadcasts it to the
+// task. The author is the authenticated user and the task is the channel's.
+func (s *WsComments) createComment(incMsg IncomingMessage) {
+	var payload struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(incMsg.Payload, &payload); err != nil {
+		log.Printf("Comment Unmarshal Failed: %v", err)
+		return
+	}
+	taskID, err := uuid.Parse(strings.TrimPrefix(incMsg.ChannelID, "comments:"))
+	if err != nil {
+		return
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), moderationTimeout)
+	defer cancel()
+
+	created, err := s.commentService.CreateComment(ctx, taskID, incMsg.UserID, payload.Content)
+	var rejected *comment.RejectedError
+	switch {
+	case errors.As(err, &rejected):
+		s.hub.reply(incMsg, commentError{MessageType: "comment_rejected", TaskID: taskID.String(), Reason: rejected.Reason})
+		return
+	case errors.Is(err, comment.ErrInvalid):
+		s.hub.reply(incMsg, commentError{MessageType: "comment_rejected", TaskID: taskID.String(), Reason: err.Error()})
+		return
+	case err != nil:
+		log.Printf("failed to create comment on task %s: %v", taskID, err)
+		s.hub.reply(incMsg, commentError{MessageType: "comment_failed", TaskID: taskID.String(), Reason: "failed to save comment"})
+		return
+	}
+
+	s.SendCreated(created)
+}
+
+type commentError struct {
+	MessageType string `json:"messageType"`
+	TaskID      string `json:"taskId"`
+	Reason      string `json:"reason"`
 }
 
 func (s *WsComments) HandleComments(w http.ResponseWriter, r *http.Request) {
@@ -53,14 +95,33 @@ func (s *WsComments) HandleComments(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing task_id", http.StatusBadRequest)
 		return
 	}
+	if _, err := authorizeTaskViewer(r.Context(), s.store, taskID); err != nil {
+		writeAccessError(w, err)
+		return
+	}
 
 	q := r.URL.Query()
 	q.Set("channel", "comments:"+taskID)
 	r.URL.RawQuery = q.Encode()
 
-	s.hub.handleWS(w, r, s.commentsChannel)
+	s.hub.handleWS(w, r, s.commentsChannel, s.limiter)
+}
+
+func (s *WsComments) SendCreated(c comment.Comment) {
+	c.MessageType = "comment_created"
+	s.sendToTask(c.TaskID, c)
+}
+
+func (s *WsComments) SendUpdated(c comment.Comment) {
+	c.MessageType = "comment_updated"
+	s.sendToTask(c.TaskID, c)
+}
+
+func (s *WsComments) SendDeleted(c comment.Comment) {
+	c.MessageType = "comment_deleted"
+	s.sendToTask(c.TaskID, c)
 }
 
-func (s *WsComments) sendToTask(taskID string, comment Comment) {
-	s.hub.sendToChannel("comments:"+taskID, comment)
+func (s *WsComments) sendToTask(taskID string, c comment.Comment) {
+	s.hub.sendToChannel("comments:"+taskID, c)
 }
@@ -2,15 +2,23 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github/abdallemo/solveit-saas/internal/comment"
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -23,11 +31,18 @@ const (
 	pongWait   = 90 * time.Second    // Time allowed to wait for the next pong from client
 	pingPeriod = (pongWait * 9) / 10 // Send pings every 81 (before the read deadline expires)
 	writeWait  = 10 * time.Second    // Time allowed to write a message to the client
+
+	redisChannelPrefix = "ws:" // Prefix for the redis pub/sub channel of every hub channel
 )
 
 type IncomingMessage struct {
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+
+	// set by the hub from the connection the message was read from
+	ChannelID string    `json:"-"`
+	UserID    uuid.UUID `json:"-"`
+	from      *client
 }
 
 var upgrader = websocket.Upgrader{
@@ -42,39 +57,68 @@ var upgrader = websocket.Upgrader{
 
 type WebSockets struct {
 	Hub      *WsHub
+	Presence *Presence
 	Notif    *WsNotification
+	Announce *WsAnnouncements
+	TaskFeed *WsTaskFeed
 	Comments *WsComments
 	Signal   *WsSignalling
 	Chat     *WsMentorChat
 }
 type WsHub struct {
-	conns map[string][]*websocket.Conn
+	conns map[string][]*client
 	mu    sync.RWMutex
-	// messageChan chan any
+
+	// every message goes through redis so that connections held
+	// by other server instances receive it as well
+	redis  *redis.Client
+	pubsub *redis.PubSub
+	subMu  sync.Mutex
+
+	evictions atomic.Int64 // connections dropped for overflowing their send queue
+
+	// live counts the connections that have not left presence yet, so that
+	// Shutdown can wait for them
+	live sync.WaitGroup
+
+	presence      *Presence
+	presenceHooks map[string][]presenceHook
 }
 
-func NewHub() *WsHub {
-	return &WsHub{
-		conns: make(map[string][]*websocket.Conn),
+func NewHub(redisClient *redis.Client) *WsHub {
+	h := &WsHub{
+		conns:         make(map[string][]*client),
+		redis:         redisClient,
+		pubsub:        redisClient.Subscribe(context.Background()),
+		presence:      NewPresence(redisClient),
+		presenceHooks: make(map[string][]presenceHook),
 	}
+	go h.listenForRedisMessages()
+	return h
 }
 
-func NewWebSockets() *WebSockets {
-	hub := NewHub()
+func NewWebSockets(redisClient *redis.Client, store *database.Queries, commentService *comment.Service, limits LimitConfig) *WebSockets {
+	hub := NewHub(redisClient)
 	return &WebSockets{
 		Hub:      hub,
-		Notif:    NewWsNotification(hub),
-		Comments: NewWsComments(hub),
-		Chat:     NewMentorChat(hub),
-		Signal:   NewWsWsSignalling(hub),
+		Presence: hub.presence,
+		Notif:    NewWsNotification(hub, limits.Notifications),
+		Announce: NewWsAnnouncements(hub, limits.Announcements),
+		TaskFeed: NewWsTaskFeed(hub, limits.TaskFeed),
+		Comments: NewWsComments(hub, store, commentService, limits.Comments),
+		Chat:     NewMentorChat(hub, store, limits.Chat),
+		Signal:   NewWsWsSignalling(hub, store, limits.Signaling),
 	}
 }
 
-func (h *WsHub) handleWS(w http.ResponseWriter, r *http.Request, appChan chan IncomingMessage) {
+// handleWS upgrades the request and registers the connection on the
+// channel in its "channel" query. It returns nil when the connection was
+// refused.
+func (h *WsHub) handleWS(w http.ResponseWriter, r *http.Request, appChan chan IncomingMessage, limiter *limiter) *client {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		fmt.Println("failed to upgrade conn:", err)
-		return
+		return nil
 	}
 	conn.SetReadLimit(5 << 20)
 	conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -85,63 +129,75 @@ func (h *WsHub) handleWS(w http.ResponseWriter, r *http.Request, appChan chan In
 	channelID := r.URL.Query().Get("channel")
 	if channelID == "" {
 		conn.Close()
-		return
+		return nil
+	}
+
+	// every websocket route is authenticated, the user is only missing
+	// if the hub is mounted without the middleware
+	_, userID, _ := authenticatedUser(r.Context())
+	c := newClient(conn, channelID, userID)
+	if code, reason := limiter.admit(r.Context(), c); code != 0 {
+		log.Printf("Refusing connection for channel %s: %s", channelID, reason)
+		refuse(conn, code, reason)
+		return nil
 	}
+
 	h.mu.Lock()
-	h.conns[channelID] = append(h.conns[channelID], conn)
+	h.conns[channelID] = append(h.conns[channelID], c)
+	firstConn := len(h.conns[channelID]) == 1
 	h.mu.Unlock()
+	h.live.Add(1)
 
-	go h.cleanUp(conn, channelID, appChan)
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			h.mu.RLock()
-			stillActive := slices.Contains(h.conns[channelID], conn)
-			h.mu.RUnlock()
-			if !stillActive {
-				return
-			}
+	if firstConn {
+		h.subscribe(channelID)
+	}
 
-			conn.SetWriteDeadline(time.Now().Add(writeWait))
+	// a client reconnecting with last_seq gets the messages it missed
+	// replayed before any live message, which queue up in the meantime
+	var backlog []seqMessage
+	if lastSeq, err := strconv.ParseInt(r.URL.Query().Get("last_seq"), 10, 64); err == nil && lastSeq >= 0 {
+		c.lastSeq, backlog = h.resume(r.Context(), channelID, lastSeq)
+	}
 
-			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
-				log.Printf("Ping failed for %s: %v (will retry next tick)", channelID, err)
-				continue //
-			}
-		}
-	}()
+	h.trackJoin(c)
+
+	go c.writePump(backlog, h.trackJoin)
+	go h.cleanUp(c, channelID, appChan)
 
 	log.Println("New connection for channel:", channelID)
+	return c
 }
 
-func (h *WsHub) cleanUp(conn *websocket.Conn, channelID string, appChan chan IncomingMessage) {
-	defer conn.Close()
+func (h *WsHub) cleanUp(c *client, channelID string, appChan chan IncomingMessage) {
+	defer c.conn.Close()
 	for {
 
 		var msg IncomingMessage
-		err := conn.ReadJSON(&msg)
+		err := c.conn.ReadJSON(&msg)
 		if err != nil {
 
 			log.Printf("Read error on channel %s: %v", channelID, err)
-			h.mu.Lock()
-			conns := h.conns[channelID]
-			active := conns[:0]
-			for _, c := range conns {
-				if c != conn {
-					active = append(active, c)
-				}
-			}
-			h.conns[channelID] = active
-			h.mu.Unlock()
-
+			h.removeConn(channelID, c)
+			c.stop()
+			// wait for the writer so a late heartbeat cannot revive presence
+			<-c.exited
+			h.trackLeave(c)
+			c.limiter.release(c)
+			h.live.Done()
 			break
 		}
+		msg.ChannelID = channelID
+		msg.UserID = c.userID
+		msg.from = c
+		if ok, retry := c.limiter.allow(c, msg.Type); !ok {
+			c.limiter.throttle(c, msg, retry)
+			continue
+		}
+		c.throttled = false
 		switch msg.Type {
 		case "PING":
 			continue
-		case "MESSAGE":
+		case "MESSAGE", "TYPING", "READ":
 			select {
 			case appChan <- msg:
 			default:
@@ -154,20 +210,192 @@ func (h *WsHub) cleanUp(conn *websocket.Conn, channelID string, appChan chan Inc
 	}
 }
 
-func (h *WsHub) sendToChannel(channelID string, payload any) {
+// removeConn drops c from the channel and releases the redis
+// subscription once the last local connection is gone.
+func (h *WsHub) removeConn(channelID string, c *client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	conns := h.conns[channelID]
 	active := conns[:0]
 	for _, conn := range conns {
-		conn.SetWriteDeadline(time.Now().Add(writeWait))
-		if err := conn.WriteJSON(payload); err != nil {
-			log.Printf("Write error to %s: %v (Closing connection)", channelID, err)
-			conn.Close()
-			continue
+		if conn != c {
+			active = append(active, conn)
+		}
+	}
+	empty := len(active) == 0
+	if empty {
+		delete(h.conns, channelID)
+	} else {
+		h.conns[channelID] = active
+	}
+	h.mu.Unlock()
+
+	if empty {
+		h.unsubscribe(channelID)
+	}
+}
+
+func (h *WsHub) subscribe(channelID string) {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+
+	// the channel may have been emptied again while waiting for the lock
+	h.mu.RLock()
+	active := len(h.conns[channelID]) > 0
+	h.mu.RUnlock()
+	if !active {
+		return
+	}
+
+	if err := h.pubsub.Subscribe(context.Background(), redisChannelPrefix+channelID); err != nil {
+		log.Printf("Redis subscribe failed for %s: %v", channelID, err)
+	}
+}
+
+func (h *WsHub) unsubscribe(channelID string) {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+
+	// a new connection may have arrived while waiting for the lock
+	h.mu.RLock()
+	active := len(h.conns[channelID]) > 0
+	h.mu.RUnlock()
+	if active {
+		return
+	}
+
+	if err := h.pubsub.Unsubscribe(context.Background(), redisChannelPrefix+channelID); err != nil {
+		log.Printf("Redis unsubscribe failed for %s: %v", channelID, err)
+	}
+}
+
+// listenForRedisMessages delivers every message published on a
+// subscribed channel to the connections held by this instance.
+func (h *WsHub) listenForRedisMessages() {
+	for msg := range h.pubsub.Channel() {
+		channelID := strings.TrimPrefix(msg.Channel, redisChannelPrefix)
+		data := []byte(msg.Payload)
+		h.writeToLocalConns(channelID, seqMessage{seq: parseSeq(data), data: data})
+	}
+}
+
+// sendToChannel publishes payload to every connection subscribed to
+// channelID, on this and every other server instance. Every message gets
+// the next sequence of the channel and is kept in its backlog for replay.
+func (h *WsHub) sendToChannel(channelID string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Marshal error for %s: %v", channelID, err)
+		return
+	}
+
+	if err := h.publishSequenced(context.Background(), channelID, data); err != nil {
+		log.Printf("Redis publish failed for %s: %v (delivering locally only)", channelID, err)
+		h.writeToLocalConns(channelID, seqMessage{data: data})
+	}
+}
+
+// reply sends payload to the connection msg was read from only. It is not
+// sequenced and never replayed.
+func (h *WsHub) reply(msg IncomingMessage, payload any) {
+	if msg.from == nil {
+		return
+	}
+	h.sendToClient(msg.from, payload)
+}
+
+// sendToClient sends payload to c only, like reply.
+func (h *WsHub) sendToClient(c *client, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Marshal error for %s: %v", c.channelID, err)
+		return
+	}
+	if !c.enqueue(seqMessage{data: data}) {
+		log.Printf("Send queue full for %s (dropping reply)", c.channelID)
+	}
+}
+
+// writeToLocalConns queues msg on every local connection of channelID.
+// A connection whose queue is full is evicted rather than waited on; it is
+// unregistered once its read loop notices the closed socket.
+func (h *WsHub) writeToLocalConns(channelID string, msg seqMessage) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, c := range h.conns[channelID] {
+		if !c.enqueue(msg) {
+			log.Printf("Send queue full for %s (evicting slow consumer)", channelID)
+			h.evictions.Add(1)
+			go c.evict(websocket.CloseTryAgainLater, "slow consumer")
+		}
+	}
+}
+
+// Shutdown sends every local connection a going away close and waits, until
+// ctx is done, for them to leave their channels. The hub receives no
+// messages from redis afterwards.
+func (h *WsHub) Shutdown(ctx context.Context) {
+	h.mu.RLock()
+	var clients []*client
+	for _, conns := range h.conns {
+		clients = append(clients, conns...)
+	}
+	h.mu.RUnlock()
+
+	log.Printf("Closing %d websocket connections", len(clients))
+	for _, c := range clients {
+		c.evict(websocket.CloseGoingAway, "server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.live.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Gave up waiting for websocket connections: %v", ctx.Err())
+	}
+
+	if err := h.pubsub.Close(); err != nil {
+		log.Printf("Redis pubsub close failed: %v", err)
+	}
+}
+
+type HubStats struct {
+	Channels      int   `json:"channels"`
+	Connections   int   `json:"connections"`
+	QueueCapacity int   `json:"queueCapacity"`
+	QueuedTotal   int   `json:"queuedTotal"`
+	QueuedMax     int   `json:"queuedMax"`
+	Evictions     int64 `json:"evictions"`
+}
+
+// Stats reports the send queue depth over the local connections.
+func (h *WsHub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := HubStats{
+		Channels:      len(h.conns),
+		QueueCapacity: sendQueueSize,
+		Evictions:     h.evictions.Load(),
+	}
+	for _, conns := range h.conns {
+		for _, c := range conns {
+			depth := len(c.send)
+			stats.Connections++
+			stats.QueuedTotal += depth
+			stats.QueuedMax = max(stats.QueuedMax, depth)
 		}
-		active = append(active, conn)
 	}
-	h.conns[channelID] = active
+	return stats
+}
+
+func (h *WsHub) HandleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Stats()); err != nil {
+		log.Printf("json encode error: %v", err)
+	}
 }
@@ -1,53 +1,234 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github/abdallemo/solveit-saas/internal/chat"
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/google/uuid"
 )
 
 type WsMentorChat struct {
 	hub               *WsHub
+	store             *database.Queries
 	chats             []chat.ChatWithFiles
 	mentorChatChannel chan IncomingMessage
+	limiter           *limiter
 }
 
-func NewMentorChat(hub *WsHub) *WsMentorChat {
+func NewMentorChat(hub *WsHub, store *database.Queries, limits Limits) *WsMentorChat {
 	s := &WsMentorChat{
 		hub:               hub,
+		store:             store,
 		chats:             make([]chat.ChatWithFiles, 0, 1<<10),
 		mentorChatChannel: make(chan IncomingMessage, 100),
+		limiter:           newLimiter(hub, "chat", limits),
 	}
+	hub.onPresence("chat:", s.handlePresence)
 	go s.listenForMessages()
 	return s
 }
 
+// PresenceEvent tells a session participant whether the other one is in the chat
+type PresenceEvent struct {
+	MessageType string     `json:"messageType"`
+	SessionID   string     `json:"sessionId"`
+	UserID      uuid.UUID  `json:"userId"`
+	Online      bool       `json:"online"`
+	LastSeenAt  *time.Time `json:"lastSeenAt"`
+}
+
+// handlePresence forwards a participant joining or leaving the session chat to
+// the counterpart, and tells a joining participant where the counterpart is.
+func (s *WsMentorChat) handlePresence(channelID string, userID uuid.UUID, online bool) {
+	id, ok := sessionOfChannel(channelID)
+	if !ok {
+		return
+	}
+	sessionID := id.String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+
+	counterpart, err := sessionCounterpart(ctx, s.store, id, userID)
+	if err != nil {
+		log.Printf("Presence lookup of session %s failed: %v", sessionID, err)
+		return
+	}
+	counterpartChannel := fmt.Sprintf("chat:%s:%s", sessionID, counterpart)
+
+	event := PresenceEvent{MessageType: "presence", SessionID: sessionID, UserID: userID, Online: online}
+	if !online {
+		now := time.Now().UTC()
+		event.LastSeenAt = &now
+	}
+	s.hub.sendToChannel(counterpartChannel, event)
+
+	if !online {
+		return
+	}
+	counterpartOnline, err := s.hub.presence.online(ctx, channelSubject(counterpartChannel))
+	if err != nil {
+		log.Printf("Presence lookup of %s failed: %v", counterpartChannel, err)
+		return
+	}
+	status := PresenceEvent{MessageType: "presence", SessionID: sessionID, UserID: counterpart, Online: counterpartOnline}
+	if !counterpartOnline {
+		if statuses, err := s.hub.presence.Status(ctx, []uuid.UUID{counterpart}); err == nil {
+			status.LastSeenAt = statuses[0].LastSeenAt
+		}
+	}
+	s.hub.sendToChannel(channelID, status)
+}
+
+// TypingEvent tells a participant that the other one started or stopped typing
+type TypingEvent struct {
+	MessageType string    `json:"messageType"`
+	SessionID   string    `json:"sessionId"`
+	UserID      uuid.UUID `json:"userId"`
+	Typing      bool      `json:"typing"`
+}
+
+// ReadEvent tells both participants which messages the reader has seen
+type ReadEvent struct {
+	MessageType string      `json:"messageType"`
+	SessionID   string      `json:"sessionId"`
+	ReaderID    uuid.UUID   `json:"readerId"`
+	UpToID      uuid.UUID   `json:"upToId"`
+	ChatIDs     []uuid.UUID `json:"chatIds"`
+	ReadAt      *time.Time  `json:"readAt"`
+}
+
+// listenForMessages handles what the participants send over the socket.
+// Messages are not relayed, POST /chats stores them and pushes them to both.
 func (s *WsMentorChat) listenForMessages() {
 	for incMsg := range s.mentorChatChannel {
-
-		var chat chat.ChatWithFiles
-		if err := json.Unmarshal(incMsg.Payload, &chat); err != nil {
-			log.Printf("Chat Unmarshal Failed: %v", err)
-			continue
+		switch incMsg.Type {
+		case "TYPING":
+			s.handleTyping(incMsg)
+		case "READ":
+			s.handleRead(incMsg)
 		}
-		s.SendToUser(chat.SessionID, chat.SentTo, chat)
 	}
 }
 
+func (s *WsMentorChat) handleTyping(incMsg IncomingMessage) {
+	var payload struct {
+		Typing bool `json:"typing"`
+	}
+	if err := json.Unmarshal(incMsg.Payload, &payload); err != nil {
+		log.Printf("Typing Unmarshal Failed: %v", err)
+		return
+	}
+	sessionID, ok := sessionOfChannel(incMsg.ChannelID)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	counterpart, err := sessionCounterpart(ctx, s.store, sessionID, incMsg.UserID)
+	if err != nil {
+		log.Printf("Typing lookup of session %s failed: %v", sessionID, err)
+		return
+	}
+
+	s.sendEvent(sessionID.String(), counterpart.String(), TypingEvent{
+		MessageType: "chat_typing",
+		SessionID:   sessionID.String(),
+		UserID:      incMsg.UserID,
+		Typing:      payload.Typing,
+	})
+}
+
+// handleRead marks every message sent to the reader up to upToId as read
+func (s *WsMentorChat) handleRead(incMsg IncomingMessage) {
+	var payload struct {
+		UpToID uuid.UUID `json:"upToId"`
+	}
+	if err := json.Unmarshal(incMsg.Payload, &payload); err != nil {
+		log.Printf("Read Unmarshal Failed: %v", err)
+		return
+	}
+	sessionID, ok := sessionOfChannel(incMsg.ChannelID)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	counterpart, err := sessionCounterpart(ctx, s.store, sessionID, incMsg.UserID)
+	if err != nil {
+		log.Printf("Read lookup of session %s failed: %v", sessionID, err)
+		return
+	}
+
+	rows, err := s.store.MarkChatsReadUpTo(ctx, database.MarkChatsReadUpToParams{
+		SessionID: sessionID,
+		ReaderID:  incMsg.UserID,
+		UpToID:    payload.UpToID,
+	})
+	if err != nil {
+		log.Printf("Failed to mark chats read in session %s: %v", sessionID, err)
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	event := ReadEvent{
+		MessageType: "chat_read",
+		SessionID:   sessionID.String(),
+		ReaderID:    incMsg.UserID,
+		UpToID:      payload.UpToID,
+		ChatIDs:     make([]uuid.UUID, 0, len(rows)),
+		ReadAt:      rows[0].ReadAt,
+	}
+	for _, row := range rows {
+		event.ChatIDs = append(event.ChatIDs, row.ID)
+	}
+	// the reader's other tabs need it as much as the counterpart
+	s.sendEvent(sessionID.String(), counterpart.String(), event)
+	s.sendEvent(sessionID.String(), incMsg.UserID.String(), event)
+}
+
+// sessionOfChannel extracts the session of a "chat:<session>:<user>" channel
+func sessionOfChannel(channelID string) (uuid.UUID, bool) {
+	sessionID, _, _ := strings.Cut(strings.TrimPrefix(channelID, "chat:"), ":")
+	id, err := uuid.Parse(sessionID)
+	return id, err == nil
+}
+
 func (s *WsMentorChat) HandleMentorChats(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.URL.Query().Get("session_id")
 	if sessionID == "" {
 		http.Error(w, "Missing session_id", http.StatusBadRequest)
 		return
 	}
+
+	// session_id is "<session>:<user>", every participant listens on its own channel
+	mentorSessionID, channelUserID, _ := strings.Cut(sessionID, ":")
+	userID, _, err := authorizeSessionParticipant(r.Context(), s.store, mentorSessionID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	if channelUserID != userID.String() {
+		writeAccessError(w, errForbidden)
+		return
+	}
 	q := r.URL.Query()
 	q.Set("channel", "chat:"+sessionID)
 	r.URL.RawQuery = q.Encode()
 
-	s.hub.handleWS(w, r, s.mentorChatChannel)
+	s.hub.handleWS(w, r, s.mentorChatChannel, s.limiter)
 }
 
 func (s *WsMentorChat) SendToUser(sessionID, sentTo string, msg chat.ChatWithFiles) {
@@ -57,3 +238,27 @@ func (s *WsMentorChat) SendToUser(sessionID, sentTo string, msg chat.ChatWithFil
 func (s *WsMentorChat) SendDeleteToUser(sessionID, sentTo string, msg any) {
 	s.hub.sendToChannel(fmt.Sprintf("chat:%s:%s", sessionID, sentTo), msg)
 }
+
+// ReactionEvent tells both participants that a reaction was added to or
+// removed from a message, with the reactions the message now has
+type ReactionEvent struct {
+	MessageType string              `json:"messageType"`
+	SessionID   string              `json:"sessionId"`
+	ChatID      string              `json:"chatId"`
+	UserID      uuid.UUID           `json:"userId"`
+	Emoji       string              `json:"emoji"`
+	Added       bool                `json:"added"`
+	Reactions   []chat.ChatReaction `json:"reactions"`
+}
+
+// SendToParticipants pushes an event about msg to the sender and the
+// recipient of it
+func (s *WsMentorChat) SendToParticipants(msg chat.ChatWithFiles, event any) {
+	s.sendEvent(msg.SessionID, msg.SentBy, event)
+	s.sendEvent(msg.SessionID, msg.SentTo, event)
+}
+
+// sendEvent pushes a chat event to one participant of the session
+func (s *WsMentorChat) sendEvent(sessionID, userID string, event any) {
+	s.hub.sendToChannel(fmt.Sprintf("chat:%s:%s", sessionID, userID), event)
+}
@@ -1,7 +1,8 @@
 package websocket
 
 import (
-	"encoding/json"
+	"context"
+	"log"
 	"net/http"
 )
 
@@ -13,6 +14,7 @@ type Message struct {
 	Subject    string `json:"subject"`
 	Method     string `json:"method"`
 	Read       bool   `json:"read"`
+	EventType  string `json:"eventType,omitempty"`
 	CreatedAt  string `json:"createdAt"`
 }
 
@@ -20,54 +22,76 @@ type WsNotification struct {
 	hub                 *WsHub
 	messages            []Message
 	notificationChannel chan IncomingMessage
+	limiter             *limiter
+	offlineHooks        []func(userID string, msg Message)
 }
 
-func NewWsNotification(hub *WsHub) *WsNotification {
+func NewWsNotification(hub *WsHub, limits Limits) *WsNotification {
 	return &WsNotification{
 		hub:                 hub,
 		messages:            make([]Message, 0, 1<<10),
 		notificationChannel: make(chan IncomingMessage, 100),
+		limiter:             newLimiter(hub, "notifications", limits),
 	}
 }
 
 func (s *WsNotification) HandleNotification(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		http.Error(w, "Missing user_id", http.StatusBadRequest)
+	userID, err := authorizeUserChannel(r.Context(), r.URL.Query().Get("user_id"))
+	if err != nil {
+		writeAccessError(w, err)
 		return
 	}
 
 	q := r.URL.Query()
-	q.Set("channel", "notif:"+userID)
+	q.Set("channel", "notif:"+userID.String())
 	r.URL.RawQuery = q.Encode()
 
-	s.hub.handleWS(w, r, s.notificationChannel)
+	s.hub.handleWS(w, r, s.notificationChannel, s.limiter)
 }
 
-func (s *WsNotification) HandleSendNotification(w http.ResponseWriter, r *http.Request) {
-	msg := Message{}
-	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+// HandleNotificationStream serves the notifications of the authenticated
+// user as server-sent events, with the same payloads as HandleNotification.
+func (s *WsNotification) HandleNotificationStream(w http.ResponseWriter, r *http.Request) {
+	_, userID, err := authenticatedUser(r.Context())
+	if err != nil {
+		writeAccessError(w, err)
 		return
 	}
 
-	notification := Message{
-		ID:         msg.ID,
-		Content:    msg.Content,
-		ReceiverID: msg.ReceiverID,
-		SenderID:   msg.SenderID,
-		Subject:    msg.Subject,
-		Method:     msg.Method,
-		Read:       msg.Read,
-		CreatedAt:  msg.CreatedAt,
+	s.hub.handleSSE(w, r, "notif:"+userID.String(), s.limiter)
+}
+
+func (s *WsNotification) SendToUser(userID string, msg Message) {
+	s.hub.sendToChannel("notif:"+userID, msg)
+	if len(s.offlineHooks) > 0 {
+		go s.notifyOffline(userID, msg)
 	}
+}
+
+// OnOffline registers hook for the notifications of users that have no
+// notification connection open on any instance.
+func (s *WsNotification) OnOffline(hook func(userID string, msg Message)) {
+	s.offlineHooks = append(s.offlineHooks, hook)
+}
 
-	s.SendToUser(msg.ReceiverID, notification)
+func (s *WsNotification) notifyOffline(userID string, msg Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Notification sent"))
+	online, err := s.hub.presence.online(ctx, channelSubject("notif:"+userID))
+	if err != nil {
+		log.Printf("Presence lookup for notif:%s failed: %v", userID, err)
+		return
+	}
+	if online {
+		return
+	}
+	for _, hook := range s.offlineHooks {
+		hook(userID, msg)
+	}
 }
 
-func (s *WsNotification) SendToUser(userID string, msg Message) {
-	s.hub.sendToChannel("notif:"+userID, msg)
+// SendEvent pushes an inbox event other than a new notification
+func (s *WsNotification) SendEvent(userID string, event any) {
+	s.hub.sendToChannel("notif:"+userID, event)
 }
@@ -0,0 +1,266 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	presenceTTL       = pongWait + writeWait // A connection counts as online this long after its last heartbeat
+	presenceKeyPrefix = "presence:"
+	lastSeenKey       = "presence:last_seen"
+	maxPresenceQuery  = 100 // User ids accepted by a single presence lookup
+)
+
+// Presence of a subject is a sorted set of its connection ids scored by the
+// time their heartbeat expires, shared by every server instance. Several tabs
+// keep a subject online until the last of them leaves, and connections of a
+// crashed instance simply expire.
+
+// joinScript registers or refreshes a connection and reports whether the
+// subject had no live connection before.
+//
+// KEYS: subject set
+// ARGV: connection id, expiry, now, key ttl
+var joinScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+local live = redis.call('ZCARD', KEYS[1])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+if live == 0 then return 1 end
+return 0
+`)
+
+// leaveScript drops a connection and reports whether it was the subject's
+// last live connection.
+//
+// KEYS: subject set
+// ARGV: connection id, now
+var leaveScript = redis.NewScript(`
+local removed = redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[2])
+if removed == 1 and redis.call('ZCARD', KEYS[1]) == 0 then return 1 end
+return 0
+`)
+
+type PresenceStatus struct {
+	UserID     uuid.UUID  `json:"userId"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"lastSeenAt"`
+}
+
+// presenceHook is told when the user behind a channel comes online on it or
+// leaves it.
+type presenceHook func(channelID string, userID uuid.UUID, online bool)
+
+type Presence struct {
+	redis *redis.Client
+}
+
+func NewPresence(redisClient *redis.Client) *Presence {
+	return &Presence{redis: redisClient}
+}
+
+func userSubject(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+func channelSubject(channelID string) string {
+	return "channel:" + channelID
+}
+
+// join registers connID on subject, it is also used for heartbeats.
+func (p *Presence) join(ctx context.Context, subject, connID string) (bool, error) {
+	now := time.Now()
+	joined, err := joinScript.Run(ctx, p.redis, []string{presenceKeyPrefix + subject},
+		connID,
+		now.Add(presenceTTL).Unix(),
+		now.Unix(),
+		int(presenceTTL.Seconds()),
+	).Int()
+	return joined == 1, err
+}
+
+func (p *Presence) leave(ctx context.Context, subject, connID string) (bool, error) {
+	left, err := leaveScript.Run(ctx, p.redis, []string{presenceKeyPrefix + subject},
+		connID,
+		time.Now().Unix(),
+	).Int()
+	return left == 1, err
+}
+
+func (p *Presence) online(ctx context.Context, subject string) (bool, error) {
+	live, err := p.redis.ZCount(ctx, presenceKeyPrefix+subject, strconv.FormatInt(time.Now().Unix(), 10), "+inf").Result()
+	return live > 0, err
+}
+
+// onlineAll is online for many subjects at once
+func (p *Presence) onlineAll(ctx context.Context, subjects []string) ([]bool, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	counts := make([]*redis.IntCmd, len(subjects))
+	pipe := p.redis.Pipeline()
+	for i, subject := range subjects {
+		counts[i] = pipe.ZCount(ctx, presenceKeyPrefix+subject, now, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	online := make([]bool, len(subjects))
+	for i, count := range counts {
+		online[i] = count.Val() > 0
+	}
+	return online, nil
+}
+
+func (p *Presence) touch(ctx context.Context, userID uuid.UUID) error {
+	return p.redis.HSet(ctx, lastSeenKey, userID.String(), time.Now().Unix()).Err()
+}
+
+// Status returns the online state and last activity of every user.
+func (p *Presence) Status(ctx context.Context, userIDs []uuid.UUID) ([]PresenceStatus, error) {
+	if len(userIDs) == 0 {
+		return []PresenceStatus{}, nil
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	fields := make([]string, len(userIDs))
+	counts := make([]*redis.IntCmd, len(userIDs))
+	pipe := p.redis.Pipeline()
+	for i, userID := range userIDs {
+		fields[i] = userID.String()
+		counts[i] = pipe.ZCount(ctx, presenceKeyPrefix+userSubject(userID), now, "+inf")
+	}
+	lastSeen := pipe.HMGet(ctx, lastSeenKey, fields...)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	seen := lastSeen.Val()
+	statuses := make([]PresenceStatus, len(userIDs))
+	for i, userID := range userIDs {
+		statuses[i] = PresenceStatus{UserID: userID, Online: counts[i].Val() > 0}
+		if i < len(seen) {
+			if v, ok := seen[i].(string); ok {
+				if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+					t := time.Unix(ts, 0).UTC()
+					statuses[i].LastSeenAt = &t
+				}
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// HandleGetPresence answers GET /presence?user_ids=<id>,<id>
+func (p *Presence) HandleGetPresence(w http.ResponseWriter, r *http.Request) {
+	if _, _, err := authenticatedUser(r.Context()); err != nil {
+		writeAccessError(w, err)
+		return
+	}
+
+	var userIDs []uuid.UUID
+	for id := range strings.SplitSeq(r.URL.Query().Get("user_ids"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		userID, err := uuid.Parse(id)
+		if err != nil {
+			http.Error(w, "Invalid user id: "+id, http.StatusBadRequest)
+			return
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if len(userIDs) > maxPresenceQuery {
+		http.Error(w, "Too many user ids", http.StatusBadRequest)
+		return
+	}
+
+	statuses, err := p.Status(r.Context(), userIDs)
+	if err != nil {
+		log.Printf("Presence lookup failed: %v", err)
+		http.Error(w, "Failed to load presence", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+		log.Printf("json encode error: %v", err)
+	}
+}
+
+// onPresence registers hook for channels starting with prefix.
+func (h *WsHub) onPresence(prefix string, hook presenceHook) {
+	h.presenceHooks[prefix] = append(h.presenceHooks[prefix], hook)
+}
+
+func (h *WsHub) notifyPresence(channelID string, userID uuid.UUID, online bool) {
+	for prefix, hooks := range h.presenceHooks {
+		if !strings.HasPrefix(channelID, prefix) {
+			continue
+		}
+		for _, hook := range hooks {
+			hook(channelID, userID, online)
+		}
+	}
+}
+
+// trackJoin marks the user of c online, both globally and on its channel.
+// It doubles as the heartbeat of the connection.
+func (h *WsHub) trackJoin(c *client) {
+	if c.userID == uuid.Nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+
+	if _, err := h.presence.join(ctx, userSubject(c.userID), c.id); err != nil {
+		log.Printf("Presence join failed for %s: %v", c.channelID, err)
+	}
+	if err := h.presence.touch(ctx, c.userID); err != nil {
+		log.Printf("Presence touch failed for %s: %v", c.channelID, err)
+	}
+	if c.limiter != nil {
+		c.limiter.heartbeat(ctx, c)
+	}
+	joined, err := h.presence.join(ctx, channelSubject(c.channelID), c.id)
+	if err != nil {
+		log.Printf("Presence join failed for %s: %v", c.channelID, err)
+		return
+	}
+	if joined {
+		h.notifyPresence(c.channelID, c.userID, true)
+	}
+}
+
+func (h *WsHub) trackLeave(c *client) {
+	if c.userID == uuid.Nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+
+	if _, err := h.presence.leave(ctx, userSubject(c.userID), c.id); err != nil {
+		log.Printf("Presence leave failed for %s: %v", c.channelID, err)
+	}
+	if err := h.presence.touch(ctx, c.userID); err != nil {
+		log.Printf("Presence touch failed for %s: %v", c.channelID, err)
+	}
+	left, err := h.presence.leave(ctx, channelSubject(c.channelID), c.id)
+	if err != nil {
+		log.Printf("Presence leave failed for %s: %v", c.channelID, err)
+		return
+	}
+	if left {
+		h.notifyPresence(c.channelID, c.userID, false)
+	}
+}
@@ -0,0 +1,374 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Close codes of the connections refused or dropped for breaking the limits
+// of a feature
+const (
+	CloseTooManyConnections = 4001
+	CloseBanned             = 4003
+)
+
+const banKeyPrefix = "ws:ban:" // Marks a user banned from a feature until the key expires
+
+// admitScript registers a connection unless the subject already holds max
+// live ones, with the same layout as the presence sets.
+//
+// KEYS: subject set
+// ARGV: connection id, expiry, now, key ttl, max
+var admitScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+if tonumber(ARGV[5]) > 0 and redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[5]) then return 0 end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+// Rate is a token bucket holding up to Burst messages, refilled with
+// PerSecond of them. A Burst below one refuses the message type.
+type Rate struct {
+	PerSecond float64 `json:"perSecond"`
+	Burst     int     `json:"burst"`
+}
+
+// Limits of a hub feature. Message types missing from a rate map fall back
+// to its "*" entry, and are not limited without one.
+type Limits struct {
+	Conn     map[string]Rate `json:"conn"`     // per connection
+	User     map[string]Rate `json:"user"`     // per user, over their connections to one instance
+	MaxConns int             `json:"maxConns"` // concurrent connections of a user, 0 for no limit
+
+	// a user who has Strikes messages dropped within StrikeWindowSeconds is
+	// banned from the feature for BanSeconds
+	Strikes             int `json:"strikes"`
+	StrikeWindowSeconds int `json:"strikeWindowSeconds"`
+	BanSeconds          int `json:"banSeconds"`
+}
+
+// LimitConfig holds the limits of every hub feature
+type LimitConfig struct {
+	Notifications Limits `json:"notifications"`
+	Announcements Limits `json:"announcements"`
+	TaskFeed      Limits `json:"taskFeed"`
+	Comments      Limits `json:"comments"`
+	Chat          Limits `json:"chat"`
+	Signaling     Limits `json:"signaling"`
+}
+
+func DefaultLimits() LimitConfig {
+	strikes := func(l Limits) Limits {
+		l.Strikes, l.StrikeWindowSeconds, l.BanSeconds = 30, 60, 300
+		return l
+	}
+	return LimitConfig{
+		Notifications: strikes(Limits{
+			Conn:     map[string]Rate{"*": {PerSecond: 1, Burst: 5}},
+			MaxConns: 10,
+		}),
+		Announcements: strikes(Limits{
+			Conn:     map[string]Rate{"*": {PerSecond: 1, Burst: 5}},
+			MaxConns: 10,
+		}),
+		TaskFeed: strikes(Limits{
+			Conn:     map[string]Rate{"*": {PerSecond: 1, Burst: 5}},
+			MaxConns: 10,
+		}),
+		Comments: strikes(Limits{
+			Conn: map[string]Rate{
+				"MESSAGE": {PerSecond: 0.5, Burst: 3},
+				"*":       {PerSecond: 2, Burst: 10},
+			},
+			User:     map[string]Rate{"MESSAGE": {PerSecond: 1, Burst: 5}},
+			MaxConns: 10,
+		}),
+		Chat: strikes(Limits{
+			Conn: map[string]Rate{
+				"MESSAGE": {PerSecond: 2, Burst: 10},
+				"TYPING":  {PerSecond: 1, Burst: 5},
+				"READ":    {PerSecond: 2, Burst: 10},
+				"*":       {PerSecond: 2, Burst: 10},
+			},
+			User:     map[string]Rate{"MESSAGE": {PerSecond: 4, Burst: 20}},
+			MaxConns: 10,
+		}),
+		// offers, answers and the ice candidates of a call come in bursts
+		Signaling: strikes(Limits{
+			Conn: map[string]Rate{
+				"MESSAGE": {PerSecond: 20, Burst: 60},
+				"*":       {PerSecond: 2, Burst: 10},
+			},
+			User:     map[string]Rate{"MESSAGE": {PerSecond: 40, Burst: 120}},
+			MaxConns: 5,
+		}),
+	}
+}
+
+// ParseLimits overrides the defaults with the JSON of a LimitConfig, the
+// features, fields and message types it leaves out keep their default.
+func ParseLimits(raw string) (LimitConfig, error) {
+	limits := DefaultLimits()
+	if raw == "" {
+		return limits, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &limits); err != nil {
+		return LimitConfig{}, err
+	}
+	return limits, nil
+}
+
+func (l Limits) rate(rates map[string]Rate, msgType string) (Rate, bool) {
+	if rate, ok := rates[msgType]; ok {
+		return rate, true
+	}
+	rate, ok := rates["*"]
+	return rate, ok
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take spends a token if there is one, or else tells how long until there is
+func (b *bucket) take(rate Rate, now time.Time) (bool, time.Duration) {
+	if b.last.IsZero() {
+		b.tokens = float64(rate.Burst)
+	} else {
+		b.tokens = min(float64(rate.Burst), b.tokens+now.Sub(b.last).Seconds()*rate.PerSecond)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if rate.Burst < 1 || rate.PerSecond <= 0 {
+		return false, 0
+	}
+	return false, time.Duration((1 - b.tokens) / rate.PerSecond * float64(time.Second))
+}
+
+// userLimits is the state of a user with connections to this instance
+type userLimits struct {
+	conns   int
+	buckets map[string]*bucket
+	strikes []time.Time
+}
+
+// limiter enforces the Limits of one hub feature. Connection counts and
+// bans are shared by every instance through redis, rates are counted per
+// instance.
+type limiter struct {
+	hub     *WsHub
+	feature string
+	limits  Limits
+
+	mu    sync.Mutex
+	users map[uuid.UUID]*userLimits
+}
+
+func newLimiter(hub *WsHub, feature string, limits Limits) *limiter {
+	return &limiter{
+		hub:     hub,
+		feature: feature,
+		limits:  limits,
+		users:   make(map[uuid.UUID]*userLimits),
+	}
+}
+
+func (l *limiter) subject(userID uuid.UUID) string {
+	return "conns:" + l.feature + ":" + userID.String()
+}
+
+func (l *limiter) banKey(userID uuid.UUID) string {
+	return banKeyPrefix + l.feature + ":" + userID.String()
+}
+
+// admit registers c on the feature. When the user is banned or holds too
+// many connections it returns the close code and reason to refuse c with.
+func (l *limiter) admit(ctx context.Context, c *client) (int, string) {
+	c.limiter = l
+	if c.userID == uuid.Nil {
+		return 0, ""
+	}
+
+	banned, err := l.hub.redis.PTTL(ctx, l.banKey(c.userID)).Result()
+	if err != nil {
+		log.Printf("Ban lookup failed for %s: %v", c.channelID, err)
+	} else if banned > 0 {
+		return CloseBanned, fmt.Sprintf("banned for %s", banned.Round(time.Second))
+	}
+
+	now := time.Now()
+	admitted, err := admitScript.Run(ctx, l.hub.redis, []string{presenceKeyPrefix + l.subject(c.userID)},
+		c.id,
+		now.Add(presenceTTL).Unix(),
+		now.Unix(),
+		int(presenceTTL.Seconds()),
+		l.limits.MaxConns,
+	).Int()
+	if err != nil {
+		// an outage of redis must not lock everybody out
+		log.Printf("Connection count failed for %s: %v", c.channelID, err)
+	} else if admitted == 0 {
+		return CloseTooManyConnections, fmt.Sprintf("at most %d connections", l.limits.MaxConns)
+	}
+
+	l.mu.Lock()
+	user, ok := l.users[c.userID]
+	if !ok {
+		user = &userLimits{buckets: make(map[string]*bucket)}
+		l.users[c.userID] = user
+	}
+	user.conns++
+	l.mu.Unlock()
+	return 0, ""
+}
+
+// heartbeat keeps c counted among the connections of its user
+func (l *limiter) heartbeat(ctx context.Context, c *client) {
+	if _, err := l.hub.presence.join(ctx, l.subject(c.userID), c.id); err != nil {
+		log.Printf("Connection count refresh failed for %s: %v", c.channelID, err)
+	}
+}
+
+// release undoes admit once c is gone
+func (l *limiter) release(c *client) {
+	if c.userID == uuid.Nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	if _, err := l.hub.presence.leave(ctx, l.subject(c.userID), c.id); err != nil {
+		log.Printf("Connection count release failed for %s: %v", c.channelID, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if user, ok := l.users[c.userID]; ok {
+		user.conns--
+		if user.conns <= 0 {
+			delete(l.users, c.userID)
+		}
+	}
+}
+
+// allow spends a token of msgType from the buckets of c and of its user.
+// It only runs on the read goroutine of c.
+func (l *limiter) allow(c *client, msgType string) (bool, time.Duration) {
+	now := time.Now()
+	if rate, ok := l.limits.rate(l.limits.Conn, msgType); ok {
+		b, ok := c.buckets[msgType]
+		if !ok {
+			b = &bucket{}
+			c.buckets[msgType] = b
+		}
+		if allowed, retry := b.take(rate, now); !allowed {
+			return false, retry
+		}
+	}
+
+	rate, ok := l.limits.rate(l.limits.User, msgType)
+	if !ok || c.userID == uuid.Nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	user, ok := l.users[c.userID]
+	if !ok {
+		return true, 0
+	}
+	b, ok := user.buckets[msgType]
+	if !ok {
+		b = &bucket{}
+		user.buckets[msgType] = b
+	}
+	return b.take(rate, now)
+}
+
+// throttle drops msg for going over the limits. The client is told once
+// per run of dropped messages, and banned when it keeps going.
+func (l *limiter) throttle(c *client, msg IncomingMessage, retry time.Duration) {
+	if !c.throttled {
+		c.throttled = true
+		l.hub.reply(msg, struct {
+			MessageType  string `json:"messageType"`
+			Type         string `json:"type"`
+			RetryAfterMs int64  `json:"retryAfterMs"`
+		}{"rate_limited", msg.Type, retry.Milliseconds()})
+	}
+
+	if c.userID == uuid.Nil || l.limits.Strikes <= 0 {
+		return
+	}
+	now := time.Now()
+	window := now.Add(-time.Duration(l.limits.StrikeWindowSeconds) * time.Second)
+
+	l.mu.Lock()
+	user, ok := l.users[c.userID]
+	if !ok {
+		l.mu.Unlock()
+		return
+	}
+	strikes := user.strikes[:0]
+	for _, at := range user.strikes {
+		if at.After(window) {
+			strikes = append(strikes, at)
+		}
+	}
+	user.strikes = append(strikes, now)
+	banned := len(user.strikes) >= l.limits.Strikes
+	if banned {
+		user.strikes = nil
+	}
+	l.mu.Unlock()
+
+	if banned {
+		l.ban(c.userID)
+	}
+}
+
+// ban keeps the user off the feature for BanSeconds on every instance and
+// closes their connections to this one.
+func (l *limiter) ban(userID uuid.UUID) {
+	duration := time.Duration(l.limits.BanSeconds) * time.Second
+	log.Printf("Banning user %s from %s for %s", userID, l.feature, duration)
+	if duration > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+		defer cancel()
+		if err := l.hub.redis.Set(ctx, l.banKey(userID), 1, duration).Err(); err != nil {
+			log.Printf("Ban failed for user %s: %v", userID, err)
+		}
+	}
+
+	reason := fmt.Sprintf("banned for %s", duration)
+	l.hub.mu.RLock()
+	defer l.hub.mu.RUnlock()
+	for _, conns := range l.hub.conns {
+		for _, c := range conns {
+			if c.limiter == l && c.userID == userID {
+				go c.evict(CloseBanned, reason)
+			}
+		}
+	}
+}
+
+// refuse closes a connection admit turned down
+func refuse(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait)); err != nil {
+		log.Printf("Close frame failed: %v", err)
+	}
+	conn.Close()
+}
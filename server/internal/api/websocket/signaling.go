@@ -1,11 +1,24 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+// RingTimeout is how long a call rings before it is missed. It is checked
+// against the database, so any instance can expire a ring.
+const RingTimeout = 45 * time.Second
+
 type SignalMessage struct {
 	From           string          `json:"from"`
 	To             string          `json:"to"`
@@ -17,16 +30,21 @@ type SignalMessage struct {
 
 type WsSignalling struct {
 	hub               *WsHub
+	store             *database.Queries
 	signal            []SignalMessage
 	signallingChannel chan IncomingMessage
+	limiter           *limiter
 }
 
-func NewWsWsSignalling(hub *WsHub) *WsSignalling {
+func NewWsWsSignalling(hub *WsHub, store *database.Queries, limits Limits) *WsSignalling {
 	s := &WsSignalling{
 		hub:               hub,
+		store:             store,
 		signal:            make([]SignalMessage, 0, 1<<10),
 		signallingChannel: make(chan IncomingMessage, 100),
+		limiter:           newLimiter(hub, "signaling", limits),
 	}
+	hub.onPresence("signaling:", s.handlePresence)
 	go s.listenForMessages()
 	return s
 }
@@ -36,13 +54,229 @@ func (s *WsSignalling) listenForMessages() {
 
 		msg := SignalMessage{}
 
+		if incMsg.Type != "MESSAGE" {
+			continue
+		}
 		if err := json.Unmarshal(incMsg.Payload, &msg); err != nil {
 			log.Printf("Chat Unmarshal Failed: %v", err)
 			continue
 		}
 
-		s.sendToSignalSession(msg.SessionID, msg)
+		s.handleSignal(incMsg, msg)
+	}
+}
+
+// handleSignal drives the call of the session and relays negotiation
+// messages to the other participant only.
+//
+// A call goes ring -> accept | reject | timeout, and an accepted one ends
+// with end (or the camera connection leaving). Offers, answers and ice
+// candidates are only relayed while a call is ringing or active.
+func (s *WsSignalling) handleSignal(incMsg IncomingMessage, msg SignalMessage) {
+	sessionID, userID, ok := signalChannelOwner(incMsg.ChannelID)
+	if !ok {
+		return
+	}
+	if msg.From != userID.String() {
+		s.replyError(incMsg, sessionID, "from does not match the authenticated user")
+		return
+	}
+	msg.SessionID = sessionID.String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+
+	peer, err := sessionCounterpart(ctx, s.store, sessionID, userID)
+	if err != nil {
+		log.Printf("Signal lookup of session %s failed: %v", sessionID, err)
+		return
+	}
+	if msg.To != "" && msg.To != "broadcast" && msg.To != peer.String() {
+		s.replyError(incMsg, sessionID, "peer is not part of this session")
+		return
+	}
+	msg.To = peer.String()
+
+	// rings past their timeout are missed before anything looks at the call
+	s.missRingingCalls(ctx, &sessionID)
+
+	switch msg.Type {
+	case "ring":
+		s.ring(ctx, incMsg, msg, peer)
+	case "accept":
+		call, err := s.store.AcceptCall(ctx, database.AcceptCallParams{SessionID: sessionID, CalleeID: userID})
+		s.sendCallEvent(incMsg, "accept", call, err)
+	case "reject":
+		call, err := s.store.RejectCall(ctx, database.RejectCallParams{SessionID: sessionID, CalleeID: userID})
+		s.sendCallEvent(incMsg, "reject", call, err)
+	case "end":
+		call, err := s.store.EndCall(ctx, database.EndCallParams{SessionID: sessionID, UserID: userID})
+		s.sendCallEvent(incMsg, "end", call, err)
+	case "leave":
+		s.sendToPeer(sessionID, peer, msg)
+
+		// the camera connection leaving is how the client hangs up
+		if msg.ConnectionType != "screen" {
+			if call, err := s.store.EndCall(ctx, database.EndCallParams{SessionID: sessionID, UserID: userID}); err == nil {
+				s.broadcastCall("end", call)
+			}
+		}
+	default:
+		if _, err := s.store.GetLiveCall(ctx, sessionID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				s.replyError(incMsg, sessionID, "no call in progress")
+				return
+			}
+			log.Printf("Signal lookup of session %s failed: %v", sessionID, err)
+			return
+		}
+		s.sendToPeer(sessionID, peer, msg)
+	}
+}
+
+// ring starts a new call to peer. Ringing back a peer who is ringing
+// answers their call. Any other call still live in the session is ended
+// first, the participant ringing again means it is gone for them.
+func (s *WsSignalling) ring(ctx context.Context, incMsg IncomingMessage, msg SignalMessage, peer uuid.UUID) {
+	sessionID, _ := uuid.Parse(msg.SessionID)
+
+	if call, err := s.store.AcceptCall(ctx, database.AcceptCallParams{SessionID: sessionID, CalleeID: incMsg.UserID}); err == nil {
+		s.broadcastCall("accept", call)
+		return
+	}
+
+	if previous, err := s.store.EndCall(ctx, database.EndCallParams{SessionID: sessionID, UserID: incMsg.UserID}); err == nil {
+		s.broadcastCall("end", previous)
+	}
+
+	call, err := s.store.CreateCall(ctx, database.CreateCallParams{
+		SessionID: sessionID,
+		CallerID:  incMsg.UserID,
+		CalleeID:  peer,
+	})
+	if err != nil {
+		log.Printf("Failed to create call in session %s: %v", sessionID, err)
+		s.replyError(incMsg, sessionID, "unable to start call")
+		return
+	}
+	s.broadcastCall("ring", call)
+}
+
+// StartRingTimeoutJob misses the calls that rang out in sessions nobody
+// signals in anymore, the others are missed as soon as a message comes in
+func (s *WsSignalling) StartRingTimeoutJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	log.Println("Starting Background Job for unanswered calls")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Unanswered calls job shutting down...")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeWait)
+			s.missRingingCalls(ctx, nil)
+			cancel()
+		}
+	}
+}
+
+// missRingingCalls misses the calls ringing for longer than RingTimeout, in
+// one session or in all of them when sessionID is nil
+func (s *WsSignalling) missRingingCalls(ctx context.Context, sessionID *uuid.UUID) {
+	missed, err := s.store.MissRingingCalls(ctx, database.MissRingingCallsParams{
+		RangBefore: time.Now().Add(-RingTimeout),
+		SessionID:  sessionID,
+	})
+	if err != nil {
+		log.Printf("Failed to time out unanswered calls: %v", err)
+		return
+	}
+	for _, call := range missed {
+		s.broadcastCall("timeout", call)
+	}
+}
+
+// handlePresence ends the call of a participant whose last signaling
+// connection went away.
+func (s *WsSignalling) handlePresence(channelID string, userID uuid.UUID, online bool) {
+	if online {
+		return
+	}
+	sessionID, _, ok := signalChannelOwner(channelID)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+
+	call, err := s.store.EndCall(ctx, database.EndCallParams{SessionID: sessionID, UserID: userID})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("Failed to end call in session %s: %v", sessionID, err)
+		}
+		return
+	}
+	s.broadcastCall("end", call)
+}
+
+func (s *WsSignalling) sendCallEvent(incMsg IncomingMessage, eventType string, call database.MentorSessionCall, err error) {
+	sessionID, _, _ := signalChannelOwner(incMsg.ChannelID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.replyError(incMsg, sessionID, "no matching call to "+eventType)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to %s call in session %s: %v", eventType, sessionID, err)
+		s.replyError(incMsg, sessionID, "unable to "+eventType+" call")
+		return
+	}
+	s.broadcastCall(eventType, call)
+}
+
+// broadcastCall tells both participants, on all their connections, about a
+// change of the call.
+func (s *WsSignalling) broadcastCall(eventType string, call database.MentorSessionCall) {
+	payload, err := json.Marshal(call)
+	if err != nil {
+		log.Printf("Marshal error for call %s: %v", call.ID, err)
+		return
+	}
+	for _, to := range []uuid.UUID{call.CallerID, call.CalleeID} {
+		s.sendToPeer(call.SessionID, to, SignalMessage{
+			From:      call.CallerID.String(),
+			To:        to.String(),
+			Type:      eventType,
+			Payload:   payload,
+			SessionID: call.SessionID.String(),
+		})
+	}
+}
+
+func (s *WsSignalling) replyError(incMsg IncomingMessage, sessionID uuid.UUID, reason string) {
+	payload, _ := json.Marshal(struct {
+		Reason string `json:"reason"`
+	}{Reason: reason})
+	s.hub.reply(incMsg, SignalMessage{
+		Type:      "error",
+		Payload:   payload,
+		SessionID: sessionID.String(),
+	})
+}
+
+// signalChannelOwner splits a "signaling:<session>:<user>" channel
+func signalChannelOwner(channelID string) (uuid.UUID, uuid.UUID, bool) {
+	session, user, _ := strings.Cut(strings.TrimPrefix(channelID, "signaling:"), ":")
+	sessionID, err := uuid.Parse(session)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := uuid.Parse(user)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, false
 	}
+	return sessionID, userID, true
 }
 
 func (s *WsSignalling) HandleSignaling(w http.ResponseWriter, r *http.Request) {
@@ -51,14 +285,21 @@ func (s *WsSignalling) HandleSignaling(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing session_id", http.StatusBadRequest)
 		return
 	}
+	userID, session, err := authorizeSessionParticipant(r.Context(), s.store, sessionID)
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
 
+	// every participant listens on its own channel so that negotiation
+	// messages reach the intended peer only
 	q := r.URL.Query()
-	q.Set("channel", "signaling:"+sessionID)
+	q.Set("channel", "signaling:"+session.ID.String()+":"+userID.String())
 	r.URL.RawQuery = q.Encode()
 
-	s.hub.handleWS(w, r, s.signallingChannel)
+	s.hub.handleWS(w, r, s.signallingChannel, s.limiter)
 }
 
-func (s *WsSignalling) sendToSignalSession(signalID string, message SignalMessage) {
-	s.hub.sendToChannel("signaling:"+signalID, message)
+func (s *WsSignalling) sendToPeer(sessionID, userID uuid.UUID, message SignalMessage) {
+	s.hub.sendToChannel("signaling:"+sessionID.String()+":"+userID.String(), message)
 }
@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const ssePingPeriod = 25 * time.Second // below the idle timeout of common proxies
+
+// handleSSE streams the messages of channelID as server-sent events, a
+// fallback for clients that cannot hold a websocket open. Events carry the
+// same JSON as websocket messages with the channel sequence as their id, so
+// a reconnecting EventSource resumes through Last-Event-ID.
+func (h *WsHub) handleSSE(w http.ResponseWriter, r *http.Request, channelID string, limiter *limiter) {
+	rc := http.NewResponseController(w)
+
+	_, userID, _ := authenticatedUser(r.Context())
+	c := newClient(nil, channelID, userID)
+	if code, reason := limiter.admit(r.Context(), c); code != 0 {
+		log.Printf("Refusing event stream for channel %s: %s", channelID, reason)
+		http.Error(w, reason, http.StatusTooManyRequests)
+		return
+	}
+
+	h.mu.Lock()
+	h.conns[channelID] = append(h.conns[channelID], c)
+	firstConn := len(h.conns[channelID]) == 1
+	h.mu.Unlock()
+	h.live.Add(1)
+
+	if firstConn {
+		h.subscribe(channelID)
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// EventSource cannot set headers on its first request
+		lastEventID = r.URL.Query().Get("last_seq")
+	}
+	var backlog []seqMessage
+	if lastSeq, err := strconv.ParseInt(lastEventID, 10, 64); err == nil && lastSeq >= 0 {
+		c.lastSeq, backlog = h.resume(r.Context(), channelID, lastSeq)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // keeps nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	h.trackJoin(c)
+	log.Println("New event stream for channel:", channelID)
+
+	c.streamPump(w, rc, r, backlog, h.trackJoin)
+
+	h.removeConn(channelID, c)
+	h.trackLeave(c)
+	limiter.release(c)
+	h.live.Done()
+}
+
+// streamPump is the writePump of an event stream. It runs on the request
+// goroutine until the client goes away, is stopped or a write fails.
+func (c *client) streamPump(w http.ResponseWriter, rc *http.ResponseController, r *http.Request, backlog []seqMessage, heartbeat func(*client)) {
+	ticker := time.NewTicker(ssePingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.stop()
+		close(c.exited)
+	}()
+
+	send := func(event string) error {
+		rc.SetWriteDeadline(time.Now().Add(writeWait))
+		if _, err := fmt.Fprint(w, event); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	// the retry hint doubles as the first flush, so the client sees the
+	// stream open before any message arrives
+	if err := send("retry: 3000\n\n"); err != nil {
+		log.Printf("Write error to %s: %v (Closing stream)", c.channelID, err)
+		return
+	}
+	for _, msg := range backlog {
+		if err := c.writeEvent(send, msg); err != nil {
+			log.Printf("Write error to %s: %v (Closing stream)", c.channelID, err)
+			return
+		}
+	}
+
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.writeEvent(send, msg); err != nil {
+				log.Printf("Write error to %s: %v (Closing stream)", c.channelID, err)
+				return
+			}
+		case <-ticker.C:
+			// a comment line, ignored by EventSource, keeps proxies from
+			// timing out an idle stream
+			if err := send(": ping\n\n"); err != nil {
+				log.Printf("Ping failed for %s: %v (Closing stream)", c.channelID, err)
+				return
+			}
+			heartbeat(c)
+		case <-r.Context().Done():
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// writeEvent is write for event streams. Messages that bypassed the backlog
+// have no id, so they do not move the client's resume point.
+func (c *client) writeEvent(send func(string) error, msg seqMessage) error {
+	if msg.seq == 0 {
+		return send(fmt.Sprintf("data: %s\n\n", msg.data))
+	}
+	if msg.seq <= c.lastSeq {
+		return nil
+	}
+	if err := send(fmt.Sprintf("id: %d\ndata: %s\n\n", msg.seq, msg.data)); err != nil {
+		return err
+	}
+	c.lastSeq = msg.seq
+	return nil
+}
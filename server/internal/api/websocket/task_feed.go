@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// FeedTask is an open task pushed to the feed of a matching solver
+type FeedTask struct {
+	MessageType  string `json:"messageType"` // "task_open", or "task_reopened" after a missed deadline
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Price        int32  `json:"price"`
+	Deadline     string `json:"deadline"`
+	CategoryID   string `json:"categoryId"`
+	CategoryName string `json:"categoryName"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+type WsTaskFeed struct {
+	hub         *WsHub
+	feedChannel chan IncomingMessage
+	limiter     *limiter
+}
+
+func NewWsTaskFeed(hub *WsHub, limits Limits) *WsTaskFeed {
+	return &WsTaskFeed{
+		hub:         hub,
+		feedChannel: make(chan IncomingMessage, 100),
+		limiter:     newLimiter(hub, "taskFeed", limits),
+	}
+}
+
+func taskFeedChannel(userID uuid.UUID) string {
+	return "feed:" + userID.String()
+}
+
+// HandleTaskFeed joins the open-task feed of the authenticated solver
+func (s *WsTaskFeed) HandleTaskFeed(w http.ResponseWriter, r *http.Request) {
+	claims, userID, err := authenticatedUser(r.Context())
+	if err != nil {
+		writeAccessError(w, err)
+		return
+	}
+	if claims.Role != string(database.RoleSOLVER) {
+		writeAccessError(w, errForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	q.Set("channel", taskFeedChannel(userID))
+	r.URL.RawQuery = q.Encode()
+
+	s.hub.handleWS(w, r, s.feedChannel, s.limiter)
+}
+
+// SendToOnline pushes task to the feeds of the users that have one open.
+// The others would only fill the backlog of their channel, they catch up
+// on open tasks through the task listing.
+func (s *WsTaskFeed) SendToOnline(ctx context.Context, userIDs []uuid.UUID, task FeedTask) (int, error) {
+	subjects := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		subjects[i] = channelSubject(taskFeedChannel(userID))
+	}
+	online, err := s.hub.presence.onlineAll(ctx, subjects)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i, userID := range userIDs {
+		if online[i] {
+			s.hub.sendToChannel(taskFeedChannel(userID), task)
+			sent++
+		}
+	}
+	return sent, nil
+}
@@ -4,31 +4,58 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github/abdallemo/solveit-saas/internal/database"
 	"github/abdallemo/solveit-saas/internal/file"
+	"github/abdallemo/solveit-saas/internal/mailer"
 	"github/abdallemo/solveit-saas/internal/user"
 
 	"github/abdallemo/solveit-saas/internal/utils"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const (
+	previewLength       = 280 // characters of a message quoted in emails
+	maxEmojiLength      = 32  // bytes, enough for zwj sequences with modifiers
+	maxReactionsPerUser = 20  // distinct emojis a user puts on one message
+)
+
+var (
+	ErrNotFound        = errors.New("chat not found")
+	ErrForbidden       = errors.New("not allowed on this chat")
+	ErrInvalidEdit     = errors.New("invalid chat edit")
+	ErrInvalidReaction = errors.New("invalid chat reaction")
+	ErrInvalidReply    = errors.New("invalid chat reply")
+)
+
+// Notifier reaches the recipient of a message who is not connected
+type Notifier interface {
+	EmailIfOffline(ctx context.Context, userID uuid.UUID, eventType, key string, email mailer.Email) error
+}
+
 type Service struct {
 	store       *database.Queries
 	dbConn      *pgxpool.Pool
 	fileService *file.Service
+	notifier    Notifier
 }
 
 func NewService(store *database.Queries,
-	dbConn *pgxpool.Pool, fileService *file.Service) *Service {
+	dbConn *pgxpool.Pool, fileService *file.Service, notifier Notifier) *Service {
 	return &Service{
 		store:       store,
 		dbConn:      dbConn,
 		fileService: fileService,
+		notifier:    notifier,
 	}
 }
 
@@ -43,6 +70,27 @@ type ChatFile struct {
 	ChatID       string     `json:"chatId"`
 }
 
+// ChatEdit is a previous version of an edited message
+type ChatEdit struct {
+	PreviousMessage *string    `json:"previousMessage"`
+	EditedAt        *time.Time `json:"editedAt"`
+}
+
+// ChatReaction groups the users who reacted to a message with one emoji
+type ChatReaction struct {
+	Emoji   string   `json:"emoji"`
+	Count   int      `json:"count"`
+	UserIDs []string `json:"userIds"`
+}
+
+// ChatReply is the message a chat replies to, without its message once deleted
+type ChatReply struct {
+	ID        string  `json:"id"`
+	Message   *string `json:"message"`
+	SentBy    string  `json:"sentBy"`
+	IsDeleted bool    `json:"isDeleted"`
+}
+
 type ChatWithFiles struct {
 	ID          string          `json:"id"`
 	CreatedAt   *time.Time      `json:"createdAt"`
@@ -53,14 +101,21 @@ type ChatWithFiles struct {
 	ReadAt      *time.Time      `json:"readAt"`
 	Pending     *bool           `json:"pending"`
 	IsDeleted   *bool           `json:"isDeleted"`
+	EditedAt    *time.Time      `json:"editedAt"`
 	ChatOwner   user.PublicUser `json:"chatOwner"`
 	ChatFiles   []ChatFile      `json:"chatFiles"`
+	Edits       []ChatEdit      `json:"edits"`
+	Reactions   []ChatReaction  `json:"reactions"`
+	ReplyTo     *ChatReply      `json:"replyTo"`
 	MessageType string          `json:"messageType"`
 }
 
-func MapChat(row database.GetChatWithFilesByIDRow, MessageType string) (ChatWithFiles, error) {
+func MapChat(row database.MentorshipChatDetail, MessageType string) (ChatWithFiles, error) {
 	var owner user.PublicUser
 	var files []ChatFile
+	var edits []ChatEdit
+	var reactions []ChatReaction
+	var replyTo *ChatReply
 
 	if err := json.Unmarshal(row.ChatOwner, &owner); err != nil {
 		return ChatWithFiles{}, err
@@ -70,6 +125,25 @@ func MapChat(row database.GetChatWithFilesByIDRow, MessageType string) (ChatWith
 		return ChatWithFiles{}, err
 	}
 
+	if err := json.Unmarshal(row.ChatEdits, &edits); err != nil {
+		return ChatWithFiles{}, err
+	}
+
+	if err := json.Unmarshal(row.ChatReactions, &reactions); err != nil {
+		return ChatWithFiles{}, err
+	}
+
+	if len(row.ReplyTo) > 0 {
+		if err := json.Unmarshal(row.ReplyTo, &replyTo); err != nil {
+			return ChatWithFiles{}, err
+		}
+	}
+
+	var editedAt *time.Time
+	if len(edits) > 0 {
+		editedAt = edits[len(edits)-1].EditedAt
+	}
+
 	return ChatWithFiles{
 		ID:          row.ID.String(),
 		CreatedAt:   row.CreatedAt,
@@ -80,13 +154,18 @@ func MapChat(row database.GetChatWithFilesByIDRow, MessageType string) (ChatWith
 		ReadAt:      row.ReadAt,
 		Pending:     row.Pending,
 		IsDeleted:   row.IsDeleted,
+		EditedAt:    editedAt,
 		ChatOwner:   owner,
 		ChatFiles:   files,
+		Edits:       edits,
+		Reactions:   reactions,
+		ReplyTo:     replyTo,
 		MessageType: MessageType,
 	}, nil
 }
 
-// CreateChatWithFiles handles DB transaction for chat and file metadata
+// CreateChatWithFiles handles DB transaction for chat and file metadata.
+// replyTo, when set, must be a message of the same session.
 func (s *Service) CreateChatWithFiles(
 	ctx context.Context,
 	message string,
@@ -94,6 +173,7 @@ func (s *Service) CreateChatWithFiles(
 	sessionID uuid.UUID,
 	sentBy uuid.UUID,
 	sentTo uuid.UUID,
+	replyTo *uuid.UUID,
 	files []file.FileMeta,
 ) (ChatWithFiles, error) {
 
@@ -132,6 +212,23 @@ func (s *Service) CreateChatWithFiles(
 
 	}
 
+	if replyTo != nil {
+		parent, err := qtx.GetChatParticipants(ctx, *replyTo)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && parent.SeesionID != sessionID) {
+			return ChatWithFiles{}, fmt.Errorf("%w: message is not in this session", ErrInvalidReply)
+		}
+		if err != nil {
+			return ChatWithFiles{}, err
+		}
+		err = qtx.CreateChatReply(ctx, database.CreateChatReplyParams{
+			ChatID:    chat.ID,
+			ReplyToID: parent.ID,
+		})
+		if err != nil {
+			return ChatWithFiles{}, err
+		}
+	}
+
 	chatRow, err := qtx.GetChatWithFilesByID(ctx, chat.ID)
 	if err != nil {
 		return ChatWithFiles{}, err
@@ -146,9 +243,42 @@ func (s *Service) CreateChatWithFiles(
 		return ChatWithFiles{}, err
 	}
 
+	s.notifyOffline(ctx, result)
 	return result, nil
 
 }
+
+// notifyOffline emails the recipient of a message when they are not
+// connected, at most once per session in a while.
+func (s *Service) notifyOffline(ctx context.Context, chat ChatWithFiles) {
+	recipient, err := uuid.Parse(chat.SentTo)
+	if err != nil {
+		return
+	}
+
+	preview := ""
+	if chat.Message != nil {
+		preview = *chat.Message
+	}
+	if runes := []rune(preview); len(runes) > previewLength {
+		preview = string(runes[:previewLength]) + "..."
+	}
+	if preview == "" && len(chat.ChatFiles) > 0 {
+		preview = "Sent an attachment"
+	}
+
+	err = s.notifier.EmailIfOffline(ctx, recipient, "chat_message", "chat:"+chat.SessionID, mailer.Email{
+		Kind: mailer.KindChatMessage,
+		Data: map[string]any{
+			"SenderName": chat.ChatOwner.Name,
+			"Preview":    preview,
+			"SessionID":  chat.SessionID,
+		},
+	})
+	if err != nil {
+		log.Printf("failed to email offline recipient of chat %s: %v", chat.ID, err)
+	}
+}
 func (s *Service) DeleteChatWithFiles(ctx context.Context, chatId uuid.UUID, filePath string) (database.DeleteChatWithFilesRow, error) {
 	deletedChat, err := s.store.DeleteChatWithFiles(ctx,
 		database.DeleteChatWithFilesParams{
@@ -163,12 +293,165 @@ func (s *Service) DeleteChatWithFiles(ctx context.Context, chatId uuid.UUID, fil
 	for _, filePath := range deletedChat.DeletedFilePaths {
 		if filePath != "" {
 
-			err = s.fileService.DeleteFromS3(filePath)
+			err = s.fileService.DeleteFile(filePath)
 			if err != nil {
-				log.Printf("WARNING: DB deleted but S3 failed for %s. Error: %s", filePath, err.Error())
+				log.Printf("WARNING: DB deleted but storage failed for %s. Error: %s", filePath, err.Error())
 			}
 
 		}
 	}
 	return deletedChat, nil
 }
+
+// EditChat replaces the message of a chat sent by editorID, keeping the
+// previous one in its edit history.
+func (s *Service) EditChat(ctx context.Context, chatID, editorID uuid.UUID, message string) (ChatWithFiles, error) {
+	message = strings.TrimSpace(message)
+
+	tx, err := s.dbConn.Begin(ctx)
+	if err != nil {
+		return ChatWithFiles{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.store.WithTx(tx)
+
+	current, err := qtx.GetChatForUpdate(ctx, chatID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ChatWithFiles{}, ErrNotFound
+	}
+	if err != nil {
+		return ChatWithFiles{}, err
+	}
+	if current.SentBy != editorID {
+		return ChatWithFiles{}, ErrForbidden
+	}
+	if current.IsDeleted != nil && *current.IsDeleted {
+		return ChatWithFiles{}, fmt.Errorf("%w: message was deleted", ErrInvalidEdit)
+	}
+	if message == "" {
+		return ChatWithFiles{}, fmt.Errorf("%w: message is required", ErrInvalidEdit)
+	}
+	if current.Message != nil && *current.Message == message {
+		return ChatWithFiles{}, fmt.Errorf("%w: message is unchanged", ErrInvalidEdit)
+	}
+
+	err = qtx.CreateChatEdit(ctx, database.CreateChatEditParams{
+		ChatID:          chatID,
+		PreviousMessage: current.Message,
+	})
+	if err != nil {
+		return ChatWithFiles{}, err
+	}
+	err = qtx.UpdateChatMessage(ctx, database.UpdateChatMessageParams{
+		ID:      chatID,
+		Message: &message,
+	})
+	if err != nil {
+		return ChatWithFiles{}, err
+	}
+
+	chatRow, err := qtx.GetChatWithFilesByID(ctx, chatID)
+	if err != nil {
+		return ChatWithFiles{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return ChatWithFiles{}, err
+	}
+
+	return MapChat(chatRow, "chat_edited")
+}
+
+// React adds or removes the emoji reaction of a session participant to a
+// message, and returns the message with its reactions.
+func (s *Service) React(ctx context.Context, chatID, userID uuid.UUID, emoji string, add bool) (ChatWithFiles, error) {
+	if err := validateEmoji(emoji); err != nil {
+		return ChatWithFiles{}, err
+	}
+
+	participants, err := s.store.GetChatParticipants(ctx, chatID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ChatWithFiles{}, ErrNotFound
+	}
+	if err != nil {
+		return ChatWithFiles{}, err
+	}
+	if participants.SentBy != userID && participants.SentTo != userID {
+		return ChatWithFiles{}, ErrForbidden
+	}
+
+	if add {
+		if participants.IsDeleted != nil && *participants.IsDeleted {
+			return ChatWithFiles{}, fmt.Errorf("%w: message was deleted", ErrInvalidReaction)
+		}
+		count, err := s.store.CountUserChatReactions(ctx, database.CountUserChatReactionsParams{
+			ChatID: chatID,
+			UserID: userID,
+		})
+		if err != nil {
+			return ChatWithFiles{}, err
+		}
+		if count >= maxReactionsPerUser {
+			return ChatWithFiles{}, fmt.Errorf("%w: at most %d reactions per message", ErrInvalidReaction, maxReactionsPerUser)
+		}
+		_, err = s.store.AddChatReaction(ctx, database.AddChatReactionParams{
+			ChatID: chatID,
+			UserID: userID,
+			Emoji:  emoji,
+		})
+		if err != nil {
+			return ChatWithFiles{}, err
+		}
+	} else {
+		_, err = s.store.RemoveChatReaction(ctx, database.RemoveChatReactionParams{
+			ChatID: chatID,
+			UserID: userID,
+			Emoji:  emoji,
+		})
+		if err != nil {
+			return ChatWithFiles{}, err
+		}
+	}
+
+	chatRow, err := s.store.GetChatWithFilesByID(ctx, chatID)
+	if err != nil {
+		return ChatWithFiles{}, err
+	}
+	return MapChat(chatRow, "chat_reaction")
+}
+
+// validateEmoji accepts a single short emoji sequence, not words
+func validateEmoji(emoji string) error {
+	if emoji == "" || len(emoji) > maxEmojiLength || !utf8.ValidString(emoji) {
+		return fmt.Errorf("%w: emoji must be 1 to %d bytes", ErrInvalidReaction, maxEmojiLength)
+	}
+	for _, r := range emoji {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("%w: %q is not an emoji", ErrInvalidReaction, emoji)
+		}
+	}
+	return nil
+}
+
+type UnreadCount struct {
+	SessionID string `json:"sessionId"`
+	Unread    int    `json:"unread"`
+}
+
+// GetUnreadCounts returns the number of unread messages sent to userID, per session
+func (s *Service) GetUnreadCounts(ctx context.Context, userID uuid.UUID) ([]UnreadCount, error) {
+	rows, err := s.store.GetUnreadChatCounts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]UnreadCount, 0, len(rows))
+	for _, row := range rows {
+		counts = append(counts, UnreadCount{
+			SessionID: row.SeesionID.String(),
+			Unread:    int(row.Unread),
+		})
+	}
+	return counts, nil
+}
@@ -0,0 +1,328 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/user"
+	"github/abdallemo/solveit-saas/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultPageSize   = 50
+	MaxPageSize       = 100
+	maxQueryLength    = 200
+	defaultContextLen = 10 // messages on each side of a message jumped to
+)
+
+var ErrInvalidSearch = errors.New("invalid chat search")
+
+// HistoryPage is a run of consecutive messages of a session, oldest first
+type HistoryPage struct {
+	Chats      []ChatWithFiles `json:"chats"`
+	PrevCursor *string         `json:"prevCursor"` // loads the older messages, nil at the start
+	NextCursor *string         `json:"nextCursor"` // loads the newer messages, nil at the end
+}
+
+// ChatContext is the HistoryPage around a message jumped to
+type ChatContext struct {
+	TargetID string `json:"targetId"`
+	HistoryPage
+}
+
+// SearchResult is a message matching a search, to jump to with its context
+type SearchResult struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"sessionId"`
+	Message   *string         `json:"message"`
+	SentBy    string          `json:"sentBy"`
+	CreatedAt *time.Time      `json:"createdAt"`
+	ChatOwner user.PublicUser `json:"chatOwner"`
+}
+
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor *string        `json:"nextCursor"`
+}
+
+// authorizeParticipant allows the solver and the student of the session only
+func (s *Service) authorizeParticipant(ctx context.Context, sessionID, userID uuid.UUID) error {
+	session, err := s.store.GetMentorSessionParticipants(ctx, sessionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if userID != session.SolverID && userID != session.StudentID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Counterpart is the other participant of the session, for userID to send
+// messages to
+func (s *Service) Counterpart(ctx context.Context, sessionID, userID uuid.UUID) (uuid.UUID, error) {
+	session, err := s.store.GetMentorSessionParticipants(ctx, sessionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrNotFound
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	switch userID {
+	case session.SolverID:
+		return session.StudentID, nil
+	case session.StudentID:
+		return session.SolverID, nil
+	default:
+		return uuid.Nil, ErrForbidden
+	}
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(limit, MaxPageSize)
+}
+
+func chatCursor(c ChatWithFiles) *string {
+	id, _ := uuid.Parse(c.ID)
+	var createdAt time.Time
+	if c.CreatedAt != nil {
+		createdAt = *c.CreatedAt
+	}
+	cursor := utils.EncodeCursor(createdAt, id)
+	return &cursor
+}
+
+// History returns a page of the messages of a session. With before it loads
+// the messages older than that cursor, with after the newer ones, and with
+// neither the latest ones.
+func (s *Service) History(ctx context.Context, sessionID, userID uuid.UUID, before, after string, limit int) (HistoryPage, error) {
+	if err := s.authorizeParticipant(ctx, sessionID, userID); err != nil {
+		return HistoryPage{}, err
+	}
+	limit = pageSize(limit)
+
+	if after != "" {
+		createdAt, id, err := utils.DecodeCursor(after)
+		if err != nil {
+			return HistoryPage{}, err
+		}
+		chats, more, err := s.listAfter(ctx, sessionID, createdAt, id, limit)
+		if err != nil {
+			return HistoryPage{}, err
+		}
+		// the message of the cursor at least is older
+		page := HistoryPage{Chats: chats, PrevCursor: &after}
+		if len(chats) > 0 {
+			page.PrevCursor = chatCursor(chats[0])
+		}
+		if more {
+			page.NextCursor = chatCursor(chats[len(chats)-1])
+		}
+		return page, nil
+	}
+
+	var beforeAt *time.Time
+	var beforeID *uuid.UUID
+	if before != "" {
+		createdAt, id, err := utils.DecodeCursor(before)
+		if err != nil {
+			return HistoryPage{}, err
+		}
+		beforeAt, beforeID = &createdAt, &id
+	}
+	chats, more, err := s.listBefore(ctx, sessionID, beforeAt, beforeID, limit)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+	page := HistoryPage{Chats: chats}
+	if more {
+		page.PrevCursor = chatCursor(chats[0])
+	}
+	if before != "" {
+		// the message of the cursor at least is newer
+		page.NextCursor = &before
+		if len(chats) > 0 {
+			page.NextCursor = chatCursor(chats[len(chats)-1])
+		}
+	}
+	return page, nil
+}
+
+// Context returns a message with the limit messages sent before and after it
+func (s *Service) Context(ctx context.Context, chatID, userID uuid.UUID, limit int) (ChatContext, error) {
+	row, err := s.store.GetChatWithFilesByID(ctx, chatID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ChatContext{}, ErrNotFound
+	}
+	if err != nil {
+		return ChatContext{}, err
+	}
+	if err := s.authorizeParticipant(ctx, row.SeesionID, userID); err != nil {
+		return ChatContext{}, err
+	}
+	if limit <= 0 {
+		limit = defaultContextLen
+	}
+	limit = min(limit, MaxPageSize/2)
+
+	target, err := MapChat(row, "chat_message")
+	if err != nil {
+		return ChatContext{}, err
+	}
+	var createdAt time.Time
+	if row.CreatedAt != nil {
+		createdAt = *row.CreatedAt
+	}
+
+	older, moreOlder, err := s.listBefore(ctx, row.SeesionID, &createdAt, &row.ID, limit)
+	if err != nil {
+		return ChatContext{}, err
+	}
+	newer, moreNewer, err := s.listAfter(ctx, row.SeesionID, createdAt, row.ID, limit)
+	if err != nil {
+		return ChatContext{}, err
+	}
+
+	chats := make([]ChatWithFiles, 0, len(older)+1+len(newer))
+	chats = append(chats, older...)
+	chats = append(chats, target)
+	chats = append(chats, newer...)
+
+	result := ChatContext{TargetID: target.ID, HistoryPage: HistoryPage{Chats: chats}}
+	if moreOlder {
+		result.PrevCursor = chatCursor(chats[0])
+	}
+	if moreNewer {
+		result.NextCursor = chatCursor(chats[len(chats)-1])
+	}
+	return result, nil
+}
+
+// listBefore returns up to limit messages older than the cursor, oldest
+// first, and whether there are more
+func (s *Service) listBefore(ctx context.Context, sessionID uuid.UUID, createdAt *time.Time, id *uuid.UUID, limit int) ([]ChatWithFiles, bool, error) {
+	rows, err := s.store.ListSessionChatsBefore(ctx, database.ListSessionChatsBeforeParams{
+		SessionID:       sessionID,
+		BeforeCreatedAt: createdAt,
+		BeforeID:        id,
+		// one extra row tells whether there is a next page
+		PageSize: int32(limit + 1),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	more := len(rows) > limit
+	rows = rows[:min(len(rows), limit)]
+	chats := make([]ChatWithFiles, 0, len(rows))
+	for _, row := range slices.Backward(rows) {
+		chat, err := MapChat(row, "chat_message")
+		if err != nil {
+			return nil, false, err
+		}
+		chats = append(chats, chat)
+	}
+	return chats, more, nil
+}
+
+// listAfter returns up to limit messages newer than the cursor, oldest
+// first, and whether there are more
+func (s *Service) listAfter(ctx context.Context, sessionID uuid.UUID, createdAt time.Time, id uuid.UUID, limit int) ([]ChatWithFiles, bool, error) {
+	rows, err := s.store.ListSessionChatsAfter(ctx, database.ListSessionChatsAfterParams{
+		SessionID:      sessionID,
+		AfterCreatedAt: createdAt,
+		AfterID:        id,
+		PageSize:       int32(limit + 1),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	more := len(rows) > limit
+	rows = rows[:min(len(rows), limit)]
+	chats := make([]ChatWithFiles, 0, len(rows))
+	for _, row := range rows {
+		chat, err := MapChat(row, "chat_message")
+		if err != nil {
+			return nil, false, err
+		}
+		chats = append(chats, chat)
+	}
+	return chats, more, nil
+}
+
+// Search finds the messages matching query in the sessions of the user, or
+// in one of them, newest first.
+func (s *Service) Search(ctx context.Context, userID uuid.UUID, query string, sessionID *uuid.UUID, cursor string, limit int) (SearchPage, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || len(query) > maxQueryLength {
+		return SearchPage{}, fmt.Errorf("%w: query must be 1 to %d characters", ErrInvalidSearch, maxQueryLength)
+	}
+	if sessionID != nil {
+		if err := s.authorizeParticipant(ctx, *sessionID, userID); err != nil {
+			return SearchPage{}, err
+		}
+	}
+	limit = pageSize(limit)
+
+	params := database.SearchUserChatsParams{
+		UserID:    userID,
+		SessionID: sessionID,
+		Query:     query,
+		PageSize:  int32(limit + 1),
+	}
+	if cursor != "" {
+		createdAt, id, err := utils.DecodeCursor(cursor)
+		if err != nil {
+			return SearchPage{}, err
+		}
+		params.BeforeCreatedAt = &createdAt
+		params.BeforeID = &id
+	}
+
+	rows, err := s.store.SearchUserChats(ctx, params)
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	page := SearchPage{Results: make([]SearchResult, 0, min(len(rows), limit))}
+	for i, row := range rows {
+		if i == limit {
+			last := rows[i-1]
+			var createdAt time.Time
+			if last.CreatedAt != nil {
+				createdAt = *last.CreatedAt
+			}
+			next := utils.EncodeCursor(createdAt, last.ID)
+			page.NextCursor = &next
+			break
+		}
+		var owner user.PublicUser
+		if err := json.Unmarshal(row.ChatOwner, &owner); err != nil {
+			return SearchPage{}, err
+		}
+		page.Results = append(page.Results, SearchResult{
+			ID:        row.ID.String(),
+			SessionID: row.SeesionID.String(),
+			Message:   row.Message,
+			SentBy:    row.SentBy.String(),
+			CreatedAt: row.CreatedAt,
+			ChatOwner: owner,
+		})
+	}
+	return page, nil
+}
@@ -0,0 +1,233 @@
+// Package comment holds task comment logic
+package comment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/ai"
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/task"
+	"github/abdallemo/solveit-saas/internal/user"
+	"github/abdallemo/solveit-saas/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxContentLength = 5000
+	DefaultPageSize  = 20
+	MaxPageSize      = 100
+)
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrNotFound     = errors.New("comment not found")
+	ErrForbidden    = errors.New("not allowed to access this comment")
+	ErrInvalid      = errors.New("comment must be between 1 and 5000 characters")
+)
+
+// RejectedError is returned when moderation refuses the content of a comment
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "comment rejected: " + e.Reason
+}
+
+type Service struct {
+	store     *database.Queries
+	aiService *ai.Service
+}
+
+func NewService(store *database.Queries, aiService *ai.Service) *Service {
+	return &Service{
+		store:     store,
+		aiService: aiService,
+	}
+}
+
+type Comment struct {
+	ID          string          `json:"id"`
+	Content     string          `json:"content"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UserID      string          `json:"userId"`
+	TaskID      string          `json:"taskId"`
+	Owner       user.PublicUser `json:"owner"`
+	MessageType string          `json:"messageType"`
+}
+
+type Page struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor *string   `json:"nextCursor"`
+}
+
+func mapComment(row database.GetTaskCommentWithOwnerRow) (Comment, error) {
+	var owner user.PublicUser
+	if err := json.Unmarshal(row.Owner, &owner); err != nil {
+		return Comment{}, err
+	}
+
+	return Comment{
+		ID:        row.ID.String(),
+		Content:   row.Content,
+		CreatedAt: row.CreatedAt,
+		UserID:    row.UserID.String(),
+		TaskID:    row.TaskID.String(),
+		Owner:     owner,
+	}, nil
+}
+
+// CanView checks that the user may read the comments of a task
+func (s *Service) CanView(ctx context.Context, taskID, userID uuid.UUID, role string) error {
+	access, err := s.store.GetTaskAccess(ctx, taskID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTaskNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !task.CanView(access, userID, role) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CreateComment moderates and stores a comment written by userID
+func (s *Service) CreateComment(ctx context.Context, taskID, userID uuid.UUID, content string) (Comment, error) {
+	content, err := s.moderate(ctx, content)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	id, err := s.store.CreateTaskComment(ctx, database.CreateTaskCommentParams{
+		TaskID:  taskID,
+		UserID:  userID,
+		Content: content,
+	})
+	if err != nil {
+		return Comment{}, err
+	}
+	return s.getComment(ctx, id)
+}
+
+// ListComments returns a page of comments of a task, newest first. cursor is
+// the NextCursor of the previous page, or empty for the first one.
+func (s *Service) ListComments(ctx context.Context, taskID uuid.UUID, cursor string, limit int) (Page, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	params := database.ListTaskCommentsParams{
+		TaskID: taskID,
+		// one extra row tells whether there is a next page
+		PageSize: int32(limit + 1),
+	}
+	if cursor != "" {
+		createdAt, id, err := utils.DecodeCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		params.BeforeCreatedAt = &createdAt
+		params.BeforeID = &id
+	}
+
+	rows, err := s.store.ListTaskComments(ctx, params)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Comments: make([]Comment, 0, min(len(rows), limit))}
+	for i, row := range rows {
+		if i == limit {
+			last := rows[i-1]
+			next := utils.EncodeCursor(last.CreatedAt, last.ID)
+			page.NextCursor = &next
+			break
+		}
+		comment, err := mapComment(database.GetTaskCommentWithOwnerRow(row))
+		if err != nil {
+			return Page{}, err
+		}
+		page.Comments = append(page.Comments, comment)
+	}
+	return page, nil
+}
+
+// UpdateComment moderates and stores new content, only the author may edit
+func (s *Service) UpdateComment(ctx context.Context, commentID, userID uuid.UUID, content string) (Comment, error) {
+	existing, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return Comment{}, err
+	}
+	if existing.UserID != userID.String() {
+		return Comment{}, ErrForbidden
+	}
+
+	content, err = s.moderate(ctx, content)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	err = s.store.UpdateTaskComment(ctx, database.UpdateTaskCommentParams{
+		ID:      commentID,
+		Content: content,
+	})
+	if err != nil {
+		return Comment{}, err
+	}
+	return s.getComment(ctx, commentID)
+}
+
+// DeleteComment removes a comment on behalf of its author or staff and
+// returns what was deleted
+func (s *Service) DeleteComment(ctx context.Context, commentID, userID uuid.UUID, role string) (Comment, error) {
+	existing, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return Comment{}, err
+	}
+	if existing.UserID != userID.String() &&
+		role != string(database.RoleADMIN) &&
+		role != string(database.RoleMODERATOR) {
+		return Comment{}, ErrForbidden
+	}
+
+	if err := s.store.DeleteTaskComment(ctx, commentID); err != nil {
+		return Comment{}, err
+	}
+	return existing, nil
+}
+
+func (s *Service) getComment(ctx context.Context, commentID uuid.UUID) (Comment, error) {
+	row, err := s.store.GetTaskCommentWithOwner(ctx, commentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Comment{}, ErrNotFound
+	}
+	if err != nil {
+		return Comment{}, err
+	}
+	return mapComment(row)
+}
+
+// moderate validates content and runs it through the moderation model
+func (s *Service) moderate(ctx context.Context, content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || len(content) > maxContentLength {
+		return "", ErrInvalid
+	}
+
+	res, err := s.aiService.CheckModeration(ctx, content)
+	if err != nil {
+		return "", err
+	}
+	if res.ViolatesRules {
+		return "", &RejectedError{Reason: res.Reason}
+	}
+	return content, nil
+}
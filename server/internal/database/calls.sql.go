@@ -0,0 +1,210 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: calls.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const acceptCall = `-- name: AcceptCall :one
+UPDATE mentor_session_calls
+SET status = 'ACTIVE',
+    answered_at = now()
+WHERE session_id = $1
+  AND callee_id = $2
+  AND status = 'RINGING'
+RETURNING id, session_id, caller_id, callee_id, status, started_at, answered_at, ended_at, duration_seconds
+`
+
+type AcceptCallParams struct {
+	SessionID uuid.UUID `json:"session_id"`
+	CalleeID  uuid.UUID `json:"callee_id"`
+}
+
+func (q *Queries) AcceptCall(ctx context.Context, arg AcceptCallParams) (MentorSessionCall, error) {
+	row := q.db.QueryRow(ctx, acceptCall, arg.SessionID, arg.CalleeID)
+	var i MentorSessionCall
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.CallerID,
+		&i.CalleeID,
+		&i.Status,
+		&i.StartedAt,
+		&i.AnsweredAt,
+		&i.EndedAt,
+		&i.DurationSeconds,
+	)
+	return i, err
+}
+
+const createCall = `-- name: CreateCall :one
+INSERT INTO mentor_session_calls (session_id, caller_id, callee_id)
+VALUES ($1, $2, $3)
+RETURNING id, session_id, caller_id, callee_id, status, started_at, answered_at, ended_at, duration_seconds
+`
+
+type CreateCallParams struct {
+	SessionID uuid.UUID `json:"session_id"`
+	CallerID  uuid.UUID `json:"caller_id"`
+	CalleeID  uuid.UUID `json:"callee_id"`
+}
+
+func (q *Queries) CreateCall(ctx context.Context, arg CreateCallParams) (MentorSessionCall, error) {
+	row := q.db.QueryRow(ctx, createCall, arg.SessionID, arg.CallerID, arg.CalleeID)
+	var i MentorSessionCall
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.CallerID,
+		&i.CalleeID,
+		&i.Status,
+		&i.StartedAt,
+		&i.AnsweredAt,
+		&i.EndedAt,
+		&i.DurationSeconds,
+	)
+	return i, err
+}
+
+const endCall = `-- name: EndCall :one
+UPDATE mentor_session_calls
+SET status = CASE WHEN status = 'ACTIVE' THEN 'ENDED'::call_status ELSE 'CANCELED'::call_status END,
+    ended_at = now(),
+    duration_seconds = CASE
+      WHEN answered_at IS NULL THEN 0
+      ELSE EXTRACT(EPOCH FROM now() - answered_at)::int
+    END
+WHERE session_id = $1
+  AND (caller_id = $2 OR callee_id = $2)
+  AND status IN ('RINGING', 'ACTIVE')
+RETURNING id, session_id, caller_id, callee_id, status, started_at, answered_at, ended_at, duration_seconds
+`
+
+type EndCallParams struct {
+	SessionID uuid.UUID `json:"session_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) EndCall(ctx context.Context, arg EndCallParams) (MentorSessionCall, error) {
+	row := q.db.QueryRow(ctx, endCall, arg.SessionID, arg.UserID)
+	var i MentorSessionCall
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.CallerID,
+		&i.CalleeID,
+		&i.Status,
+		&i.StartedAt,
+		&i.AnsweredAt,
+		&i.EndedAt,
+		&i.DurationSeconds,
+	)
+	return i, err
+}
+
+const getLiveCall = `-- name: GetLiveCall :one
+SELECT id, session_id, caller_id, callee_id, status, started_at, answered_at, ended_at, duration_seconds FROM mentor_session_calls
+WHERE session_id = $1
+  AND status IN ('RINGING', 'ACTIVE')
+`
+
+func (q *Queries) GetLiveCall(ctx context.Context, sessionID uuid.UUID) (MentorSessionCall, error) {
+	row := q.db.QueryRow(ctx, getLiveCall, sessionID)
+	var i MentorSessionCall
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.CallerID,
+		&i.CalleeID,
+		&i.Status,
+		&i.StartedAt,
+		&i.AnsweredAt,
+		&i.EndedAt,
+		&i.DurationSeconds,
+	)
+	return i, err
+}
+
+const missRingingCalls = `-- name: MissRingingCalls :many
+UPDATE mentor_session_calls
+SET status = 'MISSED',
+    ended_at = now()
+WHERE status = 'RINGING'
+  AND started_at < $1
+  AND ($2::uuid IS NULL OR session_id = $2)
+RETURNING id, session_id, caller_id, callee_id, status, started_at, answered_at, ended_at, duration_seconds
+`
+
+type MissRingingCallsParams struct {
+	RangBefore time.Time  `json:"rang_before"`
+	SessionID  *uuid.UUID `json:"session_id"`
+}
+
+func (q *Queries) MissRingingCalls(ctx context.Context, arg MissRingingCallsParams) ([]MentorSessionCall, error) {
+	rows, err := q.db.Query(ctx, missRingingCalls, arg.RangBefore, arg.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MentorSessionCall
+	for rows.Next() {
+		var i MentorSessionCall
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.CallerID,
+			&i.CalleeID,
+			&i.Status,
+			&i.StartedAt,
+			&i.AnsweredAt,
+			&i.EndedAt,
+			&i.DurationSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rejectCall = `-- name: RejectCall :one
+UPDATE mentor_session_calls
+SET status = 'REJECTED',
+    ended_at = now()
+WHERE session_id = $1
+  AND callee_id = $2
+  AND status = 'RINGING'
+RETURNING id, session_id, caller_id, callee_id, status, started_at, answered_at, ended_at, duration_seconds
+`
+
+type RejectCallParams struct {
+	SessionID uuid.UUID `json:"session_id"`
+	CalleeID  uuid.UUID `json:"callee_id"`
+}
+
+func (q *Queries) RejectCall(ctx context.Context, arg RejectCallParams) (MentorSessionCall, error) {
+	row := q.db.QueryRow(ctx, rejectCall, arg.SessionID, arg.CalleeID)
+	var i MentorSessionCall
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.CallerID,
+		&i.CalleeID,
+		&i.Status,
+		&i.StartedAt,
+		&i.AnsweredAt,
+		&i.EndedAt,
+		&i.DurationSeconds,
+	)
+	return i, err
+}
@@ -12,6 +12,44 @@ import (
 	"github.com/google/uuid"
 )
 
+const addChatReaction = `-- name: AddChatReaction :execrows
+INSERT INTO mentorship_chat_reactions (chat_id, user_id, emoji)
+VALUES ($1, $2, $3)
+ON CONFLICT (chat_id, user_id, emoji) DO NOTHING
+`
+
+type AddChatReactionParams struct {
+	ChatID uuid.UUID `json:"chat_id"`
+	UserID uuid.UUID `json:"user_id"`
+	Emoji  string    `json:"emoji"`
+}
+
+func (q *Queries) AddChatReaction(ctx context.Context, arg AddChatReactionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, addChatReaction, arg.ChatID, arg.UserID, arg.Emoji)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const countUserChatReactions = `-- name: CountUserChatReactions :one
+SELECT COUNT(*)::int AS reactions
+FROM mentorship_chat_reactions
+WHERE chat_id = $1 AND user_id = $2
+`
+
+type CountUserChatReactionsParams struct {
+	ChatID uuid.UUID `json:"chat_id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) CountUserChatReactions(ctx context.Context, arg CountUserChatReactionsParams) (int32, error) {
+	row := q.db.QueryRow(ctx, countUserChatReactions, arg.ChatID, arg.UserID)
+	var reactions int32
+	err := row.Scan(&reactions)
+	return reactions, err
+}
+
 const createChat = `-- name: CreateChat :one
 INSERT INTO mentorship_chats (
 seesion_id,
@@ -55,6 +93,21 @@ func (q *Queries) CreateChat(ctx context.Context, arg CreateChatParams) (Mentors
 	return i, err
 }
 
+const createChatEdit = `-- name: CreateChatEdit :exec
+INSERT INTO mentorship_chat_edits (chat_id, previous_message)
+VALUES ($1, $2)
+`
+
+type CreateChatEditParams struct {
+	ChatID          uuid.UUID `json:"chat_id"`
+	PreviousMessage *string   `json:"previous_message"`
+}
+
+func (q *Queries) CreateChatEdit(ctx context.Context, arg CreateChatEditParams) error {
+	_, err := q.db.Exec(ctx, createChatEdit, arg.ChatID, arg.PreviousMessage)
+	return err
+}
+
 const createChatFiles = `-- name: CreateChatFiles :exec
 INSERT INTO mentorship_chat_files (
 chat_id,
@@ -93,6 +146,21 @@ func (q *Queries) CreateChatFiles(ctx context.Context, arg CreateChatFilesParams
 	return err
 }
 
+const createChatReply = `-- name: CreateChatReply :exec
+INSERT INTO mentorship_chat_replies (chat_id, reply_to_id)
+VALUES ($1, $2)
+`
+
+type CreateChatReplyParams struct {
+	ChatID    uuid.UUID `json:"chat_id"`
+	ReplyToID uuid.UUID `json:"reply_to_id"`
+}
+
+func (q *Queries) CreateChatReply(ctx context.Context, arg CreateChatReplyParams) error {
+	_, err := q.db.Exec(ctx, createChatReply, arg.ChatID, arg.ReplyToID)
+	return err
+}
+
 const deleteChatFileByPath = `-- name: DeleteChatFileByPath :exec
 DELETE FROM mentorship_chat_files WHERE file_path = $1
 `
@@ -179,18 +247,347 @@ func (q *Queries) DeleteChatWithFiles(ctx context.Context, arg DeleteChatWithFil
 	return i, err
 }
 
+const getChatForUpdate = `-- name: GetChatForUpdate :one
+SELECT id, seesion_id, message, sent_by, sent_to, is_deleted
+FROM mentorship_chats
+WHERE id = $1
+FOR UPDATE
+`
+
+type GetChatForUpdateRow struct {
+	ID        uuid.UUID `json:"id"`
+	SeesionID uuid.UUID `json:"seesion_id"`
+	Message   *string   `json:"message"`
+	SentBy    uuid.UUID `json:"sent_by"`
+	SentTo    uuid.UUID `json:"sent_to"`
+	IsDeleted *bool     `json:"is_deleted"`
+}
+
+func (q *Queries) GetChatForUpdate(ctx context.Context, id uuid.UUID) (GetChatForUpdateRow, error) {
+	row := q.db.QueryRow(ctx, getChatForUpdate, id)
+	var i GetChatForUpdateRow
+	err := row.Scan(
+		&i.ID,
+		&i.SeesionID,
+		&i.Message,
+		&i.SentBy,
+		&i.SentTo,
+		&i.IsDeleted,
+	)
+	return i, err
+}
+
+const getChatParticipants = `-- name: GetChatParticipants :one
+SELECT id, seesion_id, sent_by, sent_to, is_deleted
+FROM mentorship_chats
+WHERE id = $1
+`
+
+type GetChatParticipantsRow struct {
+	ID        uuid.UUID `json:"id"`
+	SeesionID uuid.UUID `json:"seesion_id"`
+	SentBy    uuid.UUID `json:"sent_by"`
+	SentTo    uuid.UUID `json:"sent_to"`
+	IsDeleted *bool     `json:"is_deleted"`
+}
+
+func (q *Queries) GetChatParticipants(ctx context.Context, id uuid.UUID) (GetChatParticipantsRow, error) {
+	row := q.db.QueryRow(ctx, getChatParticipants, id)
+	var i GetChatParticipantsRow
+	err := row.Scan(
+		&i.ID,
+		&i.SeesionID,
+		&i.SentBy,
+		&i.SentTo,
+		&i.IsDeleted,
+	)
+	return i, err
+}
+
 const getChatWithFilesByID = `-- name: GetChatWithFilesByID :one
+SELECT id, created_at, seesion_id, message, sent_by, sent_to, read_at, pending, is_deleted, chat_owner, chat_files, chat_edits, chat_reactions, reply_to
+FROM mentorship_chat_details c
+WHERE c.id = $1
+ORDER BY c.created_at ASC
+`
+
+func (q *Queries) GetChatWithFilesByID(ctx context.Context, id uuid.UUID) (MentorshipChatDetail, error) {
+	row := q.db.QueryRow(ctx, getChatWithFilesByID, id)
+	var i MentorshipChatDetail
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.SeesionID,
+		&i.Message,
+		&i.SentBy,
+		&i.SentTo,
+		&i.ReadAt,
+		&i.Pending,
+		&i.IsDeleted,
+		&i.ChatOwner,
+		&i.ChatFiles,
+		&i.ChatEdits,
+		&i.ChatReactions,
+		&i.ReplyTo,
+	)
+	return i, err
+}
+
+const getMentorSessionParticipants = `-- name: GetMentorSessionParticipants :one
+SELECT
+  s.id,
+  s.session_start,
+  s.session_end,
+  b.solver_id,
+  b.student_id,
+  b.status
+FROM mentor_session s
+JOIN mentorship_bookings b ON b.id = s.booking_id
+WHERE s.id = $1
+`
+
+type GetMentorSessionParticipantsRow struct {
+	ID           uuid.UUID     `json:"id"`
+	SessionStart time.Time     `json:"session_start"`
+	SessionEnd   time.Time     `json:"session_end"`
+	SolverID     uuid.UUID     `json:"solver_id"`
+	StudentID    uuid.UUID     `json:"student_id"`
+	Status       BookingStatus `json:"status"`
+}
+
+func (q *Queries) GetMentorSessionParticipants(ctx context.Context, id uuid.UUID) (GetMentorSessionParticipantsRow, error) {
+	row := q.db.QueryRow(ctx, getMentorSessionParticipants, id)
+	var i GetMentorSessionParticipantsRow
+	err := row.Scan(
+		&i.ID,
+		&i.SessionStart,
+		&i.SessionEnd,
+		&i.SolverID,
+		&i.StudentID,
+		&i.Status,
+	)
+	return i, err
+}
+
+const getUnreadChatCounts = `-- name: GetUnreadChatCounts :many
+SELECT seesion_id, COUNT(*)::int AS unread
+FROM mentorship_chats
+WHERE sent_to = $1
+  AND read_at IS NULL
+  AND COALESCE(is_deleted, false) = false
+GROUP BY seesion_id
+`
+
+type GetUnreadChatCountsRow struct {
+	SeesionID uuid.UUID `json:"seesion_id"`
+	Unread    int32     `json:"unread"`
+}
+
+func (q *Queries) GetUnreadChatCounts(ctx context.Context, sentTo uuid.UUID) ([]GetUnreadChatCountsRow, error) {
+	rows, err := q.db.Query(ctx, getUnreadChatCounts, sentTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnreadChatCountsRow
+	for rows.Next() {
+		var i GetUnreadChatCountsRow
+		if err := rows.Scan(&i.SeesionID, &i.Unread); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSessionChatsAfter = `-- name: ListSessionChatsAfter :many
+SELECT id, created_at, seesion_id, message, sent_by, sent_to, read_at, pending, is_deleted, chat_owner, chat_files, chat_edits, chat_reactions, reply_to
+FROM mentorship_chat_details c
+WHERE c.seesion_id = $1
+  AND (c.created_at, c.id) > ($2::pg_catalog.timestamptz, $3::uuid)
+ORDER BY c.created_at ASC, c.id ASC
+LIMIT $4
+`
+
+type ListSessionChatsAfterParams struct {
+	SessionID      uuid.UUID `json:"session_id"`
+	AfterCreatedAt time.Time `json:"after_created_at"`
+	AfterID        uuid.UUID `json:"after_id"`
+	PageSize       int32     `json:"page_size"`
+}
+
+func (q *Queries) ListSessionChatsAfter(ctx context.Context, arg ListSessionChatsAfterParams) ([]MentorshipChatDetail, error) {
+	rows, err := q.db.Query(ctx, listSessionChatsAfter,
+		arg.SessionID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MentorshipChatDetail
+	for rows.Next() {
+		var i MentorshipChatDetail
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.SeesionID,
+			&i.Message,
+			&i.SentBy,
+			&i.SentTo,
+			&i.ReadAt,
+			&i.Pending,
+			&i.IsDeleted,
+			&i.ChatOwner,
+			&i.ChatFiles,
+			&i.ChatEdits,
+			&i.ChatReactions,
+			&i.ReplyTo,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSessionChatsBefore = `-- name: ListSessionChatsBefore :many
+SELECT id, created_at, seesion_id, message, sent_by, sent_to, read_at, pending, is_deleted, chat_owner, chat_files, chat_edits, chat_reactions, reply_to
+FROM mentorship_chat_details c
+WHERE c.seesion_id = $1
+  AND (
+    $2::pg_catalog.timestamptz IS NULL
+    OR (c.created_at, c.id) < ($2::pg_catalog.timestamptz, $3::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT $4
+`
+
+type ListSessionChatsBeforeParams struct {
+	SessionID       uuid.UUID  `json:"session_id"`
+	BeforeCreatedAt *time.Time `json:"before_created_at"`
+	BeforeID        *uuid.UUID `json:"before_id"`
+	PageSize        int32      `json:"page_size"`
+}
+
+func (q *Queries) ListSessionChatsBefore(ctx context.Context, arg ListSessionChatsBeforeParams) ([]MentorshipChatDetail, error) {
+	rows, err := q.db.Query(ctx, listSessionChatsBefore,
+		arg.SessionID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MentorshipChatDetail
+	for rows.Next() {
+		var i MentorshipChatDetail
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.SeesionID,
+			&i.Message,
+			&i.SentBy,
+			&i.SentTo,
+			&i.ReadAt,
+			&i.Pending,
+			&i.IsDeleted,
+			&i.ChatOwner,
+			&i.ChatFiles,
+			&i.ChatEdits,
+			&i.ChatReactions,
+			&i.ReplyTo,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markChatsReadUpTo = `-- name: MarkChatsReadUpTo :many
+UPDATE mentorship_chats c
+SET read_at = now()
+WHERE c.seesion_id = $1
+  AND c.sent_to = $2
+  AND c.read_at IS NULL
+  AND c.created_at <= (
+    SELECT up_to.created_at FROM mentorship_chats up_to
+    WHERE up_to.id = $3 AND up_to.seesion_id = $1
+  )
+RETURNING c.id, c.read_at
+`
+
+type MarkChatsReadUpToParams struct {
+	SessionID uuid.UUID `json:"session_id"`
+	ReaderID  uuid.UUID `json:"reader_id"`
+	UpToID    uuid.UUID `json:"up_to_id"`
+}
+
+type MarkChatsReadUpToRow struct {
+	ID     uuid.UUID  `json:"id"`
+	ReadAt *time.Time `json:"read_at"`
+}
+
+func (q *Queries) MarkChatsReadUpTo(ctx context.Context, arg MarkChatsReadUpToParams) ([]MarkChatsReadUpToRow, error) {
+	rows, err := q.db.Query(ctx, markChatsReadUpTo, arg.SessionID, arg.ReaderID, arg.UpToID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MarkChatsReadUpToRow
+	for rows.Next() {
+		var i MarkChatsReadUpToRow
+		if err := rows.Scan(&i.ID, &i.ReadAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeChatReaction = `-- name: RemoveChatReaction :execrows
+DELETE FROM mentorship_chat_reactions
+WHERE chat_id = $1 AND user_id = $2 AND emoji = $3
+`
+
+type RemoveChatReactionParams struct {
+	ChatID uuid.UUID `json:"chat_id"`
+	UserID uuid.UUID `json:"user_id"`
+	Emoji  string    `json:"emoji"`
+}
+
+func (q *Queries) RemoveChatReaction(ctx context.Context, arg RemoveChatReactionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeChatReaction, arg.ChatID, arg.UserID, arg.Emoji)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const searchUserChats = `-- name: SearchUserChats :many
 SELECT
   c.id,
-  c.created_at,
   c.seesion_id,
   c.message,
   c.sent_by,
-  c.sent_to,
-  c.read_at,
-  c.pending,
-  c.is_deleted,
-
+  c.created_at,
   (
     json_build_object(
       'id', u.id,
@@ -199,63 +596,87 @@ SELECT
       'image', u.image,
       'email', u.email
     )
-  )::jsonb AS chat_owner,
-
-  COALESCE(
-    (
-      SELECT jsonb_agg(
-        jsonb_build_object(
-          'id', f.id,
-          'fileName', f.file_name,
-          'fileType', f.file_type,
-          'fileSize', f.file_size,
-          'filePath', f.file_path,
-          'uploadedAt', f.uploaded_at,
-          'uploadedById', f.uploaded_by_id,
-          'chatId', f.chat_id
-        )
-      )
-      FROM mentorship_chat_files f
-      WHERE f.chat_id = c.id
-    ),
-    '[]'::jsonb
-  )::jsonb AS chat_files
-
+  )::jsonb AS chat_owner
 FROM mentorship_chats c
 JOIN users u ON u.id = c.sent_by
-WHERE c.id = $1
-ORDER BY c.created_at ASC
+JOIN mentor_session s ON s.id = c.seesion_id
+JOIN mentorship_bookings b ON b.id = s.booking_id
+WHERE (b.solver_id = $1 OR b.student_id = $1)
+  AND ($2::uuid IS NULL OR c.seesion_id = $2::uuid)
+  AND COALESCE(c.is_deleted, false) = false
+  AND to_tsvector('simple', COALESCE(c.message, '')) @@ websearch_to_tsquery('simple', $3)
+  AND (
+    $4::pg_catalog.timestamptz IS NULL
+    OR (c.created_at, c.id) < ($4::pg_catalog.timestamptz, $5::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT $6
 `
 
-type GetChatWithFilesByIDRow struct {
+type SearchUserChatsParams struct {
+	UserID          uuid.UUID  `json:"user_id"`
+	SessionID       *uuid.UUID `json:"session_id"`
+	Query           string     `json:"query"`
+	BeforeCreatedAt *time.Time `json:"before_created_at"`
+	BeforeID        *uuid.UUID `json:"before_id"`
+	PageSize        int32      `json:"page_size"`
+}
+
+type SearchUserChatsRow struct {
 	ID        uuid.UUID  `json:"id"`
-	CreatedAt *time.Time `json:"created_at"`
 	SeesionID uuid.UUID  `json:"seesion_id"`
 	Message   *string    `json:"message"`
 	SentBy    uuid.UUID  `json:"sent_by"`
-	SentTo    uuid.UUID  `json:"sent_to"`
-	ReadAt    *time.Time `json:"read_at"`
-	Pending   *bool      `json:"pending"`
-	IsDeleted *bool      `json:"is_deleted"`
+	CreatedAt *time.Time `json:"created_at"`
 	ChatOwner []byte     `json:"chat_owner"`
-	ChatFiles []byte     `json:"chat_files"`
 }
 
-func (q *Queries) GetChatWithFilesByID(ctx context.Context, id uuid.UUID) (GetChatWithFilesByIDRow, error) {
-	row := q.db.QueryRow(ctx, getChatWithFilesByID, id)
-	var i GetChatWithFilesByIDRow
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.SeesionID,
-		&i.Message,
-		&i.SentBy,
-		&i.SentTo,
-		&i.ReadAt,
-		&i.Pending,
-		&i.IsDeleted,
-		&i.ChatOwner,
-		&i.ChatFiles,
+func (q *Queries) SearchUserChats(ctx context.Context, arg SearchUserChatsParams) ([]SearchUserChatsRow, error) {
+	rows, err := q.db.Query(ctx, searchUserChats,
+		arg.UserID,
+		arg.SessionID,
+		arg.Query,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.PageSize,
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchUserChatsRow
+	for rows.Next() {
+		var i SearchUserChatsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SeesionID,
+			&i.Message,
+			&i.SentBy,
+			&i.CreatedAt,
+			&i.ChatOwner,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChatMessage = `-- name: UpdateChatMessage :exec
+UPDATE mentorship_chats
+SET message = $2
+WHERE id = $1
+`
+
+type UpdateChatMessageParams struct {
+	ID      uuid.UUID `json:"id"`
+	Message *string   `json:"message"`
+}
+
+func (q *Queries) UpdateChatMessage(ctx context.Context, arg UpdateChatMessageParams) error {
+	_, err := q.db.Exec(ctx, updateChatMessage, arg.ID, arg.Message)
+	return err
 }
@@ -56,6 +56,95 @@ func (ns NullBookingStatus) Value() (driver.Value, error) {
 	return string(ns.BookingStatus), nil
 }
 
+type CallStatus string
+
+const (
+	CallStatusRINGING  CallStatus = "RINGING"
+	CallStatusACTIVE   CallStatus = "ACTIVE"
+	CallStatusREJECTED CallStatus = "REJECTED"
+	CallStatusMISSED   CallStatus = "MISSED"
+	CallStatusCANCELED CallStatus = "CANCELED"
+	CallStatusENDED    CallStatus = "ENDED"
+)
+
+func (e *CallStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = CallStatus(s)
+	case string:
+		*e = CallStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for CallStatus: %T", src)
+	}
+	return nil
+}
+
+type NullCallStatus struct {
+	CallStatus CallStatus `json:"call_status"`
+	Valid      bool       `json:"valid"` // Valid is true if CallStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullCallStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.CallStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.CallStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullCallStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.CallStatus), nil
+}
+
+type DigestFrequency string
+
+const (
+	DigestFrequencyHOURLY DigestFrequency = "HOURLY"
+	DigestFrequencyDAILY  DigestFrequency = "DAILY"
+	DigestFrequencyNEVER  DigestFrequency = "NEVER"
+)
+
+func (e *DigestFrequency) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DigestFrequency(s)
+	case string:
+		*e = DigestFrequency(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DigestFrequency: %T", src)
+	}
+	return nil
+}
+
+type NullDigestFrequency struct {
+	DigestFrequency DigestFrequency `json:"digest_frequency"`
+	Valid           bool            `json:"valid"` // Valid is true if DigestFrequency is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDigestFrequency) Scan(value interface{}) error {
+	if value == nil {
+		ns.DigestFrequency, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.DigestFrequency.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDigestFrequency) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.DigestFrequency), nil
+}
+
 type FeedbackCategory string
 
 const (
@@ -184,6 +273,50 @@ func (ns NullMethod) Value() (driver.Value, error) {
 	return string(ns.Method), nil
 }
 
+type NotificationChannel string
+
+const (
+	NotificationChannelINAPP  NotificationChannel = "IN_APP"
+	NotificationChannelEMAIL  NotificationChannel = "EMAIL"
+	NotificationChannelDIGEST NotificationChannel = "DIGEST"
+	NotificationChannelMUTED  NotificationChannel = "MUTED"
+)
+
+func (e *NotificationChannel) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = NotificationChannel(s)
+	case string:
+		*e = NotificationChannel(s)
+	default:
+		return fmt.Errorf("unsupported scan type for NotificationChannel: %T", src)
+	}
+	return nil
+}
+
+type NullNotificationChannel struct {
+	NotificationChannel NotificationChannel `json:"notification_channel"`
+	Valid               bool                `json:"valid"` // Valid is true if NotificationChannel is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullNotificationChannel) Scan(value interface{}) error {
+	if value == nil {
+		ns.NotificationChannel, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.NotificationChannel.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullNotificationChannel) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.NotificationChannel), nil
+}
+
 type PaymentPorpose string
 
 const (
@@ -581,6 +714,50 @@ func (ns NullTier) Value() (driver.Value, error) {
 	return string(ns.Tier), nil
 }
 
+type UploadScope string
+
+const (
+	UploadScopeTASKDRAFT UploadScope = "TASK_DRAFT"
+	UploadScopeWORKSPACE UploadScope = "WORKSPACE"
+	UploadScopeCHAT      UploadScope = "CHAT"
+	UploadScopeEDITOR    UploadScope = "EDITOR"
+)
+
+func (e *UploadScope) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UploadScope(s)
+	case string:
+		*e = UploadScope(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UploadScope: %T", src)
+	}
+	return nil
+}
+
+type NullUploadScope struct {
+	UploadScope UploadScope `json:"upload_scope"`
+	Valid       bool        `json:"valid"` // Valid is true if UploadScope is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUploadScope) Scan(value interface{}) error {
+	if value == nil {
+		ns.UploadScope, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UploadScope.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUploadScope) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.UploadScope), nil
+}
+
 type Visibility string
 
 const (
@@ -667,6 +844,16 @@ type AiTestSandbox struct {
 	UpdatedAt  *time.Time `json:"updated_at"`
 }
 
+type Announcement struct {
+	ID        uuid.UUID  `json:"id"`
+	SenderID  *uuid.UUID `json:"sender_id"`
+	Role      NullRole   `json:"role"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
 type BlockedTask struct {
 	ID        uuid.UUID `json:"id"`
 	UserID    uuid.UUID `json:"user_id"`
@@ -708,6 +895,19 @@ type Feedback struct {
 	CreatedAt       time.Time        `json:"created_at"`
 }
 
+type FileScan struct {
+	FilePath       string     `json:"file_path"`
+	UserID         *uuid.UUID `json:"user_id"`
+	Status         FileStatus `json:"status"`
+	Signature      *string    `json:"signature"`
+	Attempts       int32      `json:"attempts"`
+	LastError      *string    `json:"last_error"`
+	QuarantinePath *string    `json:"quarantine_path"`
+	ScannedAt      *time.Time `json:"scanned_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
 type Jwk struct {
 	ID         uuid.UUID  `json:"id"`
 	PublicKey  string     `json:"publicKey"`
@@ -734,6 +934,18 @@ type MentorSession struct {
 	CreatedAt    time.Time   `json:"created_at"`
 }
 
+type MentorSessionCall struct {
+	ID              uuid.UUID  `json:"id"`
+	SessionID       uuid.UUID  `json:"session_id"`
+	CallerID        uuid.UUID  `json:"caller_id"`
+	CalleeID        uuid.UUID  `json:"callee_id"`
+	Status          CallStatus `json:"status"`
+	StartedAt       time.Time  `json:"started_at"`
+	AnsweredAt      *time.Time `json:"answered_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int32      `json:"duration_seconds"`
+}
+
 type MentorshipBooking struct {
 	ID        uuid.UUID     `json:"id"`
 	SolverID  uuid.UUID     `json:"solver_id"`
@@ -757,6 +969,30 @@ type MentorshipChat struct {
 	CreatedAt *time.Time `json:"created_at"`
 }
 
+type MentorshipChatDetail struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     *time.Time `json:"created_at"`
+	SeesionID     uuid.UUID  `json:"seesion_id"`
+	Message       *string    `json:"message"`
+	SentBy        uuid.UUID  `json:"sent_by"`
+	SentTo        uuid.UUID  `json:"sent_to"`
+	ReadAt        *time.Time `json:"read_at"`
+	Pending       *bool      `json:"pending"`
+	IsDeleted     *bool      `json:"is_deleted"`
+	ChatOwner     []byte     `json:"chat_owner"`
+	ChatFiles     []byte     `json:"chat_files"`
+	ChatEdits     []byte     `json:"chat_edits"`
+	ChatReactions []byte     `json:"chat_reactions"`
+	ReplyTo       []byte     `json:"reply_to"`
+}
+
+type MentorshipChatEdit struct {
+	ID              uuid.UUID `json:"id"`
+	ChatID          uuid.UUID `json:"chat_id"`
+	PreviousMessage *string   `json:"previous_message"`
+	EditedAt        time.Time `json:"edited_at"`
+}
+
 type MentorshipChatFile struct {
 	ID           uuid.UUID  `json:"id"`
 	ChatID       uuid.UUID  `json:"chat_id"`
@@ -769,6 +1005,19 @@ type MentorshipChatFile struct {
 	UploadedAt   *time.Time `json:"uploaded_at"`
 }
 
+type MentorshipChatReaction struct {
+	ID        uuid.UUID `json:"id"`
+	ChatID    uuid.UUID `json:"chat_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MentorshipChatReply struct {
+	ChatID    uuid.UUID `json:"chat_id"`
+	ReplyToID uuid.UUID `json:"reply_to_id"`
+}
+
 type MentorshipProfile struct {
 	ID             uuid.UUID `json:"id"`
 	UserID         uuid.UUID `json:"user_id"`
@@ -784,14 +1033,35 @@ type MentorshipProfile struct {
 }
 
 type Notification struct {
-	ID         uuid.UUID  `json:"id"`
-	SenderID   string     `json:"sender_id"`
-	ReceiverID string     `json:"receiver_id"`
-	Subject    *string    `json:"subject"`
-	Content    string     `json:"content"`
-	Method     Method     `json:"method"`
-	Read       bool       `json:"read"`
-	CreatedAt  *time.Time `json:"created_at"`
+	ID           uuid.UUID  `json:"id"`
+	SenderID     string     `json:"sender_id"`
+	ReceiverID   string     `json:"receiver_id"`
+	Subject      *string    `json:"subject"`
+	Content      string     `json:"content"`
+	Method       Method     `json:"method"`
+	Read         bool       `json:"read"`
+	CreatedAt    *time.Time `json:"created_at"`
+	EventType    string     `json:"event_type"`
+	DigestedAt   *time.Time `json:"digested_at"`
+	DispatchedAt *time.Time `json:"dispatched_at"`
+}
+
+type NotificationPreference struct {
+	ID        uuid.UUID           `json:"id"`
+	UserID    uuid.UUID           `json:"user_id"`
+	EventType string              `json:"event_type"`
+	Channel   NotificationChannel `json:"channel"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+type NotificationSetting struct {
+	UserID          uuid.UUID       `json:"user_id"`
+	DigestFrequency DigestFrequency `json:"digest_frequency"`
+	Timezone        string          `json:"timezone"`
+	QuietHoursStart *int32          `json:"quiet_hours_start"`
+	QuietHoursEnd   *int32          `json:"quiet_hours_end"`
+	LastDigestAt    *time.Time      `json:"last_digest_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
 type Payment struct {
@@ -815,6 +1085,17 @@ type ProductFeedback struct {
 	CreatedAt time.Time           `json:"created_at"`
 }
 
+type PushSubscription struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Endpoint   string     `json:"endpoint"`
+	P256dh     string     `json:"p256dh"`
+	Auth       string     `json:"auth"`
+	UserAgent  *string    `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
 type Refund struct {
 	ID             uuid.UUID        `json:"id"`
 	PaymentID      uuid.UUID        `json:"payment_id"`
@@ -969,6 +1250,13 @@ type TaskDraft struct {
 	Price         int32      `json:"price"`
 }
 
+type TaskFeedSubscription struct {
+	UserID      uuid.UUID   `json:"user_id"`
+	CategoryIds []uuid.UUID `json:"category_ids"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
 type TaskFile struct {
 	ID         uuid.UUID  `json:"id"`
 	TaskID     uuid.UUID  `json:"task_id"`
@@ -979,6 +1267,38 @@ type TaskFile struct {
 	UploadedAt *time.Time `json:"uploaded_at"`
 }
 
+type TusUpload struct {
+	ID           uuid.UUID   `json:"id"`
+	UserID       uuid.UUID   `json:"user_id"`
+	Scope        UploadScope `json:"scope"`
+	TargetID     *uuid.UUID  `json:"target_id"`
+	FileName     string      `json:"file_name"`
+	FileType     string      `json:"file_type"`
+	FileSize     int32       `json:"file_size"`
+	FilePath     string      `json:"file_path"`
+	MultipartID  string      `json:"multipart_id"`
+	UploadOffset int32       `json:"upload_offset"`
+	Parts        []byte      `json:"parts"`
+	TailSize     int32       `json:"tail_size"`
+	ExpiresAt    time.Time   `json:"expires_at"`
+	CompletedAt  *time.Time  `json:"completed_at"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+type UploadIntent struct {
+	ID          uuid.UUID   `json:"id"`
+	UserID      uuid.UUID   `json:"user_id"`
+	Scope       UploadScope `json:"scope"`
+	TargetID    *uuid.UUID  `json:"target_id"`
+	FileName    string      `json:"file_name"`
+	FileType    string      `json:"file_type"`
+	FileSize    int32       `json:"file_size"`
+	FilePath    string      `json:"file_path"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+	ConfirmedAt *time.Time  `json:"confirmed_at"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
 type User struct {
 	ID               uuid.UUID `json:"id"`
 	Name             string    `json:"name"`
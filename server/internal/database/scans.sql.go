@@ -0,0 +1,191 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: scans.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimFileScans = `-- name: ClaimFileScans :many
+UPDATE file_scans
+SET status = 'PROCESSING', updated_at = now()
+WHERE file_path IN (
+  SELECT pending.file_path FROM file_scans pending
+  WHERE pending.status = 'PENDING'
+     OR (pending.status = 'PROCESSING' AND pending.updated_at < $1)
+  ORDER BY pending.created_at
+  LIMIT $2
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING file_path, user_id, status, signature, attempts, last_error, quarantine_path, scanned_at, created_at, updated_at
+`
+
+type ClaimFileScansParams struct {
+	StaleBefore time.Time `json:"stale_before"`
+	BatchSize   int32     `json:"batch_size"`
+}
+
+// PENDING files, and the PROCESSING ones of a worker that died mid scan
+func (q *Queries) ClaimFileScans(ctx context.Context, arg ClaimFileScansParams) ([]FileScan, error) {
+	rows, err := q.db.Query(ctx, claimFileScans, arg.StaleBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FileScan
+	for rows.Next() {
+		var i FileScan
+		if err := rows.Scan(
+			&i.FilePath,
+			&i.UserID,
+			&i.Status,
+			&i.Signature,
+			&i.Attempts,
+			&i.LastError,
+			&i.QuarantinePath,
+			&i.ScannedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeFileScan = `-- name: CompleteFileScan :exec
+UPDATE file_scans
+SET status = 'COMPLETED', last_error = NULL, scanned_at = now(), updated_at = now()
+WHERE file_path = $1
+`
+
+func (q *Queries) CompleteFileScan(ctx context.Context, filePath string) error {
+	_, err := q.db.Exec(ctx, completeFileScan, filePath)
+	return err
+}
+
+const createFileScan = `-- name: CreateFileScan :exec
+INSERT INTO file_scans (file_path, user_id)
+VALUES ($1, $2)
+ON CONFLICT (file_path) DO NOTHING
+`
+
+type CreateFileScanParams struct {
+	FilePath string     `json:"file_path"`
+	UserID   *uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) CreateFileScan(ctx context.Context, arg CreateFileScanParams) error {
+	_, err := q.db.Exec(ctx, createFileScan, arg.FilePath, arg.UserID)
+	return err
+}
+
+const deleteFileScan = `-- name: DeleteFileScan :exec
+DELETE FROM file_scans WHERE file_path = $1 AND quarantine_path IS NULL
+`
+
+func (q *Queries) DeleteFileScan(ctx context.Context, filePath string) error {
+	_, err := q.db.Exec(ctx, deleteFileScan, filePath)
+	return err
+}
+
+const failFileScan = `-- name: FailFileScan :exec
+UPDATE file_scans
+SET status = 'FAILED', signature = $2, last_error = $3, quarantine_path = $4,
+    scanned_at = now(), updated_at = now()
+WHERE file_path = $1
+`
+
+type FailFileScanParams struct {
+	FilePath       string  `json:"file_path"`
+	Signature      *string `json:"signature"`
+	LastError      *string `json:"last_error"`
+	QuarantinePath *string `json:"quarantine_path"`
+}
+
+func (q *Queries) FailFileScan(ctx context.Context, arg FailFileScanParams) error {
+	_, err := q.db.Exec(ctx, failFileScan,
+		arg.FilePath,
+		arg.Signature,
+		arg.LastError,
+		arg.QuarantinePath,
+	)
+	return err
+}
+
+const getAllQuarantinedFilePaths = `-- name: GetAllQuarantinedFilePaths :many
+SELECT quarantine_path::text FROM file_scans WHERE quarantine_path IS NOT NULL
+`
+
+func (q *Queries) GetAllQuarantinedFilePaths(ctx context.Context) ([]string, error) {
+	rows, err := q.db.Query(ctx, getAllQuarantinedFilePaths)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var quarantine_path string
+		if err := rows.Scan(&quarantine_path); err != nil {
+			return nil, err
+		}
+		items = append(items, quarantine_path)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFileScanStatus = `-- name: GetFileScanStatus :one
+SELECT status FROM file_scans WHERE file_path = $1
+`
+
+func (q *Queries) GetFileScanStatus(ctx context.Context, filePath string) (FileStatus, error) {
+	row := q.db.QueryRow(ctx, getFileScanStatus, filePath)
+	var status FileStatus
+	err := row.Scan(&status)
+	return status, err
+}
+
+const retryFileScan = `-- name: RetryFileScan :exec
+UPDATE file_scans
+SET status = 'PENDING', attempts = attempts + 1, last_error = $2, updated_at = now()
+WHERE file_path = $1
+`
+
+type RetryFileScanParams struct {
+	FilePath  string  `json:"file_path"`
+	LastError *string `json:"last_error"`
+}
+
+func (q *Queries) RetryFileScan(ctx context.Context, arg RetryFileScanParams) error {
+	_, err := q.db.Exec(ctx, retryFileScan, arg.FilePath, arg.LastError)
+	return err
+}
+
+const setWorkspaceFileStatus = `-- name: SetWorkspaceFileStatus :exec
+UPDATE solution_workspace_files
+SET status = $2::file_status, updated_at = now()
+WHERE file_path = $1
+`
+
+type SetWorkspaceFileStatusParams struct {
+	FilePath string     `json:"file_path"`
+	Status   FileStatus `json:"status"`
+}
+
+func (q *Queries) SetWorkspaceFileStatus(ctx context.Context, arg SetWorkspaceFileStatusParams) error {
+	_, err := q.db.Exec(ctx, setWorkspaceFileStatus, arg.FilePath, arg.Status)
+	return err
+}
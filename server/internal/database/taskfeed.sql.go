@@ -0,0 +1,247 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: taskfeed.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const countTaskCategories = `-- name: CountTaskCategories :one
+SELECT count(*)
+FROM task_categories
+WHERE id = ANY($1::uuid [ ])
+`
+
+func (q *Queries) CountTaskCategories(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countTaskCategories, ids)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteTaskFeedSubscription = `-- name: DeleteTaskFeedSubscription :execrows
+DELETE FROM task_feed_subscriptions
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteTaskFeedSubscription(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTaskFeedSubscription, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getFeedTask = `-- name: GetFeedTask :one
+SELECT t.id,
+  t.title,
+  t.description,
+  t.price,
+  t.deadline,
+  t.poster_id,
+  t.category_id,
+  c.name AS category_name,
+  t.created_at
+FROM tasks t
+  JOIN task_categories c ON c.id = t.category_id
+WHERE t.id = $1
+  AND t.task_status = 'OPEN'
+  AND t.visibility = 'public'
+`
+
+type GetFeedTaskRow struct {
+	ID           uuid.UUID `json:"id"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Price        int32     `json:"price"`
+	Deadline     string    `json:"deadline"`
+	PosterID     uuid.UUID `json:"poster_id"`
+	CategoryID   uuid.UUID `json:"category_id"`
+	CategoryName string    `json:"category_name"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (q *Queries) GetFeedTask(ctx context.Context, id uuid.UUID) (GetFeedTaskRow, error) {
+	row := q.db.QueryRow(ctx, getFeedTask, id)
+	var i GetFeedTaskRow
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Description,
+		&i.Price,
+		&i.Deadline,
+		&i.PosterID,
+		&i.CategoryID,
+		&i.CategoryName,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTaskFeedSubscription = `-- name: GetTaskFeedSubscription :one
+SELECT user_id, category_ids, created_at, updated_at
+FROM task_feed_subscriptions
+WHERE user_id = $1
+`
+
+func (q *Queries) GetTaskFeedSubscription(ctx context.Context, userID uuid.UUID) (TaskFeedSubscription, error) {
+	row := q.db.QueryRow(ctx, getTaskFeedSubscription, userID)
+	var i TaskFeedSubscription
+	err := row.Scan(
+		&i.UserID,
+		&i.CategoryIds,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listNewFeedTasks = `-- name: ListNewFeedTasks :many
+SELECT t.id,
+  t.title,
+  t.description,
+  t.price,
+  t.deadline,
+  t.poster_id,
+  t.category_id,
+  c.name AS category_name,
+  t.created_at
+FROM tasks t
+  JOIN task_categories c ON c.id = t.category_id
+WHERE t.created_at > $1
+  AND t.task_status = 'OPEN'
+  AND t.visibility = 'public'
+ORDER BY t.created_at
+`
+
+type ListNewFeedTasksRow struct {
+	ID           uuid.UUID `json:"id"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Price        int32     `json:"price"`
+	Deadline     string    `json:"deadline"`
+	PosterID     uuid.UUID `json:"poster_id"`
+	CategoryID   uuid.UUID `json:"category_id"`
+	CategoryName string    `json:"category_name"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Open public tasks created since created_after, for the publish job to
+// announce whichever were not announced yet.
+func (q *Queries) ListNewFeedTasks(ctx context.Context, createdAfter time.Time) ([]ListNewFeedTasksRow, error) {
+	rows, err := q.db.Query(ctx, listNewFeedTasks, createdAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNewFeedTasksRow
+	for rows.Next() {
+		var i ListNewFeedTasksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.Price,
+			&i.Deadline,
+			&i.PosterID,
+			&i.CategoryID,
+			&i.CategoryName,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTaskFeedRecipients = `-- name: ListTaskFeedRecipients :many
+SELECT s.user_id
+FROM tasks t
+  JOIN task_categories c ON c.id = t.category_id
+  JOIN task_feed_subscriptions s ON cardinality(s.category_ids) = 0
+  OR t.category_id = ANY(s.category_ids)
+  JOIN users u ON u.id = s.user_id
+  LEFT JOIN solver_profile p ON p.user_id = s.user_id
+WHERE t.id = $1
+  AND t.task_status = 'OPEN'
+  AND t.visibility = 'public'
+  AND u.role = 'SOLVER'
+  AND s.user_id <> t.poster_id
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocked_tasks b
+    WHERE b.task_id = t.id
+      AND b.user_id = s.user_id
+  )
+  AND (
+    COALESCE(cardinality(p.skills), 0) = 0
+    OR EXISTS (
+      SELECT 1
+      FROM unnest(p.skills) AS skill
+      WHERE skill <> ''
+        AND position(
+          lower(skill) IN lower(c.name || ' ' || t.title || ' ' || t.description)
+        ) > 0
+    )
+  )
+`
+
+// Subscribed solvers whose categories and skills match an open public task.
+// Solvers without categories take every category, and those without skills
+// every task of them. A skill matches when the category, title or
+// description mentions it.
+func (q *Queries) ListTaskFeedRecipients(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.Query(ctx, listTaskFeedRecipients, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertTaskFeedSubscription = `-- name: UpsertTaskFeedSubscription :one
+INSERT INTO task_feed_subscriptions (user_id, category_ids)
+VALUES ($1, $2) ON CONFLICT (user_id) DO
+UPDATE
+SET category_ids = EXCLUDED.category_ids,
+  updated_at = NOW()
+RETURNING user_id, category_ids, created_at, updated_at
+`
+
+type UpsertTaskFeedSubscriptionParams struct {
+	UserID      uuid.UUID   `json:"user_id"`
+	CategoryIds []uuid.UUID `json:"category_ids"`
+}
+
+func (q *Queries) UpsertTaskFeedSubscription(ctx context.Context, arg UpsertTaskFeedSubscriptionParams) (TaskFeedSubscription, error) {
+	row := q.db.QueryRow(ctx, upsertTaskFeedSubscription, arg.UserID, arg.CategoryIds)
+	var i TaskFeedSubscription
+	err := row.Scan(
+		&i.UserID,
+		&i.CategoryIds,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
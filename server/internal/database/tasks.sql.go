@@ -122,6 +122,31 @@ func (q *Queries) GetAvailbleTasks(ctx context.Context, limit int32) ([]Task, er
 	return items, nil
 }
 
+const getTaskAccess = `-- name: GetTaskAccess :one
+SELECT id, poster_id, solver_id, visibility
+FROM tasks
+WHERE id = $1
+`
+
+type GetTaskAccessRow struct {
+	ID         uuid.UUID  `json:"id"`
+	PosterID   uuid.UUID  `json:"poster_id"`
+	SolverID   *uuid.UUID `json:"solver_id"`
+	Visibility Visibility `json:"visibility"`
+}
+
+func (q *Queries) GetTaskAccess(ctx context.Context, id uuid.UUID) (GetTaskAccessRow, error) {
+	row := q.db.QueryRow(ctx, getTaskAccess, id)
+	var i GetTaskAccessRow
+	err := row.Scan(
+		&i.ID,
+		&i.PosterID,
+		&i.SolverID,
+		&i.Visibility,
+	)
+	return i, err
+}
+
 const getTaskCategories = `-- name: GetTaskCategories :many
 SELECT name
 FROM task_categories
@@ -0,0 +1,282 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: tus.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const advanceTusUpload = `-- name: AdvanceTusUpload :one
+UPDATE tus_uploads
+SET upload_offset = $1,
+    parts = $2,
+    tail_size = $3,
+    expires_at = $4
+WHERE id = $5
+  AND upload_offset = $6
+  AND completed_at IS NULL
+RETURNING id, user_id, scope, target_id, file_name, file_type, file_size, file_path, multipart_id, upload_offset, parts, tail_size, expires_at, completed_at, created_at
+`
+
+type AdvanceTusUploadParams struct {
+	UploadOffset   int32     `json:"upload_offset"`
+	Parts          []byte    `json:"parts"`
+	TailSize       int32     `json:"tail_size"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	ID             uuid.UUID `json:"id"`
+	PreviousOffset int32     `json:"previous_offset"`
+}
+
+func (q *Queries) AdvanceTusUpload(ctx context.Context, arg AdvanceTusUploadParams) (TusUpload, error) {
+	row := q.db.QueryRow(ctx, advanceTusUpload,
+		arg.UploadOffset,
+		arg.Parts,
+		arg.TailSize,
+		arg.ExpiresAt,
+		arg.ID,
+		arg.PreviousOffset,
+	)
+	var i TusUpload
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scope,
+		&i.TargetID,
+		&i.FileName,
+		&i.FileType,
+		&i.FileSize,
+		&i.FilePath,
+		&i.MultipartID,
+		&i.UploadOffset,
+		&i.Parts,
+		&i.TailSize,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const completeTusUpload = `-- name: CompleteTusUpload :one
+UPDATE tus_uploads
+SET upload_offset = file_size,
+    tail_size = 0,
+    completed_at = now()
+WHERE id = $1
+  AND completed_at IS NULL
+RETURNING id, user_id, scope, target_id, file_name, file_type, file_size, file_path, multipart_id, upload_offset, parts, tail_size, expires_at, completed_at, created_at
+`
+
+func (q *Queries) CompleteTusUpload(ctx context.Context, id uuid.UUID) (TusUpload, error) {
+	row := q.db.QueryRow(ctx, completeTusUpload, id)
+	var i TusUpload
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scope,
+		&i.TargetID,
+		&i.FileName,
+		&i.FileType,
+		&i.FileSize,
+		&i.FilePath,
+		&i.MultipartID,
+		&i.UploadOffset,
+		&i.Parts,
+		&i.TailSize,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createTusUpload = `-- name: CreateTusUpload :one
+INSERT INTO tus_uploads (
+  user_id,
+  scope,
+  target_id,
+  file_name,
+  file_type,
+  file_size,
+  file_path,
+  multipart_id,
+  expires_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, user_id, scope, target_id, file_name, file_type, file_size, file_path, multipart_id, upload_offset, parts, tail_size, expires_at, completed_at, created_at
+`
+
+type CreateTusUploadParams struct {
+	UserID      uuid.UUID   `json:"user_id"`
+	Scope       UploadScope `json:"scope"`
+	TargetID    *uuid.UUID  `json:"target_id"`
+	FileName    string      `json:"file_name"`
+	FileType    string      `json:"file_type"`
+	FileSize    int32       `json:"file_size"`
+	FilePath    string      `json:"file_path"`
+	MultipartID string      `json:"multipart_id"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+}
+
+func (q *Queries) CreateTusUpload(ctx context.Context, arg CreateTusUploadParams) (TusUpload, error) {
+	row := q.db.QueryRow(ctx, createTusUpload,
+		arg.UserID,
+		arg.Scope,
+		arg.TargetID,
+		arg.FileName,
+		arg.FileType,
+		arg.FileSize,
+		arg.FilePath,
+		arg.MultipartID,
+		arg.ExpiresAt,
+	)
+	var i TusUpload
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scope,
+		&i.TargetID,
+		&i.FileName,
+		&i.FileType,
+		&i.FileSize,
+		&i.FilePath,
+		&i.MultipartID,
+		&i.UploadOffset,
+		&i.Parts,
+		&i.TailSize,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteTusUpload = `-- name: DeleteTusUpload :exec
+DELETE FROM tus_uploads
+WHERE id = $1
+`
+
+func (q *Queries) DeleteTusUpload(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteTusUpload, id)
+	return err
+}
+
+const getAllPendingTusFilePaths = `-- name: GetAllPendingTusFilePaths :many
+SELECT file_path FROM tus_uploads
+WHERE completed_at IS NULL
+UNION ALL
+SELECT file_path || '.part' FROM tus_uploads
+WHERE completed_at IS NULL
+  AND tail_size > 0
+`
+
+func (q *Queries) GetAllPendingTusFilePaths(ctx context.Context) ([]string, error) {
+	rows, err := q.db.Query(ctx, getAllPendingTusFilePaths)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var file_path string
+		if err := rows.Scan(&file_path); err != nil {
+			return nil, err
+		}
+		items = append(items, file_path)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTusUpload = `-- name: GetTusUpload :one
+SELECT id, user_id, scope, target_id, file_name, file_type, file_size, file_path, multipart_id, upload_offset, parts, tail_size, expires_at, completed_at, created_at FROM tus_uploads
+WHERE id = $1
+`
+
+func (q *Queries) GetTusUpload(ctx context.Context, id uuid.UUID) (TusUpload, error) {
+	row := q.db.QueryRow(ctx, getTusUpload, id)
+	var i TusUpload
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scope,
+		&i.TargetID,
+		&i.FileName,
+		&i.FileType,
+		&i.FileSize,
+		&i.FilePath,
+		&i.MultipartID,
+		&i.UploadOffset,
+		&i.Parts,
+		&i.TailSize,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listExpiredTusUploads = `-- name: ListExpiredTusUploads :many
+SELECT id, user_id, scope, target_id, file_name, file_type, file_size, file_path, multipart_id, upload_offset, parts, tail_size, expires_at, completed_at, created_at FROM tus_uploads
+WHERE completed_at IS NULL
+  AND expires_at <= now()
+ORDER BY expires_at
+LIMIT $1
+`
+
+func (q *Queries) ListExpiredTusUploads(ctx context.Context, limit int32) ([]TusUpload, error) {
+	rows, err := q.db.Query(ctx, listExpiredTusUploads, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TusUpload
+	for rows.Next() {
+		var i TusUpload
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Scope,
+			&i.TargetID,
+			&i.FileName,
+			&i.FileType,
+			&i.FileSize,
+			&i.FilePath,
+			&i.MultipartID,
+			&i.UploadOffset,
+			&i.Parts,
+			&i.TailSize,
+			&i.ExpiresAt,
+			&i.CompletedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneCompletedTusUploads = `-- name: PruneCompletedTusUploads :execrows
+DELETE FROM tus_uploads
+WHERE completed_at IS NOT NULL
+  AND completed_at < $1
+`
+
+func (q *Queries) PruneCompletedTusUploads(ctx context.Context, completedAt *time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, pruneCompletedTusUploads, completedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,230 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: uploads.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const confirmUploadIntent = `-- name: ConfirmUploadIntent :one
+UPDATE upload_intents
+SET confirmed_at = now()
+WHERE id = $1
+  AND confirmed_at IS NULL
+  AND expires_at > now()
+RETURNING id, user_id, scope, target_id, file_name, file_type, file_size, file_path, expires_at, confirmed_at, created_at
+`
+
+func (q *Queries) ConfirmUploadIntent(ctx context.Context, id uuid.UUID) (UploadIntent, error) {
+	row := q.db.QueryRow(ctx, confirmUploadIntent, id)
+	var i UploadIntent
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scope,
+		&i.TargetID,
+		&i.FileName,
+		&i.FileType,
+		&i.FileSize,
+		&i.FilePath,
+		&i.ExpiresAt,
+		&i.ConfirmedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createUploadIntent = `-- name: CreateUploadIntent :one
+INSERT INTO upload_intents (
+  user_id,
+  scope,
+  target_id,
+  file_name,
+  file_type,
+  file_size,
+  file_path,
+  expires_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, user_id, scope, target_id, file_name, file_type, file_size, file_path, expires_at, confirmed_at, created_at
+`
+
+type CreateUploadIntentParams struct {
+	UserID    uuid.UUID   `json:"user_id"`
+	Scope     UploadScope `json:"scope"`
+	TargetID  *uuid.UUID  `json:"target_id"`
+	FileName  string      `json:"file_name"`
+	FileType  string      `json:"file_type"`
+	FileSize  int32       `json:"file_size"`
+	FilePath  string      `json:"file_path"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+func (q *Queries) CreateUploadIntent(ctx context.Context, arg CreateUploadIntentParams) (UploadIntent, error) {
+	row := q.db.QueryRow(ctx, createUploadIntent,
+		arg.UserID,
+		arg.Scope,
+		arg.TargetID,
+		arg.FileName,
+		arg.FileType,
+		arg.FileSize,
+		arg.FilePath,
+		arg.ExpiresAt,
+	)
+	var i UploadIntent
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scope,
+		&i.TargetID,
+		&i.FileName,
+		&i.FileType,
+		&i.FileSize,
+		&i.FilePath,
+		&i.ExpiresAt,
+		&i.ConfirmedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteUploadIntent = `-- name: DeleteUploadIntent :exec
+DELETE FROM upload_intents
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUploadIntent(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUploadIntent, id)
+	return err
+}
+
+const getAllPendingUploadFilePaths = `-- name: GetAllPendingUploadFilePaths :many
+SELECT file_path FROM upload_intents
+WHERE confirmed_at IS NULL
+`
+
+func (q *Queries) GetAllPendingUploadFilePaths(ctx context.Context) ([]string, error) {
+	rows, err := q.db.Query(ctx, getAllPendingUploadFilePaths)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var file_path string
+		if err := rows.Scan(&file_path); err != nil {
+			return nil, err
+		}
+		items = append(items, file_path)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUploadIntent = `-- name: GetUploadIntent :one
+SELECT id, user_id, scope, target_id, file_name, file_type, file_size, file_path, expires_at, confirmed_at, created_at FROM upload_intents
+WHERE id = $1
+`
+
+func (q *Queries) GetUploadIntent(ctx context.Context, id uuid.UUID) (UploadIntent, error) {
+	row := q.db.QueryRow(ctx, getUploadIntent, id)
+	var i UploadIntent
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Scope,
+		&i.TargetID,
+		&i.FileName,
+		&i.FileType,
+		&i.FileSize,
+		&i.FilePath,
+		&i.ExpiresAt,
+		&i.ConfirmedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWorkspaceSolver = `-- name: GetWorkspaceSolver :one
+SELECT solver_id FROM solution_workspaces
+WHERE id = $1
+`
+
+func (q *Queries) GetWorkspaceSolver(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceSolver, id)
+	var solver_id uuid.UUID
+	err := row.Scan(&solver_id)
+	return solver_id, err
+}
+
+const listExpiredUploadIntents = `-- name: ListExpiredUploadIntents :many
+SELECT id, user_id, scope, target_id, file_name, file_type, file_size, file_path, expires_at, confirmed_at, created_at FROM upload_intents
+WHERE confirmed_at IS NULL
+  AND expires_at <= now()
+ORDER BY expires_at
+LIMIT $1
+`
+
+func (q *Queries) ListExpiredUploadIntents(ctx context.Context, limit int32) ([]UploadIntent, error) {
+	rows, err := q.db.Query(ctx, listExpiredUploadIntents, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UploadIntent
+	for rows.Next() {
+		var i UploadIntent
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Scope,
+			&i.TargetID,
+			&i.FileName,
+			&i.FileType,
+			&i.FileSize,
+			&i.FilePath,
+			&i.ExpiresAt,
+			&i.ConfirmedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneConfirmedUploadIntents = `-- name: PruneConfirmedUploadIntents :execrows
+DELETE FROM upload_intents
+WHERE confirmed_at IS NOT NULL
+  AND confirmed_at < $1
+`
+
+func (q *Queries) PruneConfirmedUploadIntents(ctx context.Context, confirmedAt *time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, pruneConfirmedUploadIntents, confirmedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const releaseUploadIntent = `-- name: ReleaseUploadIntent :exec
+UPDATE upload_intents
+SET confirmed_at = NULL
+WHERE id = $1
+`
+
+func (q *Queries) ReleaseUploadIntent(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, releaseUploadIntent, id)
+	return err
+}
@@ -36,6 +36,34 @@ func (q *Queries) AddSolverToTaskBlockList(ctx context.Context, arg AddSolverToT
 	return i, err
 }
 
+const getUserContact = `-- name: GetUserContact :one
+SELECT id,
+  name,
+  email,
+  role
+FROM users
+WHERE id = $1
+`
+
+type GetUserContactRow struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Role  Role      `json:"role"`
+}
+
+func (q *Queries) GetUserContact(ctx context.Context, id uuid.UUID) (GetUserContactRow, error) {
+	row := q.db.QueryRow(ctx, getUserContact, id)
+	var i GetUserContactRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Role,
+	)
+	return i, err
+}
+
 const getUsers = `-- name: GetUsers :many
 SELECT id, name, email, password, role, stripe_customer_id, stripe_account_id, email_verified, image, created_at, updated_at, metadata
 FROM users
@@ -81,10 +109,12 @@ INSERT INTO notifications (
     subject,
     content,
     method,
-    read
+    read,
+    event_type,
+    dispatched_at
   )
-VALUES ($1, $2, $3, $4, 'SYSTEM', $5)
-RETURNING id, sender_id, receiver_id, subject, content, method, read, created_at
+VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+RETURNING id, sender_id, receiver_id, subject, content, method, read, created_at, event_type, digested_at, dispatched_at
 `
 
 type ProcessSystemNotificationParams struct {
@@ -92,7 +122,9 @@ type ProcessSystemNotificationParams struct {
 	ReceiverID string  `json:"receiver_id"`
 	Subject    *string `json:"subject"`
 	Content    string  `json:"content"`
+	Method     Method  `json:"method"`
 	Read       bool    `json:"read"`
+	EventType  string  `json:"event_type"`
 }
 
 func (q *Queries) ProcessSystemNotification(ctx context.Context, arg ProcessSystemNotificationParams) (Notification, error) {
@@ -101,7 +133,9 @@ func (q *Queries) ProcessSystemNotification(ctx context.Context, arg ProcessSyst
 		arg.ReceiverID,
 		arg.Subject,
 		arg.Content,
+		arg.Method,
 		arg.Read,
+		arg.EventType,
 	)
 	var i Notification
 	err := row.Scan(
@@ -113,6 +147,9 @@ func (q *Queries) ProcessSystemNotification(ctx context.Context, arg ProcessSyst
 		&i.Method,
 		&i.Read,
 		&i.CreatedAt,
+		&i.EventType,
+		&i.DigestedAt,
+		&i.DispatchedAt,
 	)
 	return i, err
 }
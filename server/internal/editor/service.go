@@ -3,14 +3,18 @@ package editor
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github/abdallemo/solveit-saas/internal/database"
 	"github/abdallemo/solveit-saas/internal/file"
 	"log"
 	"mime/multipart"
+	"net/url"
 
 	"github.com/google/uuid"
 )
 
+var ErrUploadFailed = errors.New("failed to upload")
+
 type Service struct {
 	store       *database.Queries
 	fileService *file.Service
@@ -32,34 +36,41 @@ type editorFileResp struct {
 	Url      string `json:"url"`
 }
 
-func (s *Service) CreateEditorFiles(ctx context.Context, files []*multipart.FileHeader, scope string) (editorFileResp, error) {
+func (s *Service) CreateEditorFiles(ctx context.Context, userID uuid.UUID, files []*multipart.FileHeader, scope string) (editorFileResp, error) {
 	id := uuid.New()
 
-	uploaded, failed := s.fileService.ProcessBatchUpload(files, scope, id)
+	uploaded, failed := s.fileService.ProcessBatchUpload(files, scope, id, userID)
 
 	if len(failed) > 0 {
-		return editorFileResp{}, errors.New("failed to upload")
+		return editorFileResp{}, fmt.Errorf("%w: %s", ErrUploadFailed, failed[0].Error)
+	}
+	if len(uploaded) == 0 {
+		return editorFileResp{}, fmt.Errorf("%w: no file sent", ErrUploadFailed)
 	}
 
+	return s.AddEditorFile(ctx, uploaded[0])
+}
+
+// AddEditorFile records a stored editor image and returns the media path
+// it is served at once its malware scan passed
+func (s *Service) AddEditorFile(ctx context.Context, uploaded file.FileMeta) (editorFileResp, error) {
 	editorFile, err := s.store.CreateEditorFile(ctx, database.CreateEditorFileParams{
-		FileName: uploaded[0].FileName,
-		FileType: uploaded[0].FilePath,
-		FilePath: uploaded[0].FilePath,
-		FileSize: int32(uploaded[0].FileSize),
+		FileName: uploaded.FileName,
+		FileType: uploaded.FileType,
+		FilePath: uploaded.FilePath,
+		FileSize: int32(uploaded.FileSize),
 	})
 
 	if err != nil {
 		return editorFileResp{}, errors.New("failed to upload")
 	}
 
-	presinged, err := s.fileService.GetPresignedURL(ctx, editorFile.FilePath)
-
-	if err != nil {
-		return editorFileResp{}, errors.New("failed to fetch")
-	}
-
-	return editorFileResp{FileName: editorFile.FileName, FilePath: editorFile.FilePath, Url: presinged.Url}, nil
-
+	// no presigned url here, the file is not scanned yet
+	return editorFileResp{
+		FileName: editorFile.FileName,
+		FilePath: editorFile.FilePath,
+		Url:      "/media/" + url.PathEscape(editorFile.FilePath),
+	}, nil
 }
 
 // Editor Resource
@@ -72,9 +83,9 @@ func (s *Service) DeleteEditorFile(ctx context.Context, filePath string) error {
 		return errors.New("failed to delete")
 	}
 
-	err = s.fileService.DeleteFromS3(filePath)
+	err = s.fileService.DeleteFile(filePath)
 	if err != nil {
-		log.Printf("WARNING: DB deleted but S3 failed for %s. Error: %s", filePath, err.Error())
+		log.Printf("WARNING: DB deleted but storage failed for %s. Error: %s", filePath, err.Error())
 	}
 
 	return nil
@@ -2,24 +2,40 @@ package file
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"time"
 
 	"mime/multipart"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/storage"
+
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxFileSize is the largest file the server stores, in bytes
+const MaxFileSize = 50 << 20
+
+var (
+	ErrScanPending = errors.New("file is still being scanned")
+	ErrScanFailed  = errors.New("file did not pass the malware scan")
 )
 
 type Service struct {
-	s3Client *s3.Client
+	store   *database.Queries
+	storage storage.Storage
+	policy  Policy
 }
 
-func NewService(s3Client *s3.Client) *Service {
+func NewService(store *database.Queries, objectStorage storage.Storage, policy Policy) *Service {
 	return &Service{
-		s3Client: s3Client,
+		store:   store,
+		storage: objectStorage,
+		policy:  policy,
 	}
 }
 
@@ -42,6 +58,8 @@ type DownloadedFile struct {
 	Body          io.ReadCloser
 	ContentType   string
 	ContentLength int64
+	TotalSize     int64          // size of the whole file, ContentLength for a range of it
+	Range         *storage.Range // nil when Body is the whole file
 }
 
 type FileBatch struct {
@@ -82,49 +100,70 @@ func NewFileBatch(files []FileMeta) FileBatch {
 	return batch
 }
 
-func (s *Service) DeleteFromS3(filePth string) error {
-	_, err := s.s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-		Bucket: aws.String("solveit"),
-		Key:    aws.String(filePth),
+func (s *Service) DeleteFile(filePth string) error {
+	if err := s.storage.Delete(context.TODO(), filePth); err != nil {
+		return err
+	}
+	return s.store.DeleteFileScan(context.TODO(), filePth)
+}
+
+// TrackScan queues a stored file for the malware scan, it is not served
+// before the scan passed
+func (s *Service) TrackScan(ctx context.Context, uploadedBy uuid.UUID, key string) error {
+	return s.store.CreateFileScan(ctx, database.CreateFileScanParams{
+		FilePath: key,
+		UserID:   &uploadedBy,
 	})
+}
 
+// CheckScanned tells whether the file passed the malware scan, a file the
+// scanner never saw is treated as missing
+func (s *Service) CheckScanned(ctx context.Context, key string) error {
+	status, err := s.store.GetFileScanStatus(ctx, key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	}
 	if err != nil {
-		//http.Error(w, "Error Deleting from S3: "+err.Error(), http.StatusBadRequest)
 		return err
 	}
-	return nil
-}
 
-// GetFile
-func (s *Service) GetFile(ctx context.Context, key string) (*DownloadedFile, error) {
+	switch status {
+	case database.FileStatusCOMPLETED:
+		return nil
+	case database.FileStatusFAILED:
+		return ErrScanFailed
+	default:
+		return ErrScanPending
+	}
+}
 
-	obj, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String("solveit"),
-		Key:    aws.String(key),
-	})
+// GetFile reads a stored file, or only rng of it when rng is not nil
+func (s *Service) GetFile(ctx context.Context, key string, rng *storage.Range) (*DownloadedFile, error) {
+	obj, err := s.storage.Get(ctx, key, rng)
 	if err != nil {
 		return nil, err
 	}
 
 	contentType := "application/octet-stream"
-	if obj.ContentType != nil {
-		contentType = *obj.ContentType
+	if obj.ContentType != "" {
+		contentType = obj.ContentType
 	}
 
-	var contentLength int64
-	if obj.ContentLength != nil {
-		contentLength = *obj.ContentLength
-	}
-
-	return &DownloadedFile{
-		Body:          obj.Body,
+	file := &DownloadedFile{
+		Body:          obj.ReadCloser,
 		ContentType:   contentType,
-		ContentLength: contentLength,
-	}, nil
+		ContentLength: obj.Size,
+		TotalSize:     obj.Size,
+		Range:         obj.Range,
+	}
+	if obj.Range != nil {
+		file.ContentLength = obj.Range.End - obj.Range.Start + 1
+	}
+	return file, nil
 }
 
 func (s *Service) ProcessBatchUpload(files []*multipart.FileHeader,
-	scope string, id uuid.UUID) ([]FileMeta, []FailedFileError) {
+	scope string, id, uploadedBy uuid.UUID) ([]FileMeta, []FailedFileError) {
 	uploaded := []FileMeta{}
 	failed := []FailedFileError{}
 
@@ -137,7 +176,14 @@ func (s *Service) ProcessBatchUpload(files []*multipart.FileHeader,
 			continue
 		}
 
-		key, err := s.UploadFileToS3(fileHeader, scope, id)
+		meta, err := s.UploadFile(fileHeader, scope, id, uploadedBy)
+		if errors.Is(err, ErrRejected) {
+			failed = append(failed, FailedFileError{
+				File:  BuildFileMeta(fileHeader, ""),
+				Error: err.Error(),
+			})
+			continue
+		}
 		if err != nil {
 			failed = append(failed, FailedFileError{
 				File:  BuildFileMeta(fileHeader, ""),
@@ -146,42 +192,98 @@ func (s *Service) ProcessBatchUpload(files []*multipart.FileHeader,
 			continue
 		}
 
-		uploaded = append(uploaded, BuildFileMeta(fileHeader, key))
+		uploaded = append(uploaded, meta)
 	}
 
 	return uploaded, failed
 }
 
-func (s *Service) UploadFileToS3(
+// UploadFile stores the file once the scope accepts its content, under the
+// type the content says it is rather than the one the client sent, and
+// queues it for the malware scan
+func (s *Service) UploadFile(
 	fh *multipart.FileHeader,
 	scope string,
 	id uuid.UUID,
-) (key string, err error) {
+	uploadedBy uuid.UUID,
+) (FileMeta, error) {
 
 	file, err := fh.Open()
 	if err != nil {
-		return "", fmt.Errorf("file open error: %v", err)
+		return FileMeta{}, fmt.Errorf("file open error: %v", err)
 	}
 	defer file.Close()
 
-	key = fmt.Sprintf("%s/%s-%s", scope, id.String(), fh.Filename)
+	detected, err := Detect(file, fh.Size, fh.Filename)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	if err := s.policy.Allow(scope, detected); err != nil {
+		return FileMeta{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return FileMeta{}, err
+	}
 
-	_, err = s.s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String("solveit"),
-		Key:         aws.String(key),
-		Body:        file,
-		ContentType: aws.String(fh.Header.Get("Content-Type")),
-	})
+	key := fmt.Sprintf("%s/%s-%s", scope, id.String(), fh.Filename)
+
+	err = s.storage.Put(context.TODO(), key, file, fh.Size, detected.Type)
 	if err != nil {
-		return "", err
+		return FileMeta{}, err
+	}
+	if err := s.TrackScan(context.TODO(), uploadedBy, key); err != nil {
+		return FileMeta{}, err
 	}
 
-	return key, nil
+	meta := BuildFileMeta(fh, key)
+	meta.FileType = detected.Type
+	return meta, nil
+}
+
+// CheckStored sniffs a file that reached the store without passing through
+// the server, and checks that the scope accepts it
+func (s *Service) CheckStored(ctx context.Context, scope, key, name string, size int64) (Detected, error) {
+	detected, err := Detect(&objectReader{ctx: ctx, storage: s.storage, key: key, size: size}, size, name)
+	if err != nil {
+		return Detected{}, err
+	}
+	return detected, s.policy.Allow(scope, detected)
+}
+
+// objectReader reads a stored object at random, a range request per read
+type objectReader struct {
+	ctx     context.Context
+	storage storage.Storage
+	key     string
+	size    int64
+}
+
+func (r *objectReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	want := min(int64(len(p)), r.size-off)
+	if want == 0 {
+		return 0, nil
+	}
+	obj, err := r.storage.Get(r.ctx, r.key, &storage.Range{Start: off, End: off + want - 1})
+	if err != nil {
+		return 0, err
+	}
+	defer obj.Close()
+	n, err := io.ReadFull(obj, p[:want])
+	if err != nil {
+		return n, err
+	}
+	if want < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
 }
 
 // Helper
 func validateSize(fh *multipart.FileHeader) error {
-	if fh.Size >= 50<<20 { // 50MB
+	if fh.Size >= MaxFileSize { // 50MB
 		return fmt.Errorf("Exceeded server limit (50MB)")
 	}
 	return nil
@@ -195,15 +297,12 @@ type PresignedResp struct {
 func (s *Service) GetPresignedURL(ctx context.Context, key string) (PresignedResp, error) {
 	validTime := time.Minute * 5
 
-	presignClient := s3.NewPresignClient(s.s3Client)
-
-	request, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String("solveit"),
-		Key:    aws.String(key),
-	}, s3.WithPresignExpires(validTime))
-
+	url, err := s.storage.Presign(ctx, key, storage.PresignOptions{
+		Method:  http.MethodGet,
+		Expires: validTime,
+	})
 	if err != nil {
 		return PresignedResp{}, err
 	}
-	return PresignedResp{Url: request.URL, ValidTime: validTime}, nil
+	return PresignedResp{Url: url, ValidTime: validTime}, nil
 }
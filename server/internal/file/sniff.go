@@ -0,0 +1,420 @@
+package file
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kind groups the file types a scope may accept
+type Kind string
+
+const (
+	KindImage    Kind = "image"
+	KindDocument Kind = "document"
+	KindArchive  Kind = "archive"
+	KindCode     Kind = "code"
+	KindMedia    Kind = "media"
+)
+
+const (
+	sniffLen = 3072
+	// zip readers look for the end of the central directory in the last
+	// 64KB, and so do the polyglots hiding an archive in another file
+	zipTailLen = 64<<10 + 22
+	scanChunk  = 1 << 20
+)
+
+var ErrRejected = errors.New("file rejected")
+
+// Detected is what the content of a file says it is
+type Detected struct {
+	Type string // normalized media type, stored as the FileType
+	Kind Kind
+}
+
+type signature struct {
+	match func(head []byte) bool
+	Detected
+}
+
+// at matches magic at offset of the head
+func at(offset int, magic string) func([]byte) bool {
+	return func(head []byte) bool {
+		return len(head) >= offset+len(magic) && string(head[offset:offset+len(magic)]) == magic
+	}
+}
+
+func both(a, b func([]byte) bool) func([]byte) bool {
+	return func(head []byte) bool { return a(head) && b(head) }
+}
+
+// signatures of the binary types accepted, tried in order
+var signatures = []signature{
+	{at(0, "\x89PNG\r\n\x1a\n"), Detected{"image/png", KindImage}},
+	{at(0, "\xff\xd8\xff"), Detected{"image/jpeg", KindImage}},
+	{at(0, "GIF87a"), Detected{"image/gif", KindImage}},
+	{at(0, "GIF89a"), Detected{"image/gif", KindImage}},
+	{both(at(0, "RIFF"), at(8, "WEBP")), Detected{"image/webp", KindImage}},
+	{both(at(0, "BM"), at(6, "\x00\x00\x00\x00")), Detected{"image/bmp", KindImage}},
+	{at(0, "\x00\x00\x01\x00"), Detected{"image/x-icon", KindImage}},
+	{at(4, "ftypavif"), Detected{"image/avif", KindImage}},
+	{at(4, "ftypheic"), Detected{"image/heic", KindImage}},
+
+	{at(0, "%PDF-"), Detected{"application/pdf", KindDocument}},
+	{at(0, "{\\rtf"), Detected{"application/rtf", KindDocument}},
+	{at(0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), Detected{"application/x-ole-storage", KindDocument}},
+
+	{at(0, "PK\x03\x04"), Detected{"application/zip", KindArchive}},
+	{at(0, "PK\x05\x06"), Detected{"application/zip", KindArchive}}, // empty
+	{at(0, "\x1f\x8b"), Detected{"application/gzip", KindArchive}},
+	{both(at(0, "BZh"), func(head []byte) bool { return len(head) > 3 && head[3] >= '1' && head[3] <= '9' }),
+		Detected{"application/x-bzip2", KindArchive}},
+	{at(0, "\xfd7zXZ\x00"), Detected{"application/x-xz", KindArchive}},
+	{at(0, "\x28\xb5\x2f\xfd"), Detected{"application/zstd", KindArchive}},
+	{at(0, "7z\xbc\xaf\x27\x1c"), Detected{"application/x-7z-compressed", KindArchive}},
+	{at(0, "Rar!\x1a\x07"), Detected{"application/vnd.rar", KindArchive}},
+	{at(257, "ustar"), Detected{"application/x-tar", KindArchive}},
+
+	{at(4, "ftypqt"), Detected{"video/quicktime", KindMedia}},
+	{at(4, "ftyp"), Detected{"video/mp4", KindMedia}},
+	{at(0, "\x1a\x45\xdf\xa3"), Detected{"video/webm", KindMedia}},
+	{at(0, "OggS"), Detected{"audio/ogg", KindMedia}},
+	{at(0, "ID3"), Detected{"audio/mpeg", KindMedia}},
+	{at(0, "\xff\xfb"), Detected{"audio/mpeg", KindMedia}},
+	{both(at(0, "RIFF"), at(8, "WAVE")), Detected{"audio/wav", KindMedia}},
+}
+
+// executables are refused whatever the scope allows
+var executables = [][]byte{
+	[]byte("MZ"),               // Windows
+	[]byte("\x7fELF"),          // Linux
+	[]byte("\xfe\xed\xfa\xce"), // macOS, 32 and 64 bits in both orders
+	[]byte("\xfe\xed\xfa\xcf"),
+	[]byte("\xce\xfa\xed\xfe"),
+	[]byte("\xcf\xfa\xed\xfe"),
+	[]byte("\xca\xfe\xba\xbe"), // universal macOS binaries and Java classes
+	[]byte("\x00asm"),
+}
+
+// blockedExtensions run when opened on some system, whatever they contain
+var blockedExtensions = map[string]bool{
+	".exe": true, ".dll": true, ".msi": true, ".scr": true, ".com": true,
+	".pif": true, ".cpl": true, ".bat": true, ".cmd": true, ".vbs": true,
+	".vbe": true, ".wsf": true, ".hta": true, ".lnk": true, ".jar": true,
+	".apk": true, ".app": true, ".class": true,
+}
+
+// documentTexts are the text files counted as documents rather than code
+var documentTexts = map[string]bool{".txt": true, ".md": true, ".csv": true}
+
+// embedded markers of scripts, which have no business in an image
+var scriptMarkers = [][]byte{[]byte("<script"), []byte("<?php"), []byte("<html")}
+
+// Detect tells the type of a file from its content, and refuses the
+// executables and the files posing as more than one type
+func Detect(r io.ReaderAt, size int64, name string) (Detected, error) {
+	ext := strings.ToLower(path.Ext(name))
+	if blockedExtensions[ext] {
+		return Detected{}, fmt.Errorf("%w: %s files are not allowed", ErrRejected, ext)
+	}
+
+	head := make([]byte, min(size, sniffLen))
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return Detected{}, err
+	}
+	for _, magic := range executables {
+		// a text starting with MZ is not a program
+		if bytes.HasPrefix(head, magic) && !isText(head) {
+			return Detected{}, fmt.Errorf("%w: executable files are not allowed", ErrRejected)
+		}
+	}
+
+	detected, ok := matchSignature(head)
+	if !ok {
+		if !isText(head) {
+			return Detected{}, fmt.Errorf("%w: unrecognized file type", ErrRejected)
+		}
+		detected = detectText(head, ext)
+	}
+
+	zipped := detected.Type == "application/zip"
+	if zipped {
+		var err error
+		if detected, err = inspectZip(r, size); err != nil {
+			return Detected{}, err
+		}
+	}
+	if detected.Type == "application/x-ole-storage" {
+		detected.Type = oleType(ext)
+	}
+
+	if err := checkExtension(detected, ext); err != nil {
+		return Detected{}, err
+	}
+	if err := checkPolyglot(r, size, detected, zipped); err != nil {
+		return Detected{}, err
+	}
+	return detected, nil
+}
+
+func matchSignature(head []byte) (Detected, bool) {
+	for _, sig := range signatures {
+		if sig.match(head) {
+			return sig.Detected, true
+		}
+	}
+	return Detected{}, false
+}
+
+// isText accepts UTF-8 without control characters, a multi-byte rune cut
+// at the end of head aside
+func isText(head []byte) bool {
+	if len(head) == 0 {
+		return true
+	}
+	if strings.HasPrefix(http.DetectContentType(head), "text/") {
+		return true
+	}
+	trimmed := head
+	for i := 0; i < utf8.UTFMax && len(trimmed) > 0 && !utf8.Valid(trimmed); i++ {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	if !utf8.Valid(trimmed) {
+		return false
+	}
+	for _, b := range trimmed {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			return false
+		}
+	}
+	return true
+}
+
+func detectText(head []byte, ext string) Detected {
+	// svg is markup that can carry scripts, it is not taken as an image
+	lower := bytes.ToLower(bytes.TrimSpace(head))
+	if ext == ".svg" || bytes.HasPrefix(lower, []byte("<svg")) ||
+		(bytes.HasPrefix(lower, []byte("<?xml")) && bytes.Contains(lower, []byte("<svg"))) {
+		return Detected{"image/svg+xml", KindCode}
+	}
+	detected := Detected{"text/plain", KindCode}
+	if documentTexts[ext] {
+		detected.Kind = KindDocument
+	}
+	// the extension only refines text, it cannot make it binary
+	if byExt, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil &&
+		(strings.HasPrefix(byExt, "text/") || strings.HasSuffix(byExt, "json") ||
+			strings.HasSuffix(byExt, "xml") || strings.HasSuffix(byExt, "javascript")) {
+		detected.Type = byExt
+	}
+	return detected
+}
+
+// inspectZip tells the office documents from the plain archives, and
+// refuses the archives carrying executables
+func inspectZip(r io.ReaderAt, size int64) (Detected, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return Detected{}, fmt.Errorf("%w: corrupt zip archive", ErrRejected)
+	}
+
+	detected := Detected{"application/zip", KindArchive}
+	for _, f := range archive.File {
+		name := f.Name
+		switch {
+		case name == "[Content_Types].xml":
+			detected = Detected{"application/vnd.openxmlformats-officedocument", KindDocument}
+		case name == "mimetype" && f.UncompressedSize64 < 128:
+			if t, err := readSmall(f); err == nil && strings.HasPrefix(t, "application/vnd.oasis.opendocument.") {
+				detected = Detected{t, KindDocument}
+			}
+		case name == "AndroidManifest.xml", name == "META-INF/MANIFEST.MF":
+			return Detected{}, fmt.Errorf("%w: Java and Android packages are not allowed", ErrRejected)
+		case blockedExtensions[strings.ToLower(path.Ext(name))]:
+			return Detected{}, fmt.Errorf("%w: archive contains %s, executable files are not allowed", ErrRejected, path.Base(name))
+		}
+	}
+
+	if detected.Type == "application/vnd.openxmlformats-officedocument" {
+		detected.Type = ooxmlType(archive)
+	}
+	return detected, nil
+}
+
+func readSmall(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, 128))
+	return strings.TrimSpace(string(b)), err
+}
+
+func ooxmlType(archive *zip.Reader) string {
+	prefixes := map[string]string{
+		"word/": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"xl/":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"ppt/":  "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	}
+	for _, f := range archive.File {
+		for prefix, t := range prefixes {
+			if strings.HasPrefix(f.Name, prefix) {
+				return t
+			}
+		}
+	}
+	return "application/vnd.openxmlformats-officedocument"
+}
+
+func oleType(ext string) string {
+	switch ext {
+	case ".doc":
+		return "application/msword"
+	case ".xls":
+		return "application/vnd.ms-excel"
+	case ".ppt":
+		return "application/vnd.ms-powerpoint"
+	}
+	return "application/x-ole-storage"
+}
+
+// checkExtension refuses the files named as an image or a pdf they are not,
+// and the images named as something else
+func checkExtension(detected Detected, ext string) error {
+	byExt, _, err := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if err != nil {
+		return nil
+	}
+	claimsImage := strings.HasPrefix(byExt, "image/")
+	isImage := detected.Kind == KindImage || detected.Type == "image/svg+xml"
+	if claimsImage != isImage || (byExt == "application/pdf") != (detected.Type == "application/pdf") {
+		return fmt.Errorf("%w: content is %s but the name ends in %s", ErrRejected, detected.Type, ext)
+	}
+	return nil
+}
+
+// checkPolyglot refuses the files that read as another type too: an
+// archive appended to a file that is not one, or scripts inside an image
+func checkPolyglot(r io.ReaderAt, size int64, detected Detected, zipped bool) error {
+	if detected.Kind != KindArchive && !zipped {
+		start := max(size-zipTailLen, 0)
+		tail := make([]byte, size-start)
+		if _, err := r.ReadAt(tail, start); err != nil && err != io.EOF {
+			return err
+		}
+		if bytes.Contains(tail, []byte("PK\x05\x06")) {
+			return fmt.Errorf("%w: %s file also holds a zip archive", ErrRejected, detected.Type)
+		}
+	}
+
+	if detected.Kind == KindImage || detected.Type == "image/svg+xml" {
+		marker, err := scan(io.NewSectionReader(r, 0, size), scriptMarkers)
+		if err != nil {
+			return err
+		}
+		if marker != "" {
+			return fmt.Errorf("%w: image contains %s markup", ErrRejected, marker)
+		}
+	}
+	return nil
+}
+
+// scan reads r through looking for any of the lowercase markers, case
+// insensitively, and returns the first found
+func scan(r io.Reader, markers [][]byte) (string, error) {
+	longest := 0
+	for _, m := range markers {
+		longest = max(longest, len(m))
+	}
+	buf := make([]byte, scanChunk+longest)
+	carry := 0
+	for {
+		n, err := io.ReadFull(r, buf[carry:])
+		window := bytes.ToLower(buf[:carry+n])
+		for _, m := range markers {
+			if bytes.Contains(window, m) {
+				return string(m), nil
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		// keep the end, a marker may straddle two reads
+		carry = copy(buf, buf[carry+n-(longest-1):carry+n])
+	}
+}
+
+// Policy holds the kinds of files each scope accepts
+type Policy map[string][]Kind
+
+func DefaultPolicy() Policy {
+	return Policy{
+		"editor-images": {KindImage},
+		"workspace":     {KindDocument, KindArchive, KindCode},
+		"task":          {KindDocument, KindArchive, KindCode, KindImage, KindMedia},
+		"mentorship":    {KindDocument, KindArchive, KindCode, KindImage, KindMedia},
+	}
+}
+
+// ParsePolicy reads allowlists written as "scope=kind,kind;scope=kind" over
+// the defaults, the scopes left out keep theirs
+func ParsePolicy(s string) (Policy, error) {
+	policy := DefaultPolicy()
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		scope, list, ok := strings.Cut(entry, "=")
+		scope = strings.TrimSpace(scope)
+		if !ok || scope == "" {
+			return nil, fmt.Errorf("invalid allowlist entry %q", entry)
+		}
+		kinds := []Kind{}
+		for _, k := range strings.Split(list, ",") {
+			kind := Kind(strings.TrimSpace(k))
+			switch kind {
+			case KindImage, KindDocument, KindArchive, KindCode, KindMedia:
+				kinds = append(kinds, kind)
+			case "":
+			default:
+				return nil, fmt.Errorf("unknown file kind %q for %s", kind, scope)
+			}
+		}
+		policy[scope] = kinds
+	}
+	return policy, nil
+}
+
+// Allow tells whether the scope accepts the file
+func (p Policy) Allow(scope string, detected Detected) error {
+	allowed := p[scope]
+	for _, kind := range allowed {
+		if kind == detected.Kind {
+			return nil
+		}
+	}
+	if len(allowed) == 0 {
+		return fmt.Errorf("%w: %s does not accept files", ErrRejected, scope)
+	}
+	names := make([]string, 0, len(allowed))
+	for _, kind := range allowed {
+		names = append(names, string(kind))
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%w: %s only accepts %s files, this is %s", ErrRejected,
+		scope, strings.Join(names, ", "), detected.Type)
+}
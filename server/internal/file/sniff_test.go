@@ -0,0 +1,155 @@
+package file
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var (
+	pngHead = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00")
+	pdfHead = []byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+)
+
+// zipOf builds an archive holding files, in the order given
+func zipOf(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := w.Create(f[0])
+		if err != nil {
+			t.Fatalf("zip Create %s: %v", f[0], err)
+		}
+		if _, err := fw.Write([]byte(f[1])); err != nil {
+			t.Fatalf("zip Write %s: %v", f[0], err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zip Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestDetect(t *testing.T) {
+	docx := zipOf(t,
+		[2]string{"[Content_Types].xml", `<?xml version="1.0"?><Types/>`},
+		[2]string{"word/document.xml", `<w:document/>`},
+	)
+	xlsx := zipOf(t,
+		[2]string{"[Content_Types].xml", `<?xml version="1.0"?><Types/>`},
+		[2]string{"xl/workbook.xml", `<workbook/>`},
+	)
+	odt := zipOf(t,
+		[2]string{"mimetype", "application/vnd.oasis.opendocument.text"},
+		[2]string{"content.xml", `<office:document-content/>`},
+	)
+
+	tests := []struct {
+		name    string
+		content []byte
+		file    string
+		want    Detected
+		reject  bool
+	}{
+		// accepted types
+		{name: "png", content: pngHead, file: "photo.png", want: Detected{"image/png", KindImage}},
+		{name: "pdf", content: pdfHead, file: "report.pdf", want: Detected{"application/pdf", KindDocument}},
+		{name: "plain text", content: []byte("hello\nworld\n"), file: "notes.txt", want: Detected{"text/plain", KindDocument}},
+		{name: "text starting like a program", content: []byte("MZ is how the notes start\n"), file: "notes.txt", want: Detected{"text/plain", KindDocument}},
+		{name: "code", content: []byte(`{"name": "solveit"}`), file: "config.json", want: Detected{"application/json", KindCode}},
+		{name: "svg is code", content: []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), file: "logo.svg", want: Detected{"image/svg+xml", KindCode}},
+		{name: "zip", content: zipOf(t, [2]string{"a.txt", "a"}), file: "files.zip", want: Detected{"application/zip", KindArchive}},
+
+		// office documents are zips
+		{name: "docx", content: docx, file: "essay.docx",
+			want: Detected{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", KindDocument}},
+		{name: "xlsx", content: xlsx, file: "sheet.xlsx",
+			want: Detected{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", KindDocument}},
+		{name: "docx under another name", content: docx, file: "essay.zip",
+			want: Detected{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", KindDocument}},
+		{name: "opendocument", content: odt, file: "essay.odt", want: Detected{"application/vnd.oasis.opendocument.text", KindDocument}},
+
+		// executables
+		{name: "windows program", content: concat([]byte("MZ\x90\x00\x03\x00\x00\x00"), make([]byte, 64)), file: "setup.png", reject: true},
+		{name: "linux program", content: concat([]byte("\x7fELF\x02\x01\x01\x00"), make([]byte, 64)), file: "tool", reject: true},
+		{name: "mach-o program", content: concat([]byte("\xcf\xfa\xed\xfe"), make([]byte, 64)), file: "tool.bin", reject: true},
+		{name: "blocked extension", content: []byte("echo hi\n"), file: "run.bat", reject: true},
+		{name: "zip carrying a program", content: zipOf(t, [2]string{"docs/readme.txt", "hi"}, [2]string{"docs/setup.exe", "MZ"}), file: "docs.zip", reject: true},
+		{name: "java package", content: zipOf(t, [2]string{"META-INF/MANIFEST.MF", "Manifest-Version: 1.0\n"}), file: "lib.zip", reject: true},
+		{name: "docx carrying a program", content: zipOf(t,
+			[2]string{"[Content_Types].xml", `<Types/>`},
+			[2]string{"word/document.xml", `<w:document/>`},
+			[2]string{"word/embeddings/payload.exe", "MZ"},
+		), file: "essay.docx", reject: true},
+
+		// polyglots
+		{name: "png hiding a zip", content: concat(pngHead, zipOf(t, [2]string{"a.txt", "a"})), file: "photo.png", reject: true},
+		{name: "pdf hiding a zip", content: concat(pdfHead, zipOf(t, [2]string{"a.txt", "a"})), file: "report.pdf", reject: true},
+		{name: "png carrying a script", content: concat(pngHead, []byte("<SCRIPT>alert(1)</script>")), file: "photo.png", reject: true},
+		{name: "png carrying php", content: concat(pngHead, make([]byte, 2<<20), []byte("<?php system($_GET['c']); ?>")), file: "photo.png", reject: true},
+		{name: "svg carrying a script", content: []byte(`<svg><script>alert(1)</script></svg>`), file: "logo.svg", reject: true},
+
+		// names that lie
+		{name: "png named as a pdf", content: pngHead, file: "report.pdf", reject: true},
+		{name: "pdf named as an image", content: pdfHead, file: "photo.jpg", reject: true},
+		{name: "text named as an image", content: []byte("not an image"), file: "photo.png", reject: true},
+		{name: "unknown binary", content: []byte{0x00, 0x01, 0x02, 0x03, 0xff, 0xfe}, file: "data.bin", reject: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Detect(bytes.NewReader(tt.content), int64(len(tt.content)), tt.file)
+			if tt.reject {
+				if !errors.Is(err, ErrRejected) {
+					t.Fatalf("Detect = %v, %v, want ErrRejected", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Detect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyAllow(t *testing.T) {
+	policy, err := ParsePolicy("workspace=document;chat=")
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+
+	tests := []struct {
+		scope    string
+		detected Detected
+		ok       bool
+	}{
+		{"editor-images", Detected{"image/png", KindImage}, true},
+		{"editor-images", Detected{"image/svg+xml", KindCode}, false},
+		{"workspace", Detected{"application/pdf", KindDocument}, true},
+		{"workspace", Detected{"application/zip", KindArchive}, false},
+		{"chat", Detected{"text/plain", KindDocument}, false},
+		{"unknown", Detected{"text/plain", KindDocument}, false},
+	}
+	for _, tt := range tests {
+		err := policy.Allow(tt.scope, tt.detected)
+		if tt.ok != (err == nil) {
+			t.Errorf("Allow(%s, %s) = %v, want allowed %v", tt.scope, tt.detected.Type, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrRejected) {
+			t.Errorf("Allow(%s, %s) = %v, want ErrRejected", tt.scope, tt.detected.Type, err)
+		}
+	}
+
+	if _, err := ParsePolicy("workspace=binaries"); err == nil {
+		t.Error("ParsePolicy accepted an unknown kind")
+	}
+}
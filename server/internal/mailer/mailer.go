@@ -0,0 +1,274 @@
+// Package mailer delivers templated emails over SMTP through a retry queue
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/textproto"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/utils"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	queueKey      = "mail:queue"      // zset of jobs scored by their next attempt
+	processingKey = "mail:processing" // zset of claimed jobs scored by the end of their lease
+	dedupePrefix  = "mail:dedupe:"    // keys of EnqueueOnce
+	batchSize     = 50                // jobs claimed per delivery cycle
+	sendTimeout   = 30 * time.Second  // bound of a whole SMTP conversation
+
+	// a job still processing past its lease was left behind by an instance
+	// that stopped, it is claimed again and may be sent twice
+	leaseTimeout = batchSize*sendTimeout + 5*time.Minute
+)
+
+// retryBackoff is the wait before each retry, a job is dropped once it runs out
+var retryBackoff = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+}
+
+var ErrNotConfigured = errors.New("smtp is not configured")
+
+// claimScript leases the jobs whose lease ran out and then the ones that are
+// due, moving them to the processing zset so that each one is sent by a
+// single instance and none is lost with it.
+var claimScript = redis.NewScript(`
+local claimed = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+local left = tonumber(ARGV[2]) - #claimed
+if left > 0 then
+  local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, left)
+  if #due > 0 then
+    redis.call('ZREM', KEYS[1], unpack(due))
+    for _, job in ipairs(due) do
+      table.insert(claimed, job)
+    end
+  end
+end
+for _, job in ipairs(claimed) do
+  redis.call('ZADD', KEYS[2], ARGV[3], job)
+end
+return claimed
+`)
+
+type Config struct {
+	Host     string
+	Port     string
+	Username string // empty for servers without auth, like a local sink
+	Password string
+	From     string // "SolveIt <no-reply@example.com>"
+	TLS      bool   // implicit TLS (port 465), STARTTLS is used whenever offered
+	AppURL   string // base of the links in the templates
+}
+
+// Email asks for a message built from the templates of Kind.
+type Email struct {
+	To   string         `json:"to"`
+	Kind string         `json:"kind"`
+	Data map[string]any `json:"data"`
+}
+
+type job struct {
+	ID        string `json:"id"`
+	To        string `json:"to"`
+	Kind      string `json:"kind"`
+	Subject   string `json:"subject"`
+	Text      string `json:"text"`
+	HTML      string `json:"html"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+type Service struct {
+	config    Config
+	from      *mail.Address
+	redis     *redis.Client
+	store     *database.Queries
+	templates map[string]*templateSet
+}
+
+func NewService(config Config, redisClient *redis.Client, store *database.Queries) (*Service, error) {
+	templates, err := loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		config:    config,
+		redis:     redisClient,
+		store:     store,
+		templates: templates,
+	}
+	if config.Host != "" {
+		if s.from, err = mail.ParseAddress(config.From); err != nil {
+			return nil, fmt.Errorf("invalid sender address %q: %w", config.From, err)
+		}
+	}
+	return s, nil
+}
+
+// Enabled reports whether an SMTP server is configured
+func (s *Service) Enabled() bool {
+	return s.config.Host != ""
+}
+
+// Enqueue renders the email and queues it for the delivery job
+func (s *Service) Enqueue(ctx context.Context, email Email) error {
+	if !s.Enabled() {
+		return ErrNotConfigured
+	}
+	if _, err := mail.ParseAddress(email.To); err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", email.To, err)
+	}
+
+	data := map[string]any{"AppURL": s.config.AppURL}
+	for k, v := range email.Data {
+		data[k] = v
+	}
+	subject, text, html, err := s.render(email.Kind, data)
+	if err != nil {
+		return err
+	}
+
+	return s.schedule(ctx, job{
+		ID:      uuid.NewString(),
+		To:      email.To,
+		Kind:    email.Kind,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	}, time.Now())
+}
+
+// EnqueueOnce queues the email unless one with the same key was queued
+// within window, so that bursts of events produce a single email.
+func (s *Service) EnqueueOnce(ctx context.Context, key string, window time.Duration, email Email) error {
+	if !s.Enabled() {
+		return ErrNotConfigured
+	}
+	fresh, err := s.redis.SetNX(ctx, dedupePrefix+key, 1, window).Result()
+	if err != nil || !fresh {
+		return err
+	}
+	return s.Enqueue(ctx, email)
+}
+
+func (s *Service) schedule(ctx context.Context, j job, at time.Time) error {
+	body, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return s.redis.ZAdd(ctx, queueKey, &redis.Z{Score: float64(at.Unix()), Member: body}).Err()
+}
+
+// StartDeliveryJob sends the queued emails every interval until ctx is done
+func (s *Service) StartDeliveryJob(ctx context.Context, interval time.Duration) {
+	if !s.Enabled() {
+		log.Println("SMTP_HOST is not set, email delivery is disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	log.Println("Starting Background Job for email delivery")
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Email delivery shutting down...")
+			return
+		case <-ticker.C:
+			// a claimed batch is sent even when shutting down, it would
+			// otherwise wait for its lease to run out
+			s.runDelivery(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+func (s *Service) runDelivery(ctx context.Context) {
+	now := time.Now()
+	claimed, err := claimScript.Run(ctx, s.redis, []string{queueKey, processingKey},
+		now.Unix(), batchSize, now.Add(leaseTimeout).Unix()).StringSlice()
+	if err != nil {
+		log.Printf("Error claiming queued emails: %v", err)
+		return
+	}
+
+	for _, raw := range claimed {
+		var j job
+		if err := json.Unmarshal([]byte(raw), &j); err != nil {
+			log.Printf("Dropping malformed email job: %v", err)
+			s.release(ctx, raw, nil, time.Time{})
+			continue
+		}
+		retry, at := s.deliver(ctx, j)
+		s.release(ctx, raw, retry, at)
+	}
+}
+
+// deliver sends the job and returns it again with the time of its retry
+// when it is worth one, nil once it was sent, bounced or ran out of attempts
+func (s *Service) deliver(ctx context.Context, j job) (*job, time.Time) {
+	err := s.send(j)
+	if err == nil {
+		log.Printf("Sent %s email %s", j.Kind, j.ID)
+		return nil, time.Time{}
+	}
+
+	// a 5xx reply is final, the address or the message is refused
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		s.logFailure(ctx, "warn", fmt.Sprintf("email %s (%s) to %s bounced", j.ID, j.Kind, j.To), err)
+		return nil, time.Time{}
+	}
+
+	j.LastError = err.Error()
+	if j.Attempts >= len(retryBackoff) {
+		s.logFailure(ctx, "error", fmt.Sprintf("email %s (%s) to %s dropped after %d attempts", j.ID, j.Kind, j.To, j.Attempts+1), err)
+		return nil, time.Time{}
+	}
+	wait := retryBackoff[j.Attempts]
+	j.Attempts++
+	log.Printf("Email %s failed, retrying in %v: %v", j.ID, wait, err)
+	return &j, time.Now().Add(wait)
+}
+
+// release ends the lease of a claimed job, queueing its retry in the same
+// transaction when there is one
+func (s *Service) release(ctx context.Context, claimed string, retry *job, at time.Time) {
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if retry != nil {
+			body, err := json.Marshal(retry)
+			if err != nil {
+				return err
+			}
+			pipe.ZAdd(ctx, queueKey, &redis.Z{Score: float64(at.Unix()), Member: body})
+		}
+		pipe.ZRem(ctx, processingKey, claimed)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to release a claimed email: %v", err)
+	}
+}
+
+// logFailure records undeliverable emails in the logs table
+func (s *Service) logFailure(ctx context.Context, level, message string, cause error) {
+	log.Printf("%s: %v", message, cause)
+	if err := s.store.CreateLog(ctx, database.CreateLogParams{
+		Level:   level,
+		Message: message,
+		Error:   utils.ToStringPtr(cause.Error()),
+	}); err != nil {
+		log.Printf("Failed to record email failure: %v", err)
+	}
+}
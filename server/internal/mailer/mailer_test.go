@@ -0,0 +1,244 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// smtpSink is an SMTP server that keeps the messages it is sent, and
+// answers RCPT TO with rcptReply
+type smtpSink struct {
+	listener  net.Listener
+	rcptReply string
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func newSMTPSink(t *testing.T, rcptReply string) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	sink := &smtpSink{listener: listener, rcptReply: rcptReply}
+	t.Cleanup(func() { listener.Close() })
+	go sink.serve()
+	return sink
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	tp := textproto.NewConn(conn)
+
+	tp.PrintfLine("220 sink ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(strings.ToUpper(line), " ")
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 sink")
+		case "MAIL", "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			tp.PrintfLine("%s", s.rcptReply)
+		case "DATA":
+			tp.PrintfLine("354 end with <CRLF>.<CRLF>")
+			lines, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, strings.Join(lines, "\n"))
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpSink) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// logDB stands in for the database, recording the levels of the logs the
+// service writes
+type logDB struct {
+	mu     sync.Mutex
+	levels []string
+}
+
+func (db *logDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if level, ok := args[0].(string); ok {
+		db.levels = append(db.levels, level)
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *logDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (db *logDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return nil
+}
+
+func newTestService(t *testing.T, address string) (*Service, *logDB) {
+	t.Helper()
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatalf("split %q: %v", address, err)
+	}
+	db := &logDB{}
+	s, err := NewService(Config{
+		Host: host,
+		Port: port,
+		From: "SolveIt <no-reply@solveit.test>",
+	}, nil, database.New(db))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s, db
+}
+
+func testJob(attempts int) job {
+	return job{
+		ID:       "job-1",
+		To:       "solver@solveit.test",
+		Kind:     KindNotification,
+		Subject:  "A new task",
+		Text:     "hello",
+		HTML:     "<p>hello</p>",
+		Attempts: attempts,
+	}
+}
+
+func TestDeliverSends(t *testing.T) {
+	sink := newSMTPSink(t, "250 OK")
+	s, db := newTestService(t, sink.listener.Addr().String())
+
+	retry, _ := s.deliver(context.Background(), testJob(0))
+	if retry != nil {
+		t.Fatalf("deliver asked for a retry after sending: %+v", retry)
+	}
+	messages := sink.received()
+	if len(messages) != 1 {
+		t.Fatalf("sink received %d messages, want 1", len(messages))
+	}
+	for _, header := range []string{"To: solver@solveit.test", "Subject: A new task", "Message-ID: <job-1@solveit.test>"} {
+		if !strings.Contains(messages[0], header) {
+			t.Errorf("message is missing %q:\n%s", header, messages[0])
+		}
+	}
+	if len(db.levels) != 0 {
+		t.Errorf("logged %v for a sent email", db.levels)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	sink := newSMTPSink(t, "451 4.3.0 try again later")
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		address  string
+		attempts int
+	}{
+		{name: "temporary failure", address: sink.listener.Addr().String(), attempts: 0},
+		{name: "temporary failure again", address: sink.listener.Addr().String(), attempts: 2},
+		{name: "server down", address: closed.Addr().String(), attempts: 0},
+		{name: "last retry", address: sink.listener.Addr().String(), attempts: len(retryBackoff) - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestService(t, tt.address)
+
+			before := time.Now()
+			retry, at := s.deliver(context.Background(), testJob(tt.attempts))
+			if retry == nil {
+				t.Fatal("deliver gave up on a temporary failure")
+			}
+			if retry.Attempts != tt.attempts+1 {
+				t.Errorf("Attempts = %d, want %d", retry.Attempts, tt.attempts+1)
+			}
+			if retry.LastError == "" {
+				t.Error("LastError is empty")
+			}
+			wait := retryBackoff[tt.attempts]
+			if at.Before(before.Add(wait)) || at.After(time.Now().Add(wait)) {
+				t.Errorf("retry at %v, want %v after the attempt", at, wait)
+			}
+			if len(db.levels) != 0 {
+				t.Errorf("logged %v for an email that is retried", db.levels)
+			}
+		})
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		rcptReply string
+		attempts  int
+		level     string
+	}{
+		{name: "bounce", rcptReply: "550 5.1.1 no such user", attempts: 0, level: "warn"},
+		{name: "bounce on a retry", rcptReply: "554 5.7.1 rejected", attempts: 3, level: "warn"},
+		{name: "out of attempts", rcptReply: "451 4.3.0 try again later", attempts: len(retryBackoff), level: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newSMTPSink(t, tt.rcptReply)
+			s, db := newTestService(t, sink.listener.Addr().String())
+
+			if retry, _ := s.deliver(context.Background(), testJob(tt.attempts)); retry != nil {
+				t.Fatalf("deliver asked for a retry: %+v", retry)
+			}
+			if len(sink.received()) != 0 {
+				t.Error("the sink received a message it refused")
+			}
+			if len(db.levels) != 1 || db.levels[0] != tt.level {
+				t.Errorf("logged %v, want [%s]", db.levels, tt.level)
+			}
+		})
+	}
+}
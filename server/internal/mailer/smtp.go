@@ -0,0 +1,115 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// send delivers one job. Replies of the server come back as
+// *textproto.Error so that the caller can tell bounces from outages.
+func (s *Service) send(j job) error {
+	msg, err := s.buildMessage(j)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.config.Host, s.config.Port)
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	var conn net.Conn
+	if s.config.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: s.config.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	c, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && !s.config.TLS {
+		if err := c.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return err
+		}
+	}
+	if s.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(j.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage writes a multipart/alternative message with the text and
+// html bodies of the job.
+func (s *Service) buildMessage(j job) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	_, domain, _ := strings.Cut(s.from.Address, "@")
+	headers := []string{
+		"From: " + s.from.String(),
+		"To: " + j.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", j.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%s@%s>", j.ID, domain),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", j.Text},
+		{"text/html; charset=utf-8", j.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Kinds of email, each one has a <kind>.txt template defining "subject" and
+// "body" and a <kind>.html template defining "content" for the layout.
+const (
+	KindNotification    = "notification"
+	KindBlockedFromTask = "blocked_from_task"
+	KindDeadlineWarning = "deadline_warning"
+	KindChatMessage     = "chat_message"
+	KindDigest          = "digest"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+type templateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+func loadTemplates() (map[string]*templateSet, error) {
+	names, err := fs.Glob(templateFS, "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make(map[string]*templateSet, len(names))
+	for _, name := range names {
+		kind := strings.TrimSuffix(strings.TrimPrefix(name, "templates/"), ".txt")
+
+		text, err := texttemplate.ParseFS(templateFS, name)
+		if err != nil {
+			return nil, err
+		}
+		html, err := htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+kind+".html")
+		if err != nil {
+			return nil, err
+		}
+		sets[kind] = &templateSet{text: text, html: html}
+	}
+	return sets, nil
+}
+
+func (s *Service) render(kind string, data map[string]any) (string, string, string, error) {
+	set, ok := s.templates[kind]
+	if !ok {
+		return "", "", "", fmt.Errorf("unknown email kind %q", kind)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := set.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	if err := set.text.ExecuteTemplate(&text, "body", data); err != nil {
+		return "", "", "", err
+	}
+	data["Subject"] = strings.TrimSpace(subject.String())
+	if err := set.html.ExecuteTemplate(&html, "layout.html", data); err != nil {
+		return "", "", "", err
+	}
+	return data["Subject"].(string), strings.TrimSpace(text.String()) + "\n", html.String(), nil
+}
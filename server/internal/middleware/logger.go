@@ -32,6 +32,11 @@ func (w *wrappedWriter) Flush() {
 	}
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
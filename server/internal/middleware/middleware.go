@@ -3,13 +3,13 @@ package middleware
 
 import (
 	"context"
-	"errors"
+	"crypto/subtle"
 	"fmt"
 	"github/abdallemo/solveit-saas/internal/user"
 	"github/abdallemo/solveit-saas/internal/utils"
 	"log"
 	"net/http"
-	"os"
+	"slices"
 
 	"time"
 
@@ -47,9 +47,12 @@ func NewMiddleware(jwksURL string, allowedOrigins []string) (*Middleware, error)
 	}
 
 	c := cors.New(cors.Options{
-		AllowedOrigins:   allowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token",
+			"Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset"},
+		ExposedHeaders: []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension",
+			"Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-Expires"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	})
@@ -63,49 +66,87 @@ func NewMiddleware(jwksURL string, allowedOrigins []string) (*Middleware, error)
 
 func (m *Middleware) IsAuthorized(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		keyset, err := m.fetcher.Fetch(r.Context(), m.jwksURL)
-		if err != nil {
-			log.Println(err)
+		m.authorize(w, r, next)
+	})
+}
 
-			log.Printf("failed to fetch JWKS: %v", err)
-			http.Error(w, "auth system unavailable", http.StatusServiceUnavailable)
+// IsAuthorizedWs validates websocket handshakes. Browsers can not set headers
+// on a websocket upgrade, so the JWT is also accepted in the "token" query param.
+func (m *Middleware) IsAuthorizedWs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid query", http.StatusBadRequest)
 			return
 		}
+		m.authorize(w, r, next, jwt.WithHeaderKey("Authorization"), jwt.WithFormKey("token"))
+	})
+}
 
-		token, err := jwt.ParseRequest(
-			r,
-			jwt.WithKeySet(keyset),
-			jwt.WithValidate(true),
-		)
-		if err != nil {
-			log.Println(err)
-			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
-			return
-		}
-		user, err := utils.ExtractUserClaims(token)
-		if err != nil {
-			log.Println(err)
+// authorize validates the request JWT against the JWKS and passes the
+// user claims down to next through the request context.
+func (m *Middleware) authorize(w http.ResponseWriter, r *http.Request, next http.Handler, options ...jwt.ParseOption) {
+	keyset, err := m.fetcher.Fetch(r.Context(), m.jwksURL)
+	if err != nil {
+		log.Printf("failed to fetch JWKS: %v", err)
+		http.Error(w, "auth system unavailable", http.StatusServiceUnavailable)
+		return
+	}
 
-			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
-			return
-		}
+	options = append(options, jwt.WithKeySet(keyset), jwt.WithValidate(true))
+	token, err := jwt.ParseRequest(r, options...)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+	user, err := utils.ExtractUserClaims(token)
+	if err != nil {
+		log.Println(err)
 
-		ctx := context.WithValue(r.Context(), UserClaim, user)
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+		return
+	}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	ctx := context.WithValue(r.Context(), UserClaim, user)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
 }
 
-func (m *Middleware) IsAuthorizedWs(r *http.Request) error {
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
-		return errors.New("unauthorized: session token not found")
+// RequireRole lets authorized users with one of the roles through only
+func RequireRole(roles ...string) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := GetUserClaims(r.Context())
+			if err != nil {
+				http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+			if !slices.Contains(roles, claims.Role) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
+}
+
+// ServiceKeyHeader carries the key that services calling the api on their
+// own behalf, rather than a user's, authenticate with
+const ServiceKeyHeader = "X-Service-Key"
 
-	if cookie.Value != os.Getenv("GO_API_AUTH") {
-		return errors.New("unauthorized: invalid session token")
+// RequireServiceKey lets requests carrying key in ServiceKeyHeader through
+// only. With an empty key every request is refused.
+func RequireServiceKey(key string) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := r.Header.Get(ServiceKeyHeader)
+			if key == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+				http.Error(w, "invalid service key", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
-	return nil
 }
 
 func (m *Middleware) CORS() MiddlewareFunc {
@@ -124,10 +165,18 @@ func (m *Middleware) CreateStack(xs ...MiddlewareFunc) MiddlewareFunc {
 	}
 }
 
-func GetUserID(ctx context.Context) (uuid.UUID, error) {
+func GetUserClaims(ctx context.Context) (*user.UserClaims, error) {
 	user, ok := ctx.Value(UserClaim).(*user.UserClaims)
 	if !ok {
-		return uuid.UUID{}, fmt.Errorf("User not authenticated")
+		return nil, fmt.Errorf("User not authenticated")
+	}
+	return user, nil
+}
+
+func GetUserID(ctx context.Context) (uuid.UUID, error) {
+	user, err := GetUserClaims(ctx)
+	if err != nil {
+		return uuid.UUID{}, err
 	}
 	userUUID, err := uuid.Parse(user.ID)
 	if err != nil {
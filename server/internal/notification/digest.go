@@ -0,0 +1,115 @@
+package notification
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/mailer"
+
+	"github.com/google/uuid"
+)
+
+const (
+	digestBatchSize = 100
+	digestPageSize  = 20 // notifications listed in one digest
+	// notifications younger than this may still be read in the app
+	digestGrace = 10 * time.Minute
+)
+
+type DigestItem struct {
+	Subject   string
+	Content   string
+	CreatedAt string
+}
+
+// SendDigests emails every user whose digest is due and who is not in
+// their quiet hours the unread notifications that were neither emailed nor
+// digested yet. It returns how many digests were queued.
+func (s *Service) SendDigests(ctx context.Context) (int, error) {
+	if !s.mailer.Enabled() {
+		return 0, nil
+	}
+
+	now := time.Now()
+	sent := 0
+	after := uuid.Nil
+	for {
+		users, err := s.store.ListDigestCandidates(ctx, database.ListDigestCandidatesParams{
+			AfterID:       after,
+			CreatedBefore: now.Add(-digestGrace),
+			BatchSize:     digestBatchSize,
+		})
+		if err != nil {
+			return sent, err
+		}
+
+		for _, user := range users {
+			if inQuietHours(now, user.Timezone, user.QuietHoursStart, user.QuietHoursEnd) {
+				continue
+			}
+			if err := s.sendDigest(ctx, user, now); err != nil {
+				log.Printf("failed to send notification digest to %s: %v", user.ID, err)
+				continue
+			}
+			sent++
+		}
+
+		if len(users) < digestBatchSize {
+			return sent, nil
+		}
+		after = users[len(users)-1].ID
+	}
+}
+
+func (s *Service) sendDigest(ctx context.Context, user database.ListDigestCandidatesRow, now time.Time) error {
+	rows, err := s.store.ListDigestNotifications(ctx, database.ListDigestNotificationsParams{
+		ReceiverID:    user.ID.String(),
+		CreatedBefore: now.Add(-digestGrace),
+		PageSize:      digestPageSize + 1,
+	})
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	more := len(rows) > digestPageSize
+	rows = rows[:min(len(rows), digestPageSize)]
+
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	items := make([]DigestItem, len(rows))
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+		items[i] = DigestItem{Content: row.Content}
+		if row.Subject != nil {
+			items[i].Subject = *row.Subject
+		}
+		if row.CreatedAt != nil {
+			items[i].CreatedAt = row.CreatedAt.In(loc).Format("Jan 2, 15:04")
+		}
+	}
+
+	err = s.email(ctx, user.ID, mailer.Email{
+		Kind: mailer.KindDigest,
+		Data: map[string]any{
+			"Frequency": strings.ToLower(string(user.DigestFrequency)),
+			"Items":     items,
+			"More":      more,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.MarkNotificationsDigested(ctx, database.MarkNotificationsDigestedParams{
+		ReceiverID: user.ID.String(),
+		Ids:        ids,
+	}); err != nil {
+		return err
+	}
+	return s.store.TouchNotificationDigest(ctx, user.ID)
+}
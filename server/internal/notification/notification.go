@@ -0,0 +1,387 @@
+// Package notification holds the notification inbox and delivery logic
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/api/websocket"
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/mailer"
+	"github/abdallemo/solveit-saas/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	SystemSender = "solveit@org.com"
+
+	// at most one email per key in this window for users who are offline
+	offlineEmailWindow = 30 * time.Minute
+)
+
+var (
+	ErrNotFound      = errors.New("notification not found")
+	ErrInvalidMethod = errors.New("invalid notification method")
+)
+
+type Service struct {
+	store    *database.Queries
+	wsNotif  *websocket.WsNotification
+	presence *websocket.Presence
+	mailer   *mailer.Service
+}
+
+func NewService(store *database.Queries, wsNotif *websocket.WsNotification, presence *websocket.Presence, mailer *mailer.Service) *Service {
+	return &Service{
+		store:    store,
+		wsNotif:  wsNotif,
+		presence: presence,
+		mailer:   mailer,
+	}
+}
+
+// Delivery is a notification routed to the receiver by their preferences
+type Delivery struct {
+	ReceiverID uuid.UUID
+	SenderID   string
+	EventType  string
+	Subject    string
+	Content    string
+	// Email is the template used when the receiver wants this event by
+	// email, the generic one is used otherwise. The recipient is filled in.
+	Email *mailer.Email
+}
+
+type Filter struct {
+	Subject    string
+	Method     string
+	UnreadOnly bool
+}
+
+type Page struct {
+	Notifications []websocket.Message `json:"notifications"`
+	NextCursor    *string             `json:"nextCursor"`
+}
+
+// ReadStateEvent is pushed over notif:<user> whenever the inbox changes
+// outside of a new notification, so that every tab can update its badge.
+type ReadStateEvent struct {
+	MessageType string      `json:"messageType"`
+	IDs         []uuid.UUID `json:"ids"`
+	UnreadCount int         `json:"unreadCount"`
+}
+
+func MapNotification(n database.Notification) websocket.Message {
+	msg := websocket.Message{
+		ID:         n.ID.String(),
+		Content:    n.Content,
+		ReceiverID: n.ReceiverID,
+		SenderID:   n.SenderID,
+		Method:     string(n.Method),
+		Read:       n.Read,
+		EventType:  n.EventType,
+	}
+	if n.Subject != nil {
+		msg.Subject = *n.Subject
+	}
+	if n.CreatedAt != nil {
+		msg.CreatedAt = n.CreatedAt.Format(time.RFC3339Nano)
+	}
+	return msg
+}
+
+// Deliver stores the notification unless the receiver muted the event,
+// pushes it to their open tabs unless they only want it in the digest and
+// emails it when they asked for email.
+func (s *Service) Deliver(ctx context.Context, d Delivery) (websocket.Message, error) {
+	if d.EventType == "" {
+		d.EventType = EventGeneral
+	}
+	channel, err := s.Channel(ctx, d.ReceiverID, d.EventType)
+	if err != nil {
+		return websocket.Message{}, err
+	}
+	if channel == database.NotificationChannelMUTED {
+		return websocket.Message{}, nil
+	}
+
+	sender := d.SenderID
+	if sender == "" {
+		sender = SystemSender
+	}
+	method := database.MethodSYSTEM
+	if channel == database.NotificationChannelEMAIL && s.mailer.Enabled() {
+		method = database.MethodEMAIL
+	}
+	n, err := s.store.ProcessSystemNotification(ctx, database.ProcessSystemNotificationParams{
+		SenderID:   sender,
+		ReceiverID: d.ReceiverID.String(),
+		Subject:    &d.Subject,
+		Content:    d.Content,
+		Method:     method,
+		Read:       false,
+		EventType:  d.EventType,
+	})
+	if err != nil {
+		return websocket.Message{}, err
+	}
+
+	msg := MapNotification(n)
+	s.route(ctx, msg, channel, d.Email)
+	return msg, nil
+}
+
+// Dispatch routes a notification that the web app already stored. Only
+// what was stored is routed, and only once: dispatching it again is a no-op.
+func (s *Service) Dispatch(ctx context.Context, id uuid.UUID) error {
+	n, err := s.store.GetNotification(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	claimed, err := s.store.MarkNotificationDispatched(ctx, id)
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return nil
+	}
+	receiverID, err := uuid.Parse(n.ReceiverID)
+	if err != nil {
+		return ErrNotFound
+	}
+	msg := MapNotification(n)
+	if msg.EventType == "" {
+		msg.EventType = EventGeneral
+	}
+
+	channel, err := s.Channel(ctx, receiverID, msg.EventType)
+	if err != nil {
+		return err
+	}
+	switch channel {
+	case database.NotificationChannelMUTED:
+		// kept out of the unread count and the digest
+		_, err := s.store.MarkNotificationRead(ctx, database.MarkNotificationReadParams{
+			ID:         n.ID,
+			ReceiverID: n.ReceiverID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	case database.NotificationChannelEMAIL:
+		if s.mailer.Enabled() {
+			if err := s.store.MarkNotificationEmailed(ctx, n.ID); err != nil {
+				return err
+			}
+			msg.Method = string(database.MethodEMAIL)
+		}
+	}
+
+	s.route(ctx, msg, channel, nil)
+	return nil
+}
+
+// route pushes a stored notification over the websocket and, when it was
+// stored as EMAIL, queues the email.
+func (s *Service) route(ctx context.Context, msg websocket.Message, channel database.NotificationChannel, email *mailer.Email) {
+	if channel == database.NotificationChannelDIGEST {
+		return
+	}
+	s.wsNotif.SendToUser(msg.ReceiverID, msg)
+
+	if msg.Method != string(database.MethodEMAIL) {
+		return
+	}
+	if email == nil {
+		email = &mailer.Email{
+			Kind: mailer.KindNotification,
+			Data: map[string]any{"Title": msg.Subject, "Content": msg.Content},
+		}
+	}
+	receiverID, err := uuid.Parse(msg.ReceiverID)
+	if err == nil {
+		err = s.email(ctx, receiverID, *email)
+	}
+	if err != nil {
+		log.Printf("failed to queue email for notification %s: %v", msg.ID, err)
+	}
+}
+
+// EmailIfOffline emails a user without any live connection who wants the
+// event by email, once per key every offlineEmailWindow.
+func (s *Service) EmailIfOffline(ctx context.Context, userID uuid.UUID, eventType, key string, email mailer.Email) error {
+	if !s.mailer.Enabled() {
+		return nil
+	}
+	channel, err := s.Channel(ctx, userID, eventType)
+	if err != nil || channel != database.NotificationChannelEMAIL {
+		return err
+	}
+	status, err := s.presence.Status(ctx, []uuid.UUID{userID})
+	if err != nil {
+		return err
+	}
+	if status[0].Online {
+		return nil
+	}
+
+	email, err = s.address(ctx, userID, email)
+	if err != nil {
+		return err
+	}
+	return s.mailer.EnqueueOnce(ctx, key+":"+userID.String(), offlineEmailWindow, email)
+}
+
+func (s *Service) email(ctx context.Context, userID uuid.UUID, email mailer.Email) error {
+	email, err := s.address(ctx, userID, email)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Enqueue(ctx, email)
+}
+
+// address fills in the recipient and the fields every template greets with
+func (s *Service) address(ctx context.Context, userID uuid.UUID, email mailer.Email) (mailer.Email, error) {
+	contact, err := s.store.GetUserContact(ctx, userID)
+	if err != nil {
+		return email, err
+	}
+
+	data := map[string]any{
+		"Name": contact.Name,
+		"Role": strings.ToLower(string(contact.Role)),
+	}
+	for k, v := range email.Data {
+		data[k] = v
+	}
+	email.To = contact.Email
+	email.Data = data
+	return email, nil
+}
+
+// ListNotifications returns a page of the user's inbox, newest first
+func (s *Service) ListNotifications(ctx context.Context, userID uuid.UUID, filter Filter, cursor string, limit int) (Page, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	params := database.ListNotificationsParams{
+		ReceiverID: userID.String(),
+		UnreadOnly: filter.UnreadOnly,
+		// one extra row tells whether there is a next page
+		PageSize: int32(limit + 1),
+	}
+	if filter.Subject != "" {
+		params.Subject = &filter.Subject
+	}
+	if filter.Method != "" {
+		method := database.Method(filter.Method)
+		if method != database.MethodSYSTEM && method != database.MethodEMAIL {
+			return Page{}, ErrInvalidMethod
+		}
+		params.Method = database.NullMethod{Method: method, Valid: true}
+	}
+	if cursor != "" {
+		createdAt, id, err := utils.DecodeCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		params.BeforeCreatedAt = &createdAt
+		params.BeforeID = &id
+	}
+
+	rows, err := s.store.ListNotifications(ctx, params)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Notifications: make([]websocket.Message, 0, min(len(rows), limit))}
+	for i, row := range rows {
+		if i == limit {
+			last := rows[i-1]
+			if last.CreatedAt != nil {
+				next := utils.EncodeCursor(*last.CreatedAt, last.ID)
+				page.NextCursor = &next
+			}
+			break
+		}
+		page.Notifications = append(page.Notifications, MapNotification(row))
+	}
+	return page, nil
+}
+
+func (s *Service) UnreadCount(ctx context.Context, userID uuid.UUID) (int, error) {
+	count, err := s.store.CountUnreadNotifications(ctx, userID.String())
+	return int(count), err
+}
+
+func (s *Service) MarkRead(ctx context.Context, userID, notificationID uuid.UUID) (websocket.Message, error) {
+	n, err := s.store.MarkNotificationRead(ctx, database.MarkNotificationReadParams{
+		ID:         notificationID,
+		ReceiverID: userID.String(),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return websocket.Message{}, ErrNotFound
+	}
+	if err != nil {
+		return websocket.Message{}, err
+	}
+
+	s.pushReadState(ctx, userID, "notification_read", []uuid.UUID{n.ID})
+	return MapNotification(n), nil
+}
+
+// MarkAllRead marks the whole inbox as read and returns how many changed
+func (s *Service) MarkAllRead(ctx context.Context, userID uuid.UUID) (int, error) {
+	ids, err := s.store.MarkAllNotificationsRead(ctx, userID.String())
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ids) > 0 {
+		s.pushReadState(ctx, userID, "notification_read_all", ids)
+	}
+	return len(ids), nil
+}
+
+func (s *Service) DeleteNotification(ctx context.Context, userID, notificationID uuid.UUID) error {
+	n, err := s.store.DeleteNotification(ctx, database.DeleteNotificationParams{
+		ID:         notificationID,
+		ReceiverID: userID.String(),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	s.pushReadState(ctx, userID, "notification_deleted", []uuid.UUID{n.ID})
+	return nil
+}
+
+func (s *Service) pushReadState(ctx context.Context, userID uuid.UUID, messageType string, ids []uuid.UUID) {
+	unread, err := s.UnreadCount(ctx, userID)
+	if err != nil {
+		log.Printf("failed to count unread notifications of %s: %v", userID, err)
+		return
+	}
+	s.wsNotif.SendEvent(userID.String(), ReadStateEvent{
+		MessageType: messageType,
+		IDs:         ids,
+		UnreadCount: unread,
+	})
+}
@@ -0,0 +1,200 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	_ "time/tzdata" // timezones of the users do not depend on the host
+
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Event types a user can set a channel for
+const (
+	EventGeneral         = "general"
+	EventTaskBlocked     = "task_blocked"
+	EventDeadlineWarning = "deadline_warning"
+	EventChatMessage     = "chat_message"
+)
+
+// eventDefaults is the channel of every event type for users that did not
+// choose one.
+var eventDefaults = map[string]database.NotificationChannel{
+	EventGeneral:         database.NotificationChannelINAPP,
+	EventTaskBlocked:     database.NotificationChannelEMAIL,
+	EventDeadlineWarning: database.NotificationChannelEMAIL,
+	EventChatMessage:     database.NotificationChannelEMAIL,
+}
+
+var ErrInvalidPreference = errors.New("invalid notification preference")
+
+type EventPreference struct {
+	EventType string                       `json:"eventType"`
+	Channel   database.NotificationChannel `json:"channel"`
+	Default   bool                         `json:"default"`
+}
+
+type Settings struct {
+	DigestFrequency database.DigestFrequency `json:"digestFrequency"`
+	Timezone        string                   `json:"timezone"`
+	QuietHoursStart *int32                   `json:"quietHoursStart"`
+	QuietHoursEnd   *int32                   `json:"quietHoursEnd"`
+}
+
+type Preferences struct {
+	Settings Settings          `json:"settings"`
+	Events   []EventPreference `json:"events"`
+}
+
+// PreferencesUpdate changes the given events, and the settings if set
+type PreferencesUpdate struct {
+	Settings *Settings         `json:"settings"`
+	Events   []EventPreference `json:"events"`
+}
+
+func defaultSettings() Settings {
+	return Settings{DigestFrequency: database.DigestFrequencyDAILY, Timezone: "UTC"}
+}
+
+// Channel returns how the user wants to be told about events of this type
+func (s *Service) Channel(ctx context.Context, userID uuid.UUID, eventType string) (database.NotificationChannel, error) {
+	channel, err := s.store.GetNotificationChannel(ctx, database.GetNotificationChannelParams{
+		UserID:    userID,
+		EventType: eventType,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		if channel, ok := eventDefaults[eventType]; ok {
+			return channel, nil
+		}
+		return database.NotificationChannelINAPP, nil
+	}
+	return channel, err
+}
+
+func (s *Service) GetPreferences(ctx context.Context, userID uuid.UUID) (Preferences, error) {
+	prefs := Preferences{Settings: defaultSettings()}
+
+	settings, err := s.store.GetNotificationSettings(ctx, userID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return Preferences{}, err
+	}
+	if err == nil {
+		prefs.Settings = Settings{
+			DigestFrequency: settings.DigestFrequency,
+			Timezone:        settings.Timezone,
+			QuietHoursStart: settings.QuietHoursStart,
+			QuietHoursEnd:   settings.QuietHoursEnd,
+		}
+	}
+
+	rows, err := s.store.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return Preferences{}, err
+	}
+	chosen := make(map[string]database.NotificationChannel, len(rows))
+	for _, row := range rows {
+		chosen[row.EventType] = row.Channel
+	}
+
+	for eventType, channel := range eventDefaults {
+		pref := EventPreference{EventType: eventType, Channel: channel, Default: true}
+		if c, ok := chosen[eventType]; ok {
+			pref.Channel, pref.Default = c, false
+		}
+		prefs.Events = append(prefs.Events, pref)
+	}
+	sort.Slice(prefs.Events, func(i, j int) bool {
+		return prefs.Events[i].EventType < prefs.Events[j].EventType
+	})
+	return prefs, nil
+}
+
+func (s *Service) UpdatePreferences(ctx context.Context, userID uuid.UUID, update PreferencesUpdate) (Preferences, error) {
+	for _, pref := range update.Events {
+		if _, ok := eventDefaults[pref.EventType]; !ok {
+			return Preferences{}, fmt.Errorf("%w: unknown event type %q", ErrInvalidPreference, pref.EventType)
+		}
+		if !validChannel(pref.Channel) {
+			return Preferences{}, fmt.Errorf("%w: unknown channel %q", ErrInvalidPreference, pref.Channel)
+		}
+	}
+	if update.Settings != nil {
+		if err := validateSettings(*update.Settings); err != nil {
+			return Preferences{}, err
+		}
+	}
+
+	for _, pref := range update.Events {
+		if err := s.store.UpsertNotificationPreference(ctx, database.UpsertNotificationPreferenceParams{
+			UserID:    userID,
+			EventType: pref.EventType,
+			Channel:   pref.Channel,
+		}); err != nil {
+			return Preferences{}, err
+		}
+	}
+	if settings := update.Settings; settings != nil {
+		if _, err := s.store.UpsertNotificationSettings(ctx, database.UpsertNotificationSettingsParams{
+			UserID:          userID,
+			DigestFrequency: settings.DigestFrequency,
+			Timezone:        settings.Timezone,
+			QuietHoursStart: settings.QuietHoursStart,
+			QuietHoursEnd:   settings.QuietHoursEnd,
+		}); err != nil {
+			return Preferences{}, err
+		}
+	}
+
+	return s.GetPreferences(ctx, userID)
+}
+
+func validateSettings(settings Settings) error {
+	switch settings.DigestFrequency {
+	case database.DigestFrequencyHOURLY, database.DigestFrequencyDAILY, database.DigestFrequencyNEVER:
+	default:
+		return fmt.Errorf("%w: unknown digest frequency %q", ErrInvalidPreference, settings.DigestFrequency)
+	}
+	if _, err := time.LoadLocation(settings.Timezone); err != nil || settings.Timezone == "" {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPreference, settings.Timezone)
+	}
+	if (settings.QuietHoursStart == nil) != (settings.QuietHoursEnd == nil) {
+		return fmt.Errorf("%w: quiet hours need both a start and an end", ErrInvalidPreference)
+	}
+	for _, hour := range []*int32{settings.QuietHoursStart, settings.QuietHoursEnd} {
+		if hour != nil && (*hour < 0 || *hour > 23) {
+			return fmt.Errorf("%w: quiet hours must be between 0 and 23", ErrInvalidPreference)
+		}
+	}
+	return nil
+}
+
+func validChannel(channel database.NotificationChannel) bool {
+	switch channel {
+	case database.NotificationChannelINAPP, database.NotificationChannelEMAIL,
+		database.NotificationChannelDIGEST, database.NotificationChannelMUTED:
+		return true
+	}
+	return false
+}
+
+// inQuietHours tells whether t falls in the [start, end) hours of the day
+// in the timezone, which wrap around midnight when start > end.
+func inQuietHours(t time.Time, timezone string, start, end *int32) bool {
+	if start == nil || end == nil || *start == *end {
+		return false
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	hour := int32(t.In(loc).Hour())
+	if *start < *end {
+		return hour >= *start && hour < *end
+	}
+	return hour >= *start || hour < *end
+}
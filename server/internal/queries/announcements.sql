@@ -0,0 +1,27 @@
+-- name: CreateAnnouncement :one
+INSERT INTO announcements (sender_id, role, title, content, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListActiveAnnouncements :many
+SELECT *
+FROM announcements
+WHERE expires_at > NOW()
+  AND (
+    role IS NULL
+    OR role = sqlc.narg('role')::role
+  )
+ORDER BY created_at DESC;
+
+-- name: ListAllActiveAnnouncements :many
+SELECT *
+FROM announcements
+WHERE expires_at > NOW()
+ORDER BY created_at DESC;
+
+-- name: ExpireAnnouncement :one
+UPDATE announcements
+SET expires_at = NOW()
+WHERE id = $1
+  AND expires_at > NOW()
+RETURNING *;
@@ -0,0 +1,49 @@
+-- name: CreateCall :one
+INSERT INTO mentor_session_calls (session_id, caller_id, callee_id)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetLiveCall :one
+SELECT * FROM mentor_session_calls
+WHERE session_id = $1
+  AND status IN ('RINGING', 'ACTIVE');
+
+-- name: AcceptCall :one
+UPDATE mentor_session_calls
+SET status = 'ACTIVE',
+    answered_at = now()
+WHERE session_id = $1
+  AND callee_id = $2
+  AND status = 'RINGING'
+RETURNING *;
+
+-- name: RejectCall :one
+UPDATE mentor_session_calls
+SET status = 'REJECTED',
+    ended_at = now()
+WHERE session_id = $1
+  AND callee_id = $2
+  AND status = 'RINGING'
+RETURNING *;
+
+-- name: EndCall :one
+UPDATE mentor_session_calls
+SET status = CASE WHEN status = 'ACTIVE' THEN 'ENDED'::call_status ELSE 'CANCELED'::call_status END,
+    ended_at = now(),
+    duration_seconds = CASE
+      WHEN answered_at IS NULL THEN 0
+      ELSE EXTRACT(EPOCH FROM now() - answered_at)::int
+    END
+WHERE session_id = sqlc.arg(session_id)
+  AND (caller_id = sqlc.arg(user_id) OR callee_id = sqlc.arg(user_id))
+  AND status IN ('RINGING', 'ACTIVE')
+RETURNING *;
+
+-- name: MissRingingCalls :many
+UPDATE mentor_session_calls
+SET status = 'MISSED',
+    ended_at = now()
+WHERE status = 'RINGING'
+  AND started_at < sqlc.arg(rang_before)
+  AND (sqlc.narg(session_id)::uuid IS NULL OR session_id = sqlc.narg(session_id))
+RETURNING *;
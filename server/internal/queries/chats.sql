@@ -27,17 +27,37 @@ VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
 -- name: GetChatWithFilesByID :one
+SELECT *
+FROM mentorship_chat_details c
+WHERE c.id = $1
+ORDER BY c.created_at ASC;
+
+-- name: ListSessionChatsBefore :many
+SELECT *
+FROM mentorship_chat_details c
+WHERE c.seesion_id = sqlc.arg(session_id)
+  AND (
+    sqlc.narg(before_created_at)::pg_catalog.timestamptz IS NULL
+    OR (c.created_at, c.id) < (sqlc.narg(before_created_at)::pg_catalog.timestamptz, sqlc.narg(before_id)::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListSessionChatsAfter :many
+SELECT *
+FROM mentorship_chat_details c
+WHERE c.seesion_id = sqlc.arg(session_id)
+  AND (c.created_at, c.id) > (sqlc.arg(after_created_at)::pg_catalog.timestamptz, sqlc.arg(after_id)::uuid)
+ORDER BY c.created_at ASC, c.id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: SearchUserChats :many
 SELECT
   c.id,
-  c.created_at,
   c.seesion_id,
   c.message,
   c.sent_by,
-  c.sent_to,
-  c.read_at,
-  c.pending,
-  c.is_deleted,
-
+  c.created_at,
   (
     json_build_object(
       'id', u.id,
@@ -46,32 +66,59 @@ SELECT
       'image', u.image,
       'email', u.email
     )
-  )::jsonb AS chat_owner,
-
-  COALESCE(
-    (
-      SELECT jsonb_agg(
-        jsonb_build_object(
-          'id', f.id,
-          'fileName', f.file_name,
-          'fileType', f.file_type,
-          'fileSize', f.file_size,
-          'filePath', f.file_path,
-          'uploadedAt', f.uploaded_at,
-          'uploadedById', f.uploaded_by_id,
-          'chatId', f.chat_id
-        )
-      )
-      FROM mentorship_chat_files f
-      WHERE f.chat_id = c.id
-    ),
-    '[]'::jsonb
-  )::jsonb AS chat_files
-
+  )::jsonb AS chat_owner
 FROM mentorship_chats c
 JOIN users u ON u.id = c.sent_by
-WHERE c.id = $1
-ORDER BY c.created_at ASC;
+JOIN mentor_session s ON s.id = c.seesion_id
+JOIN mentorship_bookings b ON b.id = s.booking_id
+WHERE (b.solver_id = sqlc.arg(user_id) OR b.student_id = sqlc.arg(user_id))
+  AND (sqlc.narg(session_id)::uuid IS NULL OR c.seesion_id = sqlc.narg(session_id)::uuid)
+  AND COALESCE(c.is_deleted, false) = false
+  AND to_tsvector('simple', COALESCE(c.message, '')) @@ websearch_to_tsquery('simple', sqlc.arg(query))
+  AND (
+    sqlc.narg(before_created_at)::pg_catalog.timestamptz IS NULL
+    OR (c.created_at, c.id) < (sqlc.narg(before_created_at)::pg_catalog.timestamptz, sqlc.narg(before_id)::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetChatForUpdate :one
+SELECT id, seesion_id, message, sent_by, sent_to, is_deleted
+FROM mentorship_chats
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetChatParticipants :one
+SELECT id, seesion_id, sent_by, sent_to, is_deleted
+FROM mentorship_chats
+WHERE id = $1;
+
+-- name: CreateChatEdit :exec
+INSERT INTO mentorship_chat_edits (chat_id, previous_message)
+VALUES ($1, $2);
+
+-- name: UpdateChatMessage :exec
+UPDATE mentorship_chats
+SET message = $2
+WHERE id = $1;
+
+-- name: CreateChatReply :exec
+INSERT INTO mentorship_chat_replies (chat_id, reply_to_id)
+VALUES ($1, $2);
+
+-- name: AddChatReaction :execrows
+INSERT INTO mentorship_chat_reactions (chat_id, user_id, emoji)
+VALUES ($1, $2, $3)
+ON CONFLICT (chat_id, user_id, emoji) DO NOTHING;
+
+-- name: RemoveChatReaction :execrows
+DELETE FROM mentorship_chat_reactions
+WHERE chat_id = $1 AND user_id = $2 AND emoji = $3;
+
+-- name: CountUserChatReactions :one
+SELECT COUNT(*)::int AS reactions
+FROM mentorship_chat_reactions
+WHERE chat_id = $1 AND user_id = $2;
 
 -- name: DeleteChatFileByPath :exec
 DELETE FROM mentorship_chat_files WHERE file_path = $1;
@@ -119,3 +166,35 @@ SELECT
         FROM deleted_file_action f
     )::text[] AS deleted_file_paths
 FROM updated_chat_action c;
+
+-- name: GetMentorSessionParticipants :one
+SELECT
+  s.id,
+  s.session_start,
+  s.session_end,
+  b.solver_id,
+  b.student_id,
+  b.status
+FROM mentor_session s
+JOIN mentorship_bookings b ON b.id = s.booking_id
+WHERE s.id = $1;
+
+-- name: MarkChatsReadUpTo :many
+UPDATE mentorship_chats c
+SET read_at = now()
+WHERE c.seesion_id = sqlc.arg(session_id)
+  AND c.sent_to = sqlc.arg(reader_id)
+  AND c.read_at IS NULL
+  AND c.created_at <= (
+    SELECT up_to.created_at FROM mentorship_chats up_to
+    WHERE up_to.id = sqlc.arg(up_to_id) AND up_to.seesion_id = sqlc.arg(session_id)
+  )
+RETURNING c.id, c.read_at;
+
+-- name: GetUnreadChatCounts :many
+SELECT seesion_id, COUNT(*)::int AS unread
+FROM mentorship_chats
+WHERE sent_to = $1
+  AND read_at IS NULL
+  AND COALESCE(is_deleted, false) = false
+GROUP BY seesion_id;
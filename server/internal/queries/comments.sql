@@ -0,0 +1,59 @@
+-- name: CreateTaskComment :one
+INSERT INTO task_comments (task_id, user_id, content)
+VALUES ($1, $2, $3)
+RETURNING id;
+
+-- name: GetTaskCommentWithOwner :one
+SELECT
+  c.id,
+  c.task_id,
+  c.user_id,
+  c.content,
+  c.created_at,
+  (
+    json_build_object(
+      'id', u.id,
+      'name', u.name,
+      'role', u.role,
+      'image', u.image,
+      'email', u.email
+    )
+  )::jsonb AS owner
+FROM task_comments c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = $1;
+
+-- name: ListTaskComments :many
+SELECT
+  c.id,
+  c.task_id,
+  c.user_id,
+  c.content,
+  c.created_at,
+  (
+    json_build_object(
+      'id', u.id,
+      'name', u.name,
+      'role', u.role,
+      'image', u.image,
+      'email', u.email
+    )
+  )::jsonb AS owner
+FROM task_comments c
+JOIN users u ON u.id = c.user_id
+WHERE c.task_id = sqlc.arg(task_id)
+  AND (
+    sqlc.narg(before_created_at)::pg_catalog.timestamptz IS NULL
+    OR (c.created_at, c.id) < (sqlc.narg(before_created_at)::pg_catalog.timestamptz, sqlc.narg(before_id)::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: UpdateTaskComment :exec
+UPDATE task_comments
+SET content = $2
+WHERE id = $1;
+
+-- name: DeleteTaskComment :exec
+DELETE FROM task_comments
+WHERE id = $1;
@@ -0,0 +1,3 @@
+-- name: CreateLog :exec
+INSERT INTO logs ("createdAt", level, message, error)
+VALUES (NOW(), $1, $2, $3);
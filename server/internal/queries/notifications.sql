@@ -0,0 +1,151 @@
+-- name: ListNotifications :many
+SELECT *
+FROM notifications
+WHERE receiver_id = sqlc.arg(receiver_id)
+  AND (sqlc.narg(subject)::text IS NULL OR subject = sqlc.narg(subject)::text)
+  AND (sqlc.narg(method)::method IS NULL OR method = sqlc.narg(method)::method)
+  AND (sqlc.arg(unread_only)::boolean = false OR read = false)
+  AND (
+    sqlc.narg(before_created_at)::pg_catalog.timestamptz IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at)::pg_catalog.timestamptz, sqlc.narg(before_id)::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*)::int
+FROM notifications
+WHERE receiver_id = $1
+  AND read = false;
+
+-- name: MarkNotificationRead :one
+UPDATE notifications
+SET read = true
+WHERE id = $1
+  AND receiver_id = $2
+RETURNING *;
+
+-- name: MarkAllNotificationsRead :many
+UPDATE notifications
+SET read = true
+WHERE receiver_id = $1
+  AND read = false
+RETURNING id;
+
+-- name: DeleteNotification :one
+DELETE FROM notifications
+WHERE id = $1
+  AND receiver_id = $2
+RETURNING *;
+
+-- name: GetNotification :one
+SELECT *
+FROM notifications
+WHERE id = $1;
+
+-- name: MarkNotificationDispatched :execrows
+UPDATE notifications
+SET dispatched_at = NOW()
+WHERE id = $1
+  AND dispatched_at IS NULL;
+
+-- name: MarkNotificationEmailed :exec
+UPDATE notifications
+SET method = 'EMAIL'
+WHERE id = $1;
+
+-- name: GetNotificationChannel :one
+SELECT channel
+FROM notification_preferences
+WHERE user_id = $1
+  AND event_type = $2;
+
+-- name: ListNotificationPreferences :many
+SELECT event_type,
+  channel
+FROM notification_preferences
+WHERE user_id = $1
+ORDER BY event_type;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, event_type, channel)
+VALUES ($1, $2, $3) ON CONFLICT (user_id, event_type) DO
+UPDATE
+SET channel = EXCLUDED.channel,
+  updated_at = NOW();
+
+-- name: GetNotificationSettings :one
+SELECT *
+FROM notification_settings
+WHERE user_id = $1;
+
+-- name: UpsertNotificationSettings :one
+INSERT INTO notification_settings (
+    user_id,
+    digest_frequency,
+    timezone,
+    quiet_hours_start,
+    quiet_hours_end
+  )
+VALUES ($1, $2, $3, $4, $5) ON CONFLICT (user_id) DO
+UPDATE
+SET digest_frequency = EXCLUDED.digest_frequency,
+  timezone = EXCLUDED.timezone,
+  quiet_hours_start = EXCLUDED.quiet_hours_start,
+  quiet_hours_end = EXCLUDED.quiet_hours_end,
+  updated_at = NOW()
+RETURNING *;
+
+-- name: ListDigestCandidates :many
+-- users with unread notifications that were neither emailed nor digested
+-- yet, and whose digest is due
+SELECT u.id,
+  COALESCE(s.digest_frequency, 'DAILY')::digest_frequency AS digest_frequency,
+  COALESCE(s.timezone, 'UTC')::text AS timezone,
+  s.quiet_hours_start,
+  s.quiet_hours_end
+FROM users u
+  LEFT JOIN notification_settings s ON s.user_id = u.id
+WHERE u.id > sqlc.arg(after_id)
+  AND COALESCE(s.digest_frequency, 'DAILY') <> 'NEVER'
+  AND (
+    s.last_digest_at IS NULL
+    OR s.last_digest_at <= NOW() - CASE
+      WHEN s.digest_frequency = 'HOURLY' THEN INTERVAL '1 hour'
+      ELSE INTERVAL '1 day'
+    END
+  )
+  AND EXISTS (
+    SELECT 1
+    FROM notifications n
+    WHERE n.receiver_id = u.id::text
+      AND NOT n.read
+      AND n.method = 'SYSTEM'
+      AND n.digested_at IS NULL
+      AND n.created_at <= sqlc.arg(created_before)::pg_catalog.timestamptz
+  )
+ORDER BY u.id
+LIMIT sqlc.arg(batch_size);
+
+-- name: ListDigestNotifications :many
+SELECT *
+FROM notifications
+WHERE receiver_id = sqlc.arg(receiver_id)
+  AND NOT read
+  AND method = 'SYSTEM'
+  AND digested_at IS NULL
+  AND created_at <= sqlc.arg(created_before)::pg_catalog.timestamptz
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: MarkNotificationsDigested :exec
+UPDATE notifications
+SET digested_at = NOW()
+WHERE receiver_id = sqlc.arg(receiver_id)
+  AND id = ANY(sqlc.arg(ids)::uuid [ ]);
+
+-- name: TouchNotificationDigest :exec
+INSERT INTO notification_settings (user_id, last_digest_at)
+VALUES ($1, NOW()) ON CONFLICT (user_id) DO
+UPDATE
+SET last_digest_at = NOW();
@@ -0,0 +1,28 @@
+-- name: UpsertPushSubscription :one
+INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth, user_agent)
+VALUES ($1, $2, $3, $4, $5) ON CONFLICT (endpoint) DO
+UPDATE
+SET user_id = EXCLUDED.user_id,
+  p256dh = EXCLUDED.p256dh,
+  auth = EXCLUDED.auth,
+  user_agent = EXCLUDED.user_agent
+RETURNING *;
+
+-- name: ListPushSubscriptions :many
+SELECT *
+FROM push_subscriptions
+WHERE user_id = $1;
+
+-- name: DeletePushSubscription :execrows
+DELETE FROM push_subscriptions
+WHERE user_id = $1
+  AND endpoint = $2;
+
+-- name: PrunePushSubscription :exec
+DELETE FROM push_subscriptions
+WHERE id = $1;
+
+-- name: TouchPushSubscription :exec
+UPDATE push_subscriptions
+SET last_used_at = NOW()
+WHERE id = $1;
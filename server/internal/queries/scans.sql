@@ -0,0 +1,48 @@
+-- name: CreateFileScan :exec
+INSERT INTO file_scans (file_path, user_id)
+VALUES ($1, $2)
+ON CONFLICT (file_path) DO NOTHING;
+
+-- name: GetFileScanStatus :one
+SELECT status FROM file_scans WHERE file_path = $1;
+
+-- name: ClaimFileScans :many
+-- PENDING files, and the PROCESSING ones of a worker that died mid scan
+UPDATE file_scans
+SET status = 'PROCESSING', updated_at = now()
+WHERE file_path IN (
+  SELECT pending.file_path FROM file_scans pending
+  WHERE pending.status = 'PENDING'
+     OR (pending.status = 'PROCESSING' AND pending.updated_at < sqlc.arg(stale_before))
+  ORDER BY pending.created_at
+  LIMIT sqlc.arg(batch_size)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteFileScan :exec
+UPDATE file_scans
+SET status = 'COMPLETED', last_error = NULL, scanned_at = now(), updated_at = now()
+WHERE file_path = $1;
+
+-- name: FailFileScan :exec
+UPDATE file_scans
+SET status = 'FAILED', signature = $2, last_error = $3, quarantine_path = $4,
+    scanned_at = now(), updated_at = now()
+WHERE file_path = $1;
+
+-- name: RetryFileScan :exec
+UPDATE file_scans
+SET status = 'PENDING', attempts = attempts + 1, last_error = $2, updated_at = now()
+WHERE file_path = $1;
+
+-- name: DeleteFileScan :exec
+DELETE FROM file_scans WHERE file_path = $1 AND quarantine_path IS NULL;
+
+-- name: GetAllQuarantinedFilePaths :many
+SELECT quarantine_path::text FROM file_scans WHERE quarantine_path IS NOT NULL;
+
+-- name: SetWorkspaceFileStatus :exec
+UPDATE solution_workspace_files
+SET status = sqlc.arg(status)::file_status, updated_at = now()
+WHERE file_path = $1;
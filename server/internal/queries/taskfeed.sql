@@ -0,0 +1,91 @@
+-- name: GetTaskFeedSubscription :one
+SELECT *
+FROM task_feed_subscriptions
+WHERE user_id = $1;
+
+-- name: UpsertTaskFeedSubscription :one
+INSERT INTO task_feed_subscriptions (user_id, category_ids)
+VALUES ($1, $2) ON CONFLICT (user_id) DO
+UPDATE
+SET category_ids = EXCLUDED.category_ids,
+  updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteTaskFeedSubscription :execrows
+DELETE FROM task_feed_subscriptions
+WHERE user_id = $1;
+
+-- name: GetFeedTask :one
+SELECT t.id,
+  t.title,
+  t.description,
+  t.price,
+  t.deadline,
+  t.poster_id,
+  t.category_id,
+  c.name AS category_name,
+  t.created_at
+FROM tasks t
+  JOIN task_categories c ON c.id = t.category_id
+WHERE t.id = $1
+  AND t.task_status = 'OPEN'
+  AND t.visibility = 'public';
+
+-- name: ListNewFeedTasks :many
+-- Open public tasks created since created_after, for the publish job to
+-- announce whichever were not announced yet.
+SELECT t.id,
+  t.title,
+  t.description,
+  t.price,
+  t.deadline,
+  t.poster_id,
+  t.category_id,
+  c.name AS category_name,
+  t.created_at
+FROM tasks t
+  JOIN task_categories c ON c.id = t.category_id
+WHERE t.created_at > sqlc.arg(created_after)
+  AND t.task_status = 'OPEN'
+  AND t.visibility = 'public'
+ORDER BY t.created_at;
+
+-- name: ListTaskFeedRecipients :many
+-- Subscribed solvers whose categories and skills match an open public task.
+-- Solvers without categories take every category, and those without skills
+-- every task of them. A skill matches when the category, title or
+-- description mentions it.
+SELECT s.user_id
+FROM tasks t
+  JOIN task_categories c ON c.id = t.category_id
+  JOIN task_feed_subscriptions s ON cardinality(s.category_ids) = 0
+  OR t.category_id = ANY(s.category_ids)
+  JOIN users u ON u.id = s.user_id
+  LEFT JOIN solver_profile p ON p.user_id = s.user_id
+WHERE t.id = $1
+  AND t.task_status = 'OPEN'
+  AND t.visibility = 'public'
+  AND u.role = 'SOLVER'
+  AND s.user_id <> t.poster_id
+  AND NOT EXISTS (
+    SELECT 1
+    FROM blocked_tasks b
+    WHERE b.task_id = t.id
+      AND b.user_id = s.user_id
+  )
+  AND (
+    COALESCE(cardinality(p.skills), 0) = 0
+    OR EXISTS (
+      SELECT 1
+      FROM unnest(p.skills) AS skill
+      WHERE skill <> ''
+        AND position(
+          lower(skill) IN lower(c.name || ' ' || t.title || ' ' || t.description)
+        ) > 0
+    )
+  );
+
+-- name: CountTaskCategories :one
+SELECT count(*)
+FROM task_categories
+WHERE id = ANY(@ids::uuid [ ]);
@@ -46,3 +46,8 @@ SET "uploadedFiles" = (
   WHERE elem->>'filePath' <> (SELECT target_path FROM definition)
 )
 WHERE user_id = (SELECT target_user_id FROM definition);
+
+-- name: GetTaskAccess :one
+SELECT id, poster_id, solver_id, visibility
+FROM tasks
+WHERE id = $1;
@@ -0,0 +1,61 @@
+-- name: CreateTusUpload :one
+INSERT INTO tus_uploads (
+  user_id,
+  scope,
+  target_id,
+  file_name,
+  file_type,
+  file_size,
+  file_path,
+  multipart_id,
+  expires_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetTusUpload :one
+SELECT * FROM tus_uploads
+WHERE id = $1;
+
+-- name: AdvanceTusUpload :one
+UPDATE tus_uploads
+SET upload_offset = sqlc.arg(upload_offset),
+    parts = sqlc.arg(parts),
+    tail_size = sqlc.arg(tail_size),
+    expires_at = sqlc.arg(expires_at)
+WHERE id = sqlc.arg(id)
+  AND upload_offset = sqlc.arg(previous_offset)
+  AND completed_at IS NULL
+RETURNING *;
+
+-- name: CompleteTusUpload :one
+UPDATE tus_uploads
+SET upload_offset = file_size,
+    tail_size = 0,
+    completed_at = now()
+WHERE id = $1
+  AND completed_at IS NULL
+RETURNING *;
+
+-- name: DeleteTusUpload :exec
+DELETE FROM tus_uploads
+WHERE id = $1;
+
+-- name: ListExpiredTusUploads :many
+SELECT * FROM tus_uploads
+WHERE completed_at IS NULL
+  AND expires_at <= now()
+ORDER BY expires_at
+LIMIT $1;
+
+-- name: PruneCompletedTusUploads :execrows
+DELETE FROM tus_uploads
+WHERE completed_at IS NOT NULL
+  AND completed_at < $1;
+
+-- name: GetAllPendingTusFilePaths :many
+SELECT file_path FROM tus_uploads
+WHERE completed_at IS NULL
+UNION ALL
+SELECT file_path || '.part' FROM tus_uploads
+WHERE completed_at IS NULL
+  AND tail_size > 0;
@@ -0,0 +1,53 @@
+-- name: CreateUploadIntent :one
+INSERT INTO upload_intents (
+  user_id,
+  scope,
+  target_id,
+  file_name,
+  file_type,
+  file_size,
+  file_path,
+  expires_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetUploadIntent :one
+SELECT * FROM upload_intents
+WHERE id = $1;
+
+-- name: ConfirmUploadIntent :one
+UPDATE upload_intents
+SET confirmed_at = now()
+WHERE id = $1
+  AND confirmed_at IS NULL
+  AND expires_at > now()
+RETURNING *;
+
+-- name: ReleaseUploadIntent :exec
+UPDATE upload_intents
+SET confirmed_at = NULL
+WHERE id = $1;
+
+-- name: ListExpiredUploadIntents :many
+SELECT * FROM upload_intents
+WHERE confirmed_at IS NULL
+  AND expires_at <= now()
+ORDER BY expires_at
+LIMIT $1;
+
+-- name: DeleteUploadIntent :exec
+DELETE FROM upload_intents
+WHERE id = $1;
+
+-- name: PruneConfirmedUploadIntents :execrows
+DELETE FROM upload_intents
+WHERE confirmed_at IS NOT NULL
+  AND confirmed_at < $1;
+
+-- name: GetAllPendingUploadFilePaths :many
+SELECT file_path FROM upload_intents
+WHERE confirmed_at IS NULL;
+
+-- name: GetWorkspaceSolver :one
+SELECT solver_id FROM solution_workspaces
+WHERE id = $1;
@@ -9,11 +9,21 @@ INSERT INTO notifications (
     subject,
     content,
     method,
-    read
+    read,
+    event_type,
+    dispatched_at
   )
-VALUES ($1, $2, $3, $4, 'SYSTEM', $5)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
 RETURNING *;
 
+-- name: GetUserContact :one
+SELECT id,
+  name,
+  email,
+  role
+FROM users
+WHERE id = $1;
+
 -- name: AddSolverToTaskBlockList :one
 INSERT INTO blocked_tasks (user_id, task_id, reason)
 VALUES ($1, $2, $3) ON CONFLICT (user_id, task_id) DO NOTHING
@@ -0,0 +1,144 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the INSTREAM chunks sent to clamd
+const chunkSize = 64 << 10
+
+// dialTimeout bounds the connection to clamd, the scan itself is bounded by
+// the context
+const dialTimeout = 5 * time.Second
+
+var ErrScanner = errors.New("scanner error")
+
+// Result is the verdict of clamd on a stream
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Client talks to clamd, or anything speaking its protocol, over a unix or
+// a tcp socket. Every command opens its own connection.
+type Client struct {
+	network string
+	address string
+}
+
+// NewClient parses the address of clamd: unix:/path/to/clamd.sock,
+// tcp://host:port or host:port
+func NewClient(address string) (*Client, error) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		return &Client{network: "unix", address: strings.TrimPrefix(address, "unix://")}, nil
+	case strings.HasPrefix(address, "unix:"):
+		return &Client{network: "unix", address: strings.TrimPrefix(address, "unix:")}, nil
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "tcp:"):
+		address = strings.TrimPrefix(address, "tcp:")
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid clamd address %q: %w", address, err)
+	}
+	return &Client{network: "tcp", address: address}, nil
+}
+
+// Ping checks that clamd answers
+func (c *Client) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("%w: unexpected reply to PING: %q", ErrScanner, reply)
+	}
+	return nil
+}
+
+// Scan streams r to clamd with INSTREAM. The stream is refused by clamd
+// when it is longer than its StreamMaxLength.
+func (c *Client) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	reply, err := c.command(ctx, "INSTREAM", r)
+	if err != nil {
+		return Result{}, err
+	}
+	return parseReply(reply)
+}
+
+func (c *Client) command(ctx context.Context, name string, body io.Reader) (string, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrScanner, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// the z prefix has clamd terminate its reply with a NUL as well
+	if _, err := io.WriteString(conn, "z"+name+"\x00"); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrScanner, err)
+	}
+	if body != nil {
+		if err := writeChunks(conn, body); err != nil {
+			return "", err
+		}
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", fmt.Errorf("%w: reading the reply: %v", ErrScanner, err)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// writeChunks sends body as length prefixed chunks, ended by an empty one
+func writeChunks(w io.Writer, body io.Reader) error {
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(body, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				// clamd hangs up once the stream is over its limit, its
+				// reply is still worth reading
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading the file: %w", err)
+		}
+	}
+	w.Write(make([]byte, 4))
+	return nil
+}
+
+// parseReply reads "stream: OK", "stream: <signature> FOUND" or an error
+// ending with ERROR
+func parseReply(reply string) (Result, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	case strings.HasSuffix(verdict, "ERROR"):
+		return Result{}, fmt.Errorf("%w: %s", ErrScanner, strings.TrimSpace(strings.TrimSuffix(verdict, "ERROR")))
+	default:
+		return Result{}, fmt.Errorf("%w: unexpected reply %q", ErrScanner, reply)
+	}
+}
@@ -0,0 +1,148 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStandInClient(t *testing.T) *Client {
+	t.Helper()
+	standIn, err := ListenStandIn("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenStandIn: %v", err)
+	}
+	t.Cleanup(func() { standIn.Close() })
+
+	client, err := NewClient(standIn.Address())
+	if err != nil {
+		t.Fatalf("NewClient(%q): %v", standIn.Address(), err)
+	}
+	return client
+}
+
+func TestScan(t *testing.T) {
+	client := newStandInClient(t)
+
+	tests := []struct {
+		name      string
+		body      io.Reader
+		infected  bool
+		signature string
+	}{
+		{name: "empty", body: strings.NewReader("")},
+		{name: "text", body: strings.NewReader("nothing to see here")},
+		{name: "several chunks", body: bytes.NewReader(bytes.Repeat([]byte("a"), 3*chunkSize+7))},
+		{name: "eicar", body: bytes.NewReader(eicar), infected: true, signature: "Eicar-Test-Signature"},
+		{name: "eicar inside a file", body: io.MultiReader(strings.NewReader("header "), bytes.NewReader(eicar), strings.NewReader(" trailer")),
+			infected: true, signature: "Eicar-Test-Signature"},
+		// the stand-in sees the whole stream, even where the chunks split it
+		{name: "eicar across chunks", body: io.MultiReader(bytes.NewReader(make([]byte, chunkSize-10)), bytes.NewReader(eicar)),
+			infected: true, signature: "Eicar-Test-Signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			result, err := client.Scan(ctx, tt.body)
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Errorf("Scan = %+v, want infected %v with %q", result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	client := newStandInClient(t)
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	standIn, err := ListenStandIn("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenStandIn: %v", err)
+	}
+	client, err := NewClient(standIn.Address())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	standIn.Close()
+
+	if err := client.Ping(context.Background()); !errors.Is(err, ErrScanner) {
+		t.Errorf("Ping error = %v, want ErrScanner", err)
+	}
+	if _, err := client.Scan(context.Background(), strings.NewReader("x")); !errors.Is(err, ErrScanner) {
+		t.Errorf("Scan error = %v, want ErrScanner", err)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		target  string
+		invalid bool
+	}{
+		{address: "unix:/run/clamav/clamd.ctl", network: "unix", target: "/run/clamav/clamd.ctl"},
+		{address: "unix:///run/clamav/clamd.ctl", network: "unix", target: "/run/clamav/clamd.ctl"},
+		{address: "tcp://clamav:3310", network: "tcp", target: "clamav:3310"},
+		{address: "tcp:clamav:3310", network: "tcp", target: "clamav:3310"},
+		{address: "127.0.0.1:3310", network: "tcp", target: "127.0.0.1:3310"},
+		{address: "clamav", invalid: true},
+		{address: "tcp://clamav", invalid: true},
+	}
+
+	for _, tt := range tests {
+		client, err := NewClient(tt.address)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("NewClient(%q) accepted an address without a port", tt.address)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewClient(%q): %v", tt.address, err)
+			continue
+		}
+		if client.network != tt.network || client.address != tt.target {
+			t.Errorf("NewClient(%q) = %s %s, want %s %s", tt.address, client.network, client.address, tt.network, tt.target)
+		}
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply  string
+		want   Result
+		failed bool
+	}{
+		{reply: "stream: OK", want: Result{}},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND", want: Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}},
+		{reply: "INSTREAM size limit exceeded. ERROR", failed: true},
+		{reply: "UNKNOWN COMMAND", failed: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseReply(tt.reply)
+		if tt.failed {
+			if !errors.Is(err, ErrScanner) {
+				t.Errorf("parseReply(%q) error = %v, want ErrScanner", tt.reply, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseReply(%q) = %+v, %v, want %+v", tt.reply, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package scan runs the uploaded files through clamd before they are served.
+// Files are queued as PENDING when stored, the job claims them as PROCESSING
+// and leaves them COMPLETED, or FAILED with infected ones quarantined.
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/api/websocket"
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/storage"
+)
+
+const (
+	batchSize = 10
+	// how long a single file may take, clamd included
+	scanTimeout = 2 * time.Minute
+	// a file PROCESSING for longer was left behind by a worker that stopped
+	staleAfter = batchSize*scanTimeout + 10*time.Minute
+	// the scanner errors a file may run into before it is failed for good
+	maxAttempts = 5
+
+	// QuarantinePrefix is where infected files are moved to, out of the
+	// reach of the tables that point at them
+	QuarantinePrefix = "quarantine/"
+)
+
+// StatusEvent is pushed over notif:<user> to the uploader whenever the scan
+// of one of their files changes status
+type StatusEvent struct {
+	MessageType string              `json:"messageType"`
+	FilePath    string              `json:"filePath"`
+	Status      database.FileStatus `json:"status"`
+	Signature   string              `json:"signature,omitempty"`
+}
+
+type Service struct {
+	store   *database.Queries
+	storage storage.Storage
+	clamd   *Client // nil when scanning is disabled
+	wsNotif *websocket.WsNotification
+}
+
+func NewService(store *database.Queries, objectStorage storage.Storage, clamd *Client, wsNotif *websocket.WsNotification) *Service {
+	return &Service{
+		store:   store,
+		storage: objectStorage,
+		clamd:   clamd,
+		wsNotif: wsNotif,
+	}
+}
+
+// Enabled reports whether a clamd is configured, without one the files are
+// passed unscanned
+func (s *Service) Enabled() bool {
+	return s.clamd != nil
+}
+
+// StartScanJob scans the queued files every interval, as many instances as
+// wanted can run it
+func (s *Service) StartScanJob(ctx context.Context, interval time.Duration) {
+	if !s.Enabled() {
+		log.Println("CLAMD_ADDRESS is not set, uploaded files are served without a malware scan")
+	}
+	ticker := time.NewTicker(interval)
+	log.Println("Starting Background Job for malware scans")
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Malware scan shutting down...")
+			return
+		case <-ticker.C:
+			// a claimed batch is finished even when shutting down, it would
+			// otherwise wait for staleAfter to be picked up again
+			s.runScans(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+func (s *Service) runScans(ctx context.Context) {
+	if s.Enabled() {
+		if err := s.clamd.Ping(ctx); err != nil {
+			log.Printf("Skipping malware scans, clamd is unavailable: %v", err)
+			return
+		}
+	}
+
+	claimed, err := s.store.ClaimFileScans(ctx, database.ClaimFileScansParams{
+		StaleBefore: time.Now().Add(-staleAfter),
+		BatchSize:   batchSize,
+	})
+	if err != nil {
+		log.Printf("Error claiming files to scan: %v", err)
+		return
+	}
+	for _, f := range claimed {
+		s.push(f, database.FileStatusPROCESSING, "")
+		s.scan(ctx, f)
+	}
+}
+
+func (s *Service) scan(ctx context.Context, f database.FileScan) {
+	if !s.Enabled() {
+		s.complete(ctx, f)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+
+	obj, err := s.storage.Get(ctx, f.FilePath, nil)
+	if errors.Is(err, storage.ErrNotFound) {
+		// deleted while it waited, there is nothing left to serve
+		if err := s.store.DeleteFileScan(ctx, f.FilePath); err != nil {
+			log.Printf("Failed to drop the scan of missing %s: %v", f.FilePath, err)
+		}
+		return
+	}
+	if err != nil {
+		s.retry(ctx, f, err)
+		return
+	}
+	result, err := s.clamd.Scan(ctx, obj)
+	obj.Close()
+	if err != nil {
+		s.retry(ctx, f, err)
+		return
+	}
+
+	if result.Infected {
+		s.quarantine(ctx, f, result.Signature)
+		return
+	}
+	s.complete(ctx, f)
+}
+
+func (s *Service) complete(ctx context.Context, f database.FileScan) {
+	if err := s.store.CompleteFileScan(ctx, f.FilePath); err != nil {
+		log.Printf("Failed to complete the scan of %s: %v", f.FilePath, err)
+		return
+	}
+	s.setStatus(ctx, f, database.FileStatusCOMPLETED, "")
+}
+
+// retry puts the file back in the queue, or fails it once it ran out of
+// attempts. A file that can not be scanned is not served either.
+func (s *Service) retry(ctx context.Context, f database.FileScan, cause error) {
+	reason := cause.Error()
+	log.Printf("Scan of %s failed (attempt %d): %v", f.FilePath, f.Attempts+1, cause)
+
+	if f.Attempts+1 >= maxAttempts {
+		err := s.store.FailFileScan(ctx, database.FailFileScanParams{
+			FilePath:  f.FilePath,
+			LastError: &reason,
+		})
+		if err != nil {
+			log.Printf("Failed to fail the scan of %s: %v", f.FilePath, err)
+			return
+		}
+		s.setStatus(ctx, f, database.FileStatusFAILED, "")
+		return
+	}
+
+	err := s.store.RetryFileScan(ctx, database.RetryFileScanParams{
+		FilePath:  f.FilePath,
+		LastError: &reason,
+	})
+	if err != nil {
+		log.Printf("Failed to requeue the scan of %s: %v", f.FilePath, err)
+		return
+	}
+	s.push(f, database.FileStatusPENDING, "")
+}
+
+// quarantine moves an infected file under QuarantinePrefix and fails it.
+// When the move does not go through the file stays where it is, failed all
+// the same so that it is never served.
+func (s *Service) quarantine(ctx context.Context, f database.FileScan, signature string) {
+	log.Printf("Malware %s found in %s", signature, f.FilePath)
+
+	var quarantinePath *string
+	if key, err := s.move(ctx, f.FilePath); err != nil {
+		log.Printf("Failed to quarantine %s: %v", f.FilePath, err)
+	} else {
+		quarantinePath = &key
+	}
+
+	err := s.store.FailFileScan(ctx, database.FailFileScanParams{
+		FilePath:       f.FilePath,
+		Signature:      &signature,
+		QuarantinePath: quarantinePath,
+	})
+	if err != nil {
+		log.Printf("Failed to fail the scan of %s: %v", f.FilePath, err)
+		return
+	}
+	s.setStatus(ctx, f, database.FileStatusFAILED, signature)
+}
+
+func (s *Service) move(ctx context.Context, key string) (string, error) {
+	obj, err := s.storage.Get(ctx, key, nil)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	target := QuarantinePrefix + key
+	if err := s.storage.Put(ctx, target, obj, obj.Size, obj.ContentType); err != nil {
+		return "", fmt.Errorf("copy to %s: %w", target, err)
+	}
+	if err := s.storage.Delete(ctx, key); err != nil {
+		return "", fmt.Errorf("delete after the copy to %s: %w", target, err)
+	}
+	return target, nil
+}
+
+// setStatus mirrors the status on the workspace files, the only table that
+// shows it, and tells the uploader
+func (s *Service) setStatus(ctx context.Context, f database.FileScan, status database.FileStatus, signature string) {
+	err := s.store.SetWorkspaceFileStatus(ctx, database.SetWorkspaceFileStatusParams{
+		FilePath: f.FilePath,
+		Status:   status,
+	})
+	if err != nil {
+		log.Printf("Failed to update the workspace file status of %s: %v", f.FilePath, err)
+	}
+	s.push(f, status, signature)
+}
+
+func (s *Service) push(f database.FileScan, status database.FileStatus, signature string) {
+	if f.UserID == nil {
+		return
+	}
+	s.wsNotif.SendEvent(f.UserID.String(), StatusEvent{
+		MessageType: "file_status",
+		FilePath:    f.FilePath,
+		Status:      status,
+		Signature:   signature,
+	})
+}
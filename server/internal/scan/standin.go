@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// standInMaxStream mirrors the StreamMaxLength clamd should be set up with,
+// the largest upload and some room
+const standInMaxStream = 64 << 20
+
+// eicar is the antivirus test file, the only thing the stand-in finds
+var eicar = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+// StandIn answers the PING and INSTREAM commands of clamd for development
+// and tests, it reports the EICAR test file and passes everything else
+type StandIn struct {
+	listener net.Listener
+}
+
+// ListenStandIn serves the stand-in on a tcp address, 127.0.0.1:0 picks a
+// free port
+func ListenStandIn(address string) (*StandIn, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s := &StandIn{listener: listener}
+	go s.serve()
+	return s, nil
+}
+
+// Address is the address to hand to NewClient
+func (s *StandIn) Address() string {
+	return "tcp://" + s.listener.Addr().String()
+}
+
+func (s *StandIn) Close() error {
+	return s.listener.Close()
+}
+
+func (s *StandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("clamd stand-in accept failed: %v", err)
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *StandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Minute))
+	r := bufio.NewReader(conn)
+
+	// zCOMMAND\0 and nCOMMAND\n, the reply ends the same way
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	delim := byte('\n')
+	if prefix == 'z' {
+		delim = 0
+	}
+	line, err := r.ReadString(delim)
+	if err != nil {
+		return
+	}
+	reply := func(msg string) { io.WriteString(conn, msg+string(delim)) }
+
+	switch strings.TrimSuffix(line, string(delim)) {
+	case "PING":
+		reply("PONG")
+	case "INSTREAM":
+		data, err := readChunks(r)
+		if err != nil {
+			reply(err.Error() + " ERROR")
+			return
+		}
+		if bytes.Contains(data, eicar) {
+			reply("stream: Eicar-Test-Signature FOUND")
+			return
+		}
+		reply("stream: OK")
+	default:
+		reply("UNKNOWN COMMAND")
+	}
+}
+
+func readChunks(r io.Reader) ([]byte, error) {
+	var data bytes.Buffer
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, size); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			return data.Bytes(), nil
+		}
+		if data.Len()+int(n) > standInMaxStream {
+			return nil, errors.New("INSTREAM size limit exceeded")
+		}
+		if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+			return nil, err
+		}
+	}
+}
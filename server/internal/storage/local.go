@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const tempPrefix = ".upload-" // files being written, left out of listings
+
+// Local keeps the objects as files under a directory, for development
+// without an object store. Content types are derived from the extension.
+type Local struct {
+	root   string
+	signer *signer
+}
+
+func NewLocal(dir string, signer *signer) (*Local, error) {
+	if dir == "" {
+		return nil, errors.New("local storage needs a directory")
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{root: root, signer: signer}, nil
+}
+
+// validKey refuses the keys that would resolve outside of the root
+func validKey(key string) error {
+	if key == "" || !filepath.IsLocal(filepath.FromSlash(key)) || strings.HasPrefix(path.Base(key), tempPrefix) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// written aside and renamed, readers never see half an object
+	tmp, err := os.CreateTemp(filepath.Dir(p), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("wrote %d bytes of %d", written, size)
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Get(ctx context.Context, key string, rng *Range) (*Reader, error) {
+	obj, err := l.Head(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := l.path(key)
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, mapFSError(err)
+	}
+
+	resolved, err := checkRange(rng, obj.Size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	reader := &Reader{ReadCloser: f, Object: obj, Range: resolved}
+	if resolved != nil {
+		if _, err := f.Seek(resolved.Start, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		reader.ReadCloser = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(f, resolved.End-resolved.Start+1), f}
+	}
+	return reader, nil
+}
+
+func (l *Local) Head(ctx context.Context, key string) (Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return Object{}, mapFSError(err)
+	}
+	if info.IsDir() {
+		return Object{}, ErrNotFound
+	}
+	return l.object(key, info), nil
+}
+
+func (l *Local) object(key string, info fs.FileInfo) Object {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	sum := md5.Sum([]byte(fmt.Sprint(info.Size(), info.ModTime().UnixNano())))
+	return Object{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  contentType,
+		ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		LastModified: info.ModTime(),
+	}
+}
+
+// Delete removes the object, deleting a missing one is not an error, as
+// with S3
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	err := filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() && p != l.root && strings.HasPrefix(d.Name(), tempPrefix) {
+			return fs.SkipDir // the parts of multipart uploads
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(l.object(key, info))
+	})
+	return err
+}
+
+// partsDir holds the parts of a multipart upload until it is completed,
+// along with the key they are for
+func (l *Local) partsDir(uploadID string) (string, error) {
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return "", ErrNotFound
+	}
+	return filepath.Join(l.root, tempPrefix+"parts-"+uploadID), nil
+}
+
+func (l *Local) openUpload(key, uploadID string) (string, error) {
+	dir, err := l.partsDir(uploadID)
+	if err != nil {
+		return "", err
+	}
+	owner, err := os.ReadFile(filepath.Join(dir, "key"))
+	if err != nil {
+		return "", mapFSError(err)
+	}
+	if string(owner) != key {
+		return "", ErrNotFound
+	}
+	return dir, nil
+}
+
+func (l *Local) CreateMultipart(ctx context.Context, key, contentType string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	uploadID, err := newUploadID()
+	if err != nil {
+		return "", err
+	}
+	dir, _ := l.partsDir(uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "key"), []byte(key), 0o644); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (l *Local) UploadPart(ctx context.Context, key, uploadID string, number int32, body io.Reader, size int64) (Part, error) {
+	dir, err := l.openUpload(key, uploadID)
+	if err != nil {
+		return Part{}, err
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return Part{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Part{}, err
+	}
+	if size >= 0 && written != size {
+		return Part{}, fmt.Errorf("wrote %d bytes of %d", written, size)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, fmt.Sprint(number))); err != nil {
+		return Part{}, err
+	}
+	return Part{Number: number, ETag: `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, Size: written}, nil
+}
+
+func (l *Local) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	if err := checkParts(parts); err != nil {
+		return err
+	}
+	dir, err := l.openUpload(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		f, err := os.Open(filepath.Join(dir, fmt.Sprint(part.Number)))
+		if err != nil {
+			return fmt.Errorf("part %d was not uploaded: %w", part.Number, err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() != part.Size {
+			return fmt.Errorf("part %d has %d bytes, expected %d", part.Number, info.Size(), part.Size)
+		}
+		readers = append(readers, f)
+	}
+
+	if err := l.Put(ctx, key, io.MultiReader(readers...), -1, ""); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (l *Local) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	dir, err := l.openUpload(key, uploadID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (l *Local) Presign(ctx context.Context, key string, opts PresignOptions) (string, error) {
+	return l.signer.presign(key, opts)
+}
+
+func mapFSError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory keeps the objects in memory, for tests and throwaway setups
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+	uploads map[string]*memoryUpload
+	signer  *signer
+}
+
+type memoryUpload struct {
+	key         string
+	contentType string
+	parts       map[int32][]byte
+}
+
+type memoryObject struct {
+	data []byte
+	Object
+}
+
+func NewMemory(signer *signer) *Memory {
+	return &Memory{
+		objects: make(map[string]memoryObject),
+		uploads: make(map[string]*memoryUpload),
+		signer:  signer,
+	}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return fmt.Errorf("read %d bytes of %d", len(data), size)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	sum := md5.Sum(data)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{
+		data: data,
+		Object: Object{
+			Key:          key,
+			Size:         int64(len(data)),
+			ContentType:  contentType,
+			ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+			LastModified: time.Now(),
+		},
+	}
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string, rng *Range) (*Reader, error) {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	resolved, err := checkRange(rng, obj.Size)
+	if err != nil {
+		return nil, err
+	}
+	data := obj.data
+	if resolved != nil {
+		data = data[resolved.Start : resolved.End+1]
+	}
+	return &Reader{ReadCloser: io.NopCloser(bytes.NewReader(data)), Object: obj.Object, Range: resolved}, nil
+}
+
+func (m *Memory) Head(ctx context.Context, key string) (Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return Object{}, ErrNotFound
+	}
+	return obj.Object, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	m.mu.RLock()
+	objects := make([]Object, 0, len(m.objects))
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, obj.Object)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	for _, obj := range objects {
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) CreateMultipart(ctx context.Context, key, contentType string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	uploadID, err := newUploadID()
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads[uploadID] = &memoryUpload{key: key, contentType: contentType, parts: make(map[int32][]byte)}
+	return uploadID, nil
+}
+
+func (m *Memory) UploadPart(ctx context.Context, key, uploadID string, number int32, body io.Reader, size int64) (Part, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return Part{}, err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return Part{}, fmt.Errorf("read %d bytes of %d", len(data), size)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		return Part{}, ErrNotFound
+	}
+	upload.parts[number] = data
+	sum := md5.Sum(data)
+	return Part{Number: number, ETag: `"` + hex.EncodeToString(sum[:]) + `"`, Size: int64(len(data))}, nil
+}
+
+func (m *Memory) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	if err := checkParts(parts); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	var buf bytes.Buffer
+	for _, part := range parts {
+		data, ok := upload.parts[part.Number]
+		if !ok || int64(len(data)) != part.Size {
+			m.mu.Unlock()
+			return fmt.Errorf("part %d was not uploaded", part.Number)
+		}
+		buf.Write(data)
+	}
+	delete(m.uploads, uploadID)
+	m.mu.Unlock()
+
+	return m.Put(ctx, key, &buf, int64(buf.Len()), upload.contentType)
+}
+
+func (m *Memory) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+func (m *Memory) Presign(ctx context.Context, key string, opts PresignOptions) (string, error) {
+	return m.signer.presign(key, opts)
+}
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 keeps the objects in a bucket of an S3 compatible store
+type S3 struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func NewS3(ctx context.Context, cfg Config) (*S3, error) {
+	region := cfg.Region
+	if region == "" {
+		region = "auto"
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(region),
+		config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load S3 config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	return &S3{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+// Client is the underlying client, for the operations the interface lacks
+func (s *S3) Client() *s3.Client {
+	return s.client
+}
+
+func (s *S3) Bucket() string {
+	return s.bucket
+}
+
+// Key is the key of an object in the bucket
+func (s *S3) Key(key string) string {
+	return s.prefix + key
+}
+
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.Key(key)),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}
+	if size >= 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+	_, err := s.client.PutObject(ctx, input)
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string, rng *Range) (*Reader, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.Key(key)),
+	}
+	if rng != nil {
+		if rng.Start < 0 || (rng.End >= 0 && rng.Start > rng.End) {
+			return nil, ErrInvalidRange
+		}
+		if rng.End < 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", rng.Start))
+		} else {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", rng.Start, rng.End))
+		}
+	}
+
+	obj, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+
+	reader := &Reader{
+		ReadCloser: obj.Body,
+		Object: Object{
+			Key:          key,
+			Size:         aws.ToInt64(obj.ContentLength),
+			ContentType:  aws.ToString(obj.ContentType),
+			ETag:         aws.ToString(obj.ETag),
+			LastModified: aws.ToTime(obj.LastModified),
+		},
+	}
+	if obj.ContentRange != nil {
+		var start, end, size int64
+		if _, err := fmt.Sscanf(*obj.ContentRange, "bytes %d-%d/%d", &start, &end, &size); err == nil {
+			reader.Range = &Range{Start: start, End: end}
+			reader.Size = size
+		}
+	}
+	return reader, nil
+}
+
+func (s *S3) Head(ctx context.Context, key string) (Object, error) {
+	obj, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.Key(key)),
+	})
+	if err != nil {
+		return Object{}, mapS3Error(err)
+	}
+	return Object{
+		Key:          key,
+		Size:         aws.ToInt64(obj.ContentLength),
+		ContentType:  aws.ToString(obj.ContentType),
+		ETag:         aws.ToString(obj.ETag),
+		LastModified: aws.ToTime(obj.LastModified),
+	}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.Key(key)),
+	})
+	return mapS3Error(err)
+}
+
+func (s *S3) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.Key(prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			err := fn(Object{
+				Key:          strings.TrimPrefix(aws.ToString(obj.Key), s.prefix),
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         aws.ToString(obj.ETag),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *S3) Presign(ctx context.Context, key string, opts PresignOptions) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+
+	switch opts.Method {
+	case http.MethodGet, "":
+		request, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.Key(key)),
+		}, s3.WithPresignExpires(opts.Expires))
+		if err != nil {
+			return "", err
+		}
+		return request.URL, nil
+	case http.MethodPut:
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.Key(key)),
+		}
+		if opts.ContentType != "" {
+			input.ContentType = aws.String(opts.ContentType)
+		}
+		if opts.ContentLength > 0 {
+			input.ContentLength = aws.Int64(opts.ContentLength)
+		}
+		request, err := presignClient.PresignPutObject(ctx, input, s3.WithPresignExpires(opts.Expires))
+		if err != nil {
+			return "", err
+		}
+		return request.URL, nil
+	default:
+		return "", fmt.Errorf("cannot presign %s", opts.Method)
+	}
+}
+
+func (s *S3) CreateMultipart(ctx context.Context, key, contentType string) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.Key(key)),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	upload, err := s.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(upload.UploadId), nil
+}
+
+func (s *S3) UploadPart(ctx context.Context, key, uploadID string, number int32, body io.Reader, size int64) (Part, error) {
+	input := &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(s.Key(key)),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(number),
+		Body:       body,
+	}
+	if size >= 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+	part, err := s.client.UploadPart(ctx, input)
+	if err != nil {
+		return Part{}, mapS3Error(err)
+	}
+	return Part{Number: number, ETag: aws.ToString(part.ETag), Size: size}, nil
+}
+
+func (s *S3) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			PartNumber: aws.Int32(part.Number),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(s.Key(key)),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	return mapS3Error(err)
+}
+
+func (s *S3) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(s.Key(key)),
+		UploadId: aws.String(uploadID),
+	})
+	if err = mapS3Error(err); errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func mapS3Error(err error) error {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signer issues the presigned urls of the backends without an object
+// store. They point at the server, which serves them with SignedHandler.
+type signer struct {
+	baseURL string
+	key     []byte
+}
+
+func (s *signer) signature(method, key string, expires int64, contentType string, contentLength int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	io.WriteString(mac, strings.Join([]string{
+		method,
+		key,
+		strconv.FormatInt(expires, 10),
+		contentType,
+		strconv.FormatInt(contentLength, 10),
+	}, "\n"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *signer) presign(key string, opts PresignOptions) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPut {
+		return "", errors.New("cannot presign " + method)
+	}
+	expires := time.Now().Add(opts.Expires).Unix()
+
+	q := url.Values{}
+	q.Set("method", method)
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	if opts.ContentType != "" {
+		q.Set("contentType", opts.ContentType)
+	}
+	if opts.ContentLength > 0 {
+		q.Set("contentLength", strconv.FormatInt(opts.ContentLength, 10))
+	}
+	q.Set("signature", s.signature(method, key, expires, opts.ContentType, opts.ContentLength))
+
+	return strings.TrimSuffix(s.baseURL, "/") + "/storage/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+// verify checks the signature of a request to a presigned url
+func (s *signer) verify(r *http.Request, key string) bool {
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	var contentLength int64
+	if v := q.Get("contentLength"); v != "" {
+		if contentLength, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return false
+		}
+	}
+	if q.Get("method") != r.Method {
+		return false
+	}
+	want := s.signature(r.Method, key, expires, q.Get("contentType"), contentLength)
+	return hmac.Equal([]byte(want), []byte(q.Get("signature")))
+}
+
+// SignedHandler serves the presigned urls of store under /storage/, or
+// returns nil when the backend presigns urls of its own
+func SignedHandler(store Storage) http.Handler {
+	var s *signer
+	switch backend := store.(type) {
+	case *Local:
+		s = backend.signer
+	case *Memory:
+		s = backend.signer
+	default:
+		return nil
+	}
+
+	return http.StripPrefix("/storage/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if validKey(key) != nil || !s.verify(r, key) {
+			http.Error(w, "invalid or expired signature", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			obj, err := store.Get(r.Context(), key, nil)
+			if errors.Is(err, ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				log.Printf("Signed read of %s failed: %v", key, err)
+				http.Error(w, "read failed", http.StatusInternalServerError)
+				return
+			}
+			defer obj.Close()
+			w.Header().Set("Content-Type", obj.ContentType)
+			w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+			io.Copy(w, obj)
+		case http.MethodPut:
+			q := r.URL.Query()
+			contentType := r.Header.Get("Content-Type")
+			if want := q.Get("contentType"); want != "" && want != contentType {
+				http.Error(w, "content type does not match the signature", http.StatusForbidden)
+				return
+			}
+			if want := q.Get("contentLength"); want != "" && want != strconv.FormatInt(r.ContentLength, 10) {
+				http.Error(w, "content length does not match the signature", http.StatusForbidden)
+				return
+			}
+			if err := store.Put(r.Context(), key, r.Body, r.ContentLength, contentType); err != nil {
+				log.Printf("Signed write of %s failed: %v", key, err)
+				http.Error(w, "write failed", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+}
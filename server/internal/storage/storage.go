@@ -0,0 +1,181 @@
+// Package storage abstracts the object store uploaded files are kept in.
+// The services only see the Storage interface, the backend is picked from
+// the config: S3 compatible stores (R2 in production), a local directory,
+// or memory.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	BackendS3     = "s3"
+	BackendLocal  = "local"
+	BackendMemory = "memory"
+)
+
+var (
+	ErrNotFound     = errors.New("object not found")
+	ErrInvalidKey   = errors.New("invalid object key")
+	ErrInvalidRange = errors.New("invalid range")
+)
+
+// MinPartSize is the smallest part of a multipart upload, only the last
+// part may be smaller
+const MinPartSize = 5 << 20
+
+// Object describes a stored object
+type Object struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Range selects the bytes Start to End of an object, End included. An End
+// below zero reads to the end of the object.
+type Range struct {
+	Start int64
+	End   int64
+}
+
+// Reader is the body of an object, or of the range of it that was asked for
+type Reader struct {
+	io.ReadCloser
+	Object
+	Range *Range // the bytes of Body within the object, nil for all of them
+}
+
+// Part is a stored part of a multipart upload
+type Part struct {
+	Number int32 // from 1, the parts are assembled in this order
+	ETag   string
+	Size   int64
+}
+
+// PresignOptions constrain what a presigned url allows
+type PresignOptions struct {
+	Method        string // http.MethodGet or http.MethodPut
+	Expires       time.Duration
+	ContentType   string // the Content-Type a PUT must have, any when empty
+	ContentLength int64  // the size a PUT must have, any when zero
+}
+
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get reads the object, or only rng of it when rng is not nil
+	Get(ctx context.Context, key string, rng *Range) (*Reader, error)
+	Head(ctx context.Context, key string) (Object, error)
+	Delete(ctx context.Context, key string) error
+	// List calls fn with every object whose key starts with prefix, and
+	// stops at the first error fn returns
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+	Presign(ctx context.Context, key string, opts PresignOptions) (string, error)
+
+	// CreateMultipart starts an object uploaded in parts, which only shows
+	// up once CompleteMultipart assembles them
+	CreateMultipart(ctx context.Context, key, contentType string) (uploadID string, err error)
+	// UploadPart stores a part, replacing a previous one of the same number
+	UploadPart(ctx context.Context, key, uploadID string, number int32, body io.Reader, size int64) (Part, error)
+	CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error
+	AbortMultipart(ctx context.Context, key, uploadID string) error
+}
+
+type Config struct {
+	Backend string // BackendS3, BackendLocal or BackendMemory
+	Bucket  string
+	Prefix  string // prepended to the keys in the bucket, to share it between environments
+
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+
+	Dir string // root of the local backend
+
+	// the backends without an object store sign their own urls, served by
+	// the server under PublicURL/storage/
+	PublicURL  string
+	SigningKey string
+}
+
+// New builds the backend named in the config
+func New(ctx context.Context, cfg Config) (Storage, error) {
+	switch cfg.Backend {
+	case BackendS3, "":
+		return NewS3(ctx, cfg)
+	case BackendLocal:
+		signer, err := newSigner(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewLocal(cfg.Dir, signer)
+	case BackendMemory:
+		signer, err := newSigner(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewMemory(signer), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+func newSigner(cfg Config) (*signer, error) {
+	key := []byte(cfg.SigningKey)
+	if len(key) == 0 {
+		// urls signed before a restart stop working, which is fine locally
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &signer{baseURL: cfg.PublicURL, key: key}, nil
+}
+
+func newUploadID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// checkParts validates the parts given to complete a multipart upload
+func checkParts(parts []Part) error {
+	if len(parts) == 0 {
+		return errors.New("no parts to complete the upload with")
+	}
+	for i, part := range parts {
+		if part.Number != int32(i+1) {
+			return fmt.Errorf("part %d is out of order", part.Number)
+		}
+		if i < len(parts)-1 && part.Size < MinPartSize {
+			return fmt.Errorf("part %d is smaller than %d bytes", part.Number, MinPartSize)
+		}
+	}
+	return nil
+}
+
+// checkRange validates rng against an object of size bytes, resolving an
+// open End
+func checkRange(rng *Range, size int64) (*Range, error) {
+	if rng == nil {
+		return nil, nil
+	}
+	resolved := *rng
+	if resolved.End < 0 || resolved.End >= size {
+		resolved.End = size - 1
+	}
+	if resolved.Start < 0 || resolved.Start > resolved.End {
+		return nil, ErrInvalidRange
+	}
+	return &resolved, nil
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// backends returns a fresh store of every backend that runs without a
+// service behind it
+func backends(t *testing.T) map[string]Storage {
+	t.Helper()
+	local, err := NewLocal(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return map[string]Storage{
+		BackendMemory: NewMemory(nil),
+		BackendLocal:  local,
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	const content = "0123456789"
+
+	tests := []struct {
+		name     string
+		rng      *Range
+		want     string
+		resolved *Range
+		err      error
+	}{
+		{name: "whole object", rng: nil, want: content},
+		{name: "head", rng: &Range{0, 3}, want: "0123", resolved: &Range{0, 3}},
+		{name: "open end", rng: &Range{5, -1}, want: "56789", resolved: &Range{5, 9}},
+		{name: "end past the object", rng: &Range{8, 100}, want: "89", resolved: &Range{8, 9}},
+		{name: "single byte", rng: &Range{9, 9}, want: "9", resolved: &Range{9, 9}},
+		{name: "start after end", rng: &Range{3, 2}, err: ErrInvalidRange},
+		{name: "negative start", rng: &Range{-1, 3}, err: ErrInvalidRange},
+		{name: "start past the object", rng: &Range{10, -1}, err: ErrInvalidRange},
+	}
+
+	for name, store := range backends(t) {
+		ctx := context.Background()
+		if err := store.Put(ctx, "files/digits.txt", strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+			t.Fatalf("%s: Put: %v", name, err)
+		}
+
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				r, err := store.Get(ctx, "files/digits.txt", tt.rng)
+				if tt.err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Fatalf("Get error = %v, want %v", err, tt.err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Get: %v", err)
+				}
+				defer r.Close()
+
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("reading the body: %v", err)
+				}
+				if string(got) != tt.want {
+					t.Errorf("body = %q, want %q", got, tt.want)
+				}
+				if r.Size != int64(len(content)) {
+					t.Errorf("Size = %d, want the size of the whole object %d", r.Size, len(content))
+				}
+				if (r.Range == nil) != (tt.resolved == nil) || (r.Range != nil && *r.Range != *tt.resolved) {
+					t.Errorf("Range = %v, want %v", r.Range, tt.resolved)
+				}
+			})
+		}
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	for name, store := range backends(t) {
+		if _, err := store.Get(context.Background(), "files/missing", nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: Get error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestCheckParts(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []Part
+		ok    bool
+	}{
+		{name: "no parts", parts: nil},
+		{name: "single small part", parts: []Part{{Number: 1, Size: 10}}, ok: true},
+		{name: "small last part", parts: []Part{{Number: 1, Size: MinPartSize}, {Number: 2, Size: 1}}, ok: true},
+		{name: "small part before the last", parts: []Part{{Number: 1, Size: MinPartSize - 1}, {Number: 2, Size: MinPartSize}}},
+		{name: "out of order", parts: []Part{{Number: 2, Size: MinPartSize}, {Number: 1, Size: 1}}},
+		{name: "gap", parts: []Part{{Number: 1, Size: MinPartSize}, {Number: 3, Size: 1}}},
+		{name: "not starting at one", parts: []Part{{Number: 0, Size: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkParts(tt.parts)
+			if tt.ok && err != nil {
+				t.Errorf("checkParts: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("checkParts accepted the parts")
+			}
+		})
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	first := bytes.Repeat([]byte("a"), MinPartSize)
+	last := []byte("tail")
+
+	for name, store := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			uploadID, err := store.CreateMultipart(ctx, "uploads/big.bin", "application/octet-stream")
+			if err != nil {
+				t.Fatalf("CreateMultipart: %v", err)
+			}
+
+			// parts may come in any order, and be sent again
+			p2, err := store.UploadPart(ctx, "uploads/big.bin", uploadID, 2, bytes.NewReader([]byte("stale")), 5)
+			if err != nil {
+				t.Fatalf("UploadPart 2: %v", err)
+			}
+			p1, err := store.UploadPart(ctx, "uploads/big.bin", uploadID, 1, bytes.NewReader(first), int64(len(first)))
+			if err != nil {
+				t.Fatalf("UploadPart 1: %v", err)
+			}
+			if p2, err = store.UploadPart(ctx, "uploads/big.bin", uploadID, 2, bytes.NewReader(last), int64(len(last))); err != nil {
+				t.Fatalf("UploadPart 2 again: %v", err)
+			}
+
+			if _, err := store.Head(ctx, "uploads/big.bin"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Head before completion error = %v, want ErrNotFound", err)
+			}
+			if _, err := store.UploadPart(ctx, "uploads/other.bin", uploadID, 3, bytes.NewReader(last), int64(len(last))); !errors.Is(err, ErrNotFound) {
+				t.Errorf("UploadPart to another key error = %v, want ErrNotFound", err)
+			}
+			if err := store.CompleteMultipart(ctx, "uploads/big.bin", uploadID, []Part{p2, p1}); err == nil {
+				t.Error("CompleteMultipart accepted parts out of order")
+			}
+			wrongSize := p2
+			wrongSize.Size++
+			if err := store.CompleteMultipart(ctx, "uploads/big.bin", uploadID, []Part{p1, wrongSize}); err == nil {
+				t.Error("CompleteMultipart accepted a part of the wrong size")
+			}
+
+			if err := store.CompleteMultipart(ctx, "uploads/big.bin", uploadID, []Part{p1, p2}); err != nil {
+				t.Fatalf("CompleteMultipart: %v", err)
+			}
+			r, err := store.Get(ctx, "uploads/big.bin", &Range{Start: int64(len(first)) - 2, End: -1})
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("reading the body: %v", err)
+			}
+			if string(got) != "aatail" {
+				t.Errorf("end of the object = %q, want %q", got, "aatail")
+			}
+			if want := int64(len(first) + len(last)); r.Size != want {
+				t.Errorf("Size = %d, want %d", r.Size, want)
+			}
+
+			if _, err := store.UploadPart(ctx, "uploads/big.bin", uploadID, 3, bytes.NewReader(last), int64(len(last))); !errors.Is(err, ErrNotFound) {
+				t.Errorf("UploadPart after completion error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	for name, store := range backends(t) {
+		for _, key := range []string{"", "../escape", "/etc/passwd"} {
+			if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, ""); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("%s: Put(%q) error = %v, want ErrInvalidKey", name, key, err)
+			}
+			if _, err := store.CreateMultipart(context.Background(), key, ""); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("%s: CreateMultipart(%q) error = %v, want ErrInvalidKey", name, key, err)
+			}
+		}
+	}
+}
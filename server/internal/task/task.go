@@ -31,13 +31,9 @@ func NewTaskService(
 func (s *Service) CreateDraftTaskFiles(ctx context.Context, userId uuid.UUID, files []*multipart.FileHeader) (file.UploadFileRes, error) {
 	id := uuid.New()
 
-	uploaded, failed := s.fileService.ProcessBatchUpload(files, "task", id)
-	upladedByte, _ := json.Marshal(uploaded)
+	uploaded, failed := s.fileService.ProcessBatchUpload(files, "task", id, userId)
 
-	err := s.store.SaveDraftTaskFiles(ctx, database.SaveDraftTaskFilesParams{
-		UserID:  userId,
-		Column1: upladedByte,
-	})
+	err := s.AddDraftTaskFiles(ctx, userId, uploaded)
 	if err != nil {
 		return file.UploadFileRes{}, err
 
@@ -47,6 +43,16 @@ func (s *Service) CreateDraftTaskFiles(ctx context.Context, userId uuid.UUID, fi
 	}, nil
 }
 
+// AddDraftTaskFiles attaches stored files to the task draft of the user
+func (s *Service) AddDraftTaskFiles(ctx context.Context, userId uuid.UUID, files []file.FileMeta) error {
+	upladedByte, _ := json.Marshal(files)
+
+	return s.store.SaveDraftTaskFiles(ctx, database.SaveDraftTaskFilesParams{
+		UserID:  userId,
+		Column1: upladedByte,
+	})
+}
+
 func (s *Service) DeleteDraftTaskFile(ctx context.Context, userId uuid.UUID, filePath string) error {
 
 	err := s.store.DeleteDraftTaskFile(ctx, database.DeleteDraftTaskFileParams{
@@ -59,9 +65,26 @@ func (s *Service) DeleteDraftTaskFile(ctx context.Context, userId uuid.UUID, fil
 		return errors.New("failed to delete")
 	}
 
-	err = s.fileService.DeleteFromS3(filePath)
+	err = s.fileService.DeleteFile(filePath)
 	if err != nil {
-		log.Printf("WARNING: DB deleted but S3 failed for %s. Error: %s", filePath, err.Error())
+		log.Printf("WARNING: DB deleted but storage failed for %s. Error: %s", filePath, err.Error())
 	}
 	return nil
 }
+
+// CanView reports whether a user may see a task and everything attached to
+// it. Public tasks are open to everyone, private ones to the poster, the
+// assigned solver and staff only.
+func CanView(access database.GetTaskAccessRow, userID uuid.UUID, role string) bool {
+	if access.Visibility == database.VisibilityPublic {
+		return true
+	}
+	switch {
+	case access.PosterID == userID,
+		access.SolverID != nil && *access.SolverID == userID,
+		role == string(database.RoleADMIN),
+		role == string(database.RoleMODERATOR):
+		return true
+	}
+	return false
+}
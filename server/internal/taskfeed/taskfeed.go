@@ -0,0 +1,196 @@
+// Package taskfeed pushes open tasks to the solvers they match, so that
+// solvers do not have to poll for new work
+package taskfeed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/api/websocket"
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// a task is announced as new once within this window, however often
+	// its poster publishes it
+	publishWindow   = time.Hour
+	publishedPrefix = "taskfeed:published:"
+	maxCategories   = 50
+)
+
+var (
+	ErrTaskNotOpen         = errors.New("task is not open to solvers")
+	ErrForbidden           = errors.New("only the poster can publish a task")
+	ErrNotSubscribed       = errors.New("not subscribed to the task feed")
+	ErrInvalidSubscription = errors.New("invalid task feed subscription")
+)
+
+// Subscription holds the categories a solver wants tasks of, all of them
+// when empty
+type Subscription struct {
+	CategoryIDs []uuid.UUID `json:"categoryIds"`
+}
+
+type Service struct {
+	store  *database.Queries
+	redis  *redis.Client
+	wsFeed *websocket.WsTaskFeed
+}
+
+func NewService(store *database.Queries, redisClient *redis.Client, wsFeed *websocket.WsTaskFeed) *Service {
+	return &Service{store: store, redis: redisClient, wsFeed: wsFeed}
+}
+
+func (s *Service) GetSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	sub, err := s.store.GetTaskFeedSubscription(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Subscription{}, ErrNotSubscribed
+	}
+	if err != nil {
+		return Subscription{}, err
+	}
+	return Subscription{CategoryIDs: sub.CategoryIds}, nil
+}
+
+func (s *Service) Subscribe(ctx context.Context, userID uuid.UUID, sub Subscription) (Subscription, error) {
+	slices.SortFunc(sub.CategoryIDs, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	sub.CategoryIDs = slices.Compact(sub.CategoryIDs)
+	if len(sub.CategoryIDs) > maxCategories {
+		return Subscription{}, fmt.Errorf("%w: at most %d categories", ErrInvalidSubscription, maxCategories)
+	}
+	if sub.CategoryIDs == nil {
+		sub.CategoryIDs = []uuid.UUID{}
+	}
+
+	if len(sub.CategoryIDs) > 0 {
+		known, err := s.store.CountTaskCategories(ctx, sub.CategoryIDs)
+		if err != nil {
+			return Subscription{}, err
+		}
+		if known != int64(len(sub.CategoryIDs)) {
+			return Subscription{}, fmt.Errorf("%w: unknown category", ErrInvalidSubscription)
+		}
+	}
+
+	saved, err := s.store.UpsertTaskFeedSubscription(ctx, database.UpsertTaskFeedSubscriptionParams{
+		UserID:      userID,
+		CategoryIds: sub.CategoryIDs,
+	})
+	if err != nil {
+		return Subscription{}, err
+	}
+	return Subscription{CategoryIDs: saved.CategoryIds}, nil
+}
+
+func (s *Service) Unsubscribe(ctx context.Context, userID uuid.UUID) error {
+	deleted, err := s.store.DeleteTaskFeedSubscription(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotSubscribed
+	}
+	return nil
+}
+
+// PublishOpen announces a newly opened task, on behalf of its poster. It
+// returns how many solvers it was pushed to.
+func (s *Service) PublishOpen(ctx context.Context, taskID, userID uuid.UUID) (int, error) {
+	task, err := s.store.GetFeedTask(ctx, taskID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrTaskNotOpen
+	}
+	if err != nil {
+		return 0, err
+	}
+	if task.PosterID != userID {
+		return 0, ErrForbidden
+	}
+	return s.publishNew(ctx, task)
+}
+
+// StartPublishJob announces the tasks opened since the last run, whichever
+// way they were created. Every task is announced by a single instance.
+func (s *Service) StartPublishJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	log.Println("Starting Background Job for task feed publishing")
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Task feed publishing shutting down...")
+			return
+		case <-ticker.C:
+			s.publishNewTasks(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+func (s *Service) publishNewTasks(ctx context.Context) {
+	// older tasks were announced already, or by now are no news
+	tasks, err := s.store.ListNewFeedTasks(ctx, time.Now().Add(-publishWindow))
+	if err != nil {
+		log.Printf("Error listing new tasks for the feed: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		if _, err := s.publishNew(ctx, database.GetFeedTaskRow(task)); err != nil {
+			log.Printf("Failed to publish task %s to the feed: %v", task.ID, err)
+		}
+	}
+}
+
+// publishNew announces the task unless it was announced within the
+// publishWindow already
+func (s *Service) publishNew(ctx context.Context, task database.GetFeedTaskRow) (int, error) {
+	first, err := s.redis.SetNX(ctx, publishedPrefix+task.ID.String(), 1, publishWindow).Result()
+	if err != nil {
+		return 0, err
+	}
+	if !first {
+		return 0, nil
+	}
+	return s.publish(ctx, task, "task_open")
+}
+
+// PublishReopened announces a task put back to OPEN after its solver
+// missed the deadline. The blocked solver is left out.
+func (s *Service) PublishReopened(ctx context.Context, taskID uuid.UUID) (int, error) {
+	task, err := s.store.GetFeedTask(ctx, taskID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// private tasks are reopened too, they just have no feed
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return s.publish(ctx, task, "task_reopened")
+}
+
+func (s *Service) publish(ctx context.Context, task database.GetFeedTaskRow, messageType string) (int, error) {
+	recipients, err := s.store.ListTaskFeedRecipients(ctx, task.ID)
+	if err != nil || len(recipients) == 0 {
+		return 0, err
+	}
+
+	return s.wsFeed.SendToOnline(ctx, recipients, websocket.FeedTask{
+		MessageType:  messageType,
+		ID:           task.ID.String(),
+		Title:        task.Title,
+		Description:  task.Description,
+		Price:        task.Price,
+		Deadline:     task.Deadline,
+		CategoryID:   task.CategoryID.String(),
+		CategoryName: task.CategoryName,
+		CreatedAt:    task.CreatedAt.Format(time.RFC3339Nano),
+	})
+}
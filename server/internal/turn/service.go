@@ -0,0 +1,127 @@
+// Package turn issues short lived TURN credentials for mentorship calls
+package turn
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNotConfigured  = errors.New("turn server is not configured")
+	ErrNotFound       = errors.New("mentor session not found")
+	ErrNotParticipant = errors.New("not a participant of this session")
+	ErrInactive       = errors.New("mentor session is not active")
+)
+
+type Config struct {
+	Secret   string        // static-auth-secret shared with coturn
+	TurnURLs []string      // turn: and turns: urls
+	StunURLs []string      // stun: urls, handed out without credentials
+	TTL      time.Duration // upper bound of a credential lifetime
+}
+
+// ParseURLs splits a comma separated list of ice server urls
+func ParseURLs(list string) []string {
+	var urls []string
+	for url := range strings.SplitSeq(list, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+type Service struct {
+	store  *database.Queries
+	config Config
+}
+
+func NewService(store *database.Queries, config Config) *Service {
+	return &Service{
+		store:  store,
+		config: config,
+	}
+}
+
+// IceServer mirrors RTCIceServer
+type IceServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+type Credentials struct {
+	CreatedAt time.Time   `json:"createdAt"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+	TTL       int64       `json:"ttl"`
+	Turn      []IceServer `json:"turn"`
+}
+
+// IssueCredentials returns the ice servers for a participant of a paid
+// session that is currently running. The TURN credential never outlives the
+// session.
+func (s *Service) IssueCredentials(ctx context.Context, sessionID, userID uuid.UUID) (Credentials, error) {
+	if s.config.Secret == "" || len(s.config.TurnURLs) == 0 {
+		return Credentials{}, ErrNotConfigured
+	}
+
+	session, err := s.store.GetMentorSessionParticipants(ctx, sessionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Credentials{}, ErrNotFound
+	}
+	if err != nil {
+		return Credentials{}, err
+	}
+	if userID != session.SolverID && userID != session.StudentID {
+		return Credentials{}, ErrNotParticipant
+	}
+
+	now := time.Now()
+	if session.Status != database.BookingStatusPAID ||
+		now.Before(session.SessionStart) || !now.Before(session.SessionEnd) {
+		return Credentials{}, ErrInactive
+	}
+
+	expiresAt := now.Add(s.config.TTL)
+	if session.SessionEnd.Before(expiresAt) {
+		expiresAt = session.SessionEnd
+	}
+	username, credential := s.sign(userID, expiresAt)
+
+	servers := make([]IceServer, 0, 2)
+	if len(s.config.StunURLs) > 0 {
+		servers = append(servers, IceServer{URLs: s.config.StunURLs})
+	}
+	servers = append(servers, IceServer{
+		URLs:       s.config.TurnURLs,
+		Username:   username,
+		Credential: credential,
+	})
+
+	return Credentials{
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+		TTL:       int64(expiresAt.Sub(now).Seconds()),
+		Turn:      servers,
+	}, nil
+}
+
+// sign implements the coturn REST API scheme: the username is the expiry
+// timestamp and the user, the credential the HMAC-SHA1 of the username.
+func (s *Service) sign(userID uuid.UUID, expiresAt time.Time) (string, string) {
+	username := fmt.Sprintf("%d:%s", expiresAt.Unix(), userID)
+	mac := hmac.New(sha1.New, []byte(s.config.Secret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
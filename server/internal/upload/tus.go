@@ -0,0 +1,382 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/file"
+	"github/abdallemo/solveit-saas/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Resumable uploads follow the tus protocol. The bytes go to a multipart
+// upload of the store part by part; what arrives short of a part is kept
+// in a tail object next to the file until the next request completes it.
+
+const (
+	// TusVersion is the version of the tus protocol spoken
+	TusVersion = "1.0.0"
+	// tusLifetime is how long an upload may sit idle, every write pushes
+	// it back
+	tusLifetime   = 24 * time.Hour
+	tusLockTTL    = 30 * time.Minute
+	tusLockPrefix = "tus:lock:"
+)
+
+var (
+	ErrOffsetMismatch = errors.New("upload offset does not match")
+	ErrLocked         = errors.New("upload is being written by another request")
+	ErrTooLarge       = errors.New("upload is larger than its declared length")
+)
+
+// tusScopes are the scopes taking resumable uploads, the big files go there
+var tusScopes = map[database.UploadScope]bool{
+	database.UploadScopeTASKDRAFT: true,
+	database.UploadScopeWORKSPACE: true,
+}
+
+type tusPart struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// TusUpload is the state of a resumable upload
+type TusUpload struct {
+	ID        uuid.UUID
+	Offset    int64
+	Length    int64
+	ExpiresAt time.Time
+	Completed bool
+}
+
+func mapTusUpload(u database.TusUpload) TusUpload {
+	return TusUpload{
+		ID:        u.ID,
+		Offset:    int64(u.UploadOffset),
+		Length:    int64(u.FileSize),
+		ExpiresAt: u.ExpiresAt,
+		Completed: u.CompletedAt != nil,
+	}
+}
+
+func tailKey(u database.TusUpload) string {
+	return u.FilePath + ".part"
+}
+
+// CreateTus starts a resumable upload of params.FileSize bytes
+func (s *Service) CreateTus(ctx context.Context, userID uuid.UUID, params IntentParams) (TusUpload, error) {
+	if !tusScopes[params.Scope] {
+		return TusUpload{}, fmt.Errorf("%w: resumable uploads only go to task drafts and workspaces", ErrInvalidUpload)
+	}
+	key, name, err := s.newKey(ctx, userID, params)
+	if err != nil {
+		return TusUpload{}, err
+	}
+
+	multipartID, err := s.storage.CreateMultipart(ctx, key, params.FileType)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	upload, err := s.store.CreateTusUpload(ctx, database.CreateTusUploadParams{
+		UserID:      userID,
+		Scope:       params.Scope,
+		TargetID:    params.TargetID,
+		FileName:    name,
+		FileType:    params.FileType,
+		FileSize:    int32(params.FileSize),
+		FilePath:    key,
+		MultipartID: multipartID,
+		ExpiresAt:   time.Now().Add(tusLifetime),
+	})
+	if err != nil {
+		if abortErr := s.storage.AbortMultipart(ctx, key, multipartID); abortErr != nil {
+			log.Printf("failed to abort multipart upload of %s: %v", key, abortErr)
+		}
+		return TusUpload{}, err
+	}
+	return mapTusUpload(upload), nil
+}
+
+func (s *Service) getTus(ctx context.Context, userID, id uuid.UUID) (database.TusUpload, error) {
+	upload, err := s.store.GetTusUpload(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return database.TusUpload{}, ErrNotFound
+	}
+	if err != nil {
+		return database.TusUpload{}, err
+	}
+	if upload.UserID != userID {
+		return database.TusUpload{}, ErrNotFound
+	}
+	return upload, nil
+}
+
+func (s *Service) GetTus(ctx context.Context, userID, id uuid.UUID) (TusUpload, error) {
+	upload, err := s.getTus(ctx, userID, id)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	return mapTusUpload(upload), nil
+}
+
+// WriteTus appends body to the upload, which must be at offset. What was
+// read is kept even when the body ends early, the client resumes from the
+// offset returned. The last byte completes the upload and records the
+// file in its scope.
+func (s *Service) WriteTus(ctx context.Context, userID, id uuid.UUID, offset int64, body io.Reader) (TusUpload, error) {
+	// the client dropping the connection must not keep what arrived from
+	// being saved
+	ctx = context.WithoutCancel(ctx)
+
+	unlock, err := s.lockTus(ctx, id)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	defer unlock()
+
+	upload, err := s.getTus(ctx, userID, id)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	length := int64(upload.FileSize)
+	if upload.CompletedAt != nil {
+		if offset != length {
+			return TusUpload{}, ErrOffsetMismatch
+		}
+		return mapTusUpload(upload), nil
+	}
+	if !upload.ExpiresAt.After(time.Now()) {
+		return TusUpload{}, ErrExpired
+	}
+	if offset != int64(upload.UploadOffset) {
+		return TusUpload{}, ErrOffsetMismatch
+	}
+
+	// a previous request assembled the file but failed to record it
+	if obj, err := s.storage.Head(ctx, upload.FilePath); err == nil && obj.Size == length {
+		return s.recordTus(ctx, upload)
+	}
+
+	var parts []tusPart
+	if err := json.Unmarshal(upload.Parts, &parts); err != nil {
+		return TusUpload{}, err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, storage.MinPartSize))
+	if upload.TailSize > 0 {
+		// only the bytes counted, a failed write may have left more
+		tail, err := s.storage.Get(ctx, tailKey(upload), &storage.Range{Start: 0, End: int64(upload.TailSize) - 1})
+		if err != nil {
+			return TusUpload{}, fmt.Errorf("failed to read the tail of upload %s: %w", upload.ID, err)
+		}
+		_, err = io.Copy(buf, tail)
+		tail.Close()
+		if err != nil {
+			return TusUpload{}, fmt.Errorf("failed to read the tail of upload %s: %w", upload.ID, err)
+		}
+	}
+
+	// one byte past the length tells an oversized body apart
+	body = io.LimitReader(body, length-offset+1)
+	for {
+		n, readErr := io.CopyN(buf, body, int64(storage.MinPartSize-buf.Len()))
+		offset += n
+		if offset > length {
+			return TusUpload{}, ErrTooLarge
+		}
+		if buf.Len() == storage.MinPartSize && offset < length {
+			upload, parts, err = s.flushPart(ctx, upload, parts, buf.Bytes(), offset)
+			if err != nil {
+				return TusUpload{}, err
+			}
+			buf.Reset()
+		}
+		if readErr != nil || offset == length {
+			break
+		}
+	}
+
+	if offset == length {
+		if n, _ := body.Read(make([]byte, 1)); n > 0 {
+			return TusUpload{}, ErrTooLarge
+		}
+		return s.finishTus(ctx, upload, parts, buf.Bytes())
+	}
+	if offset == int64(upload.UploadOffset) {
+		return mapTusUpload(upload), nil
+	}
+
+	// keep what falls short of a part for the next request
+	if buf.Len() > 0 {
+		err := s.storage.Put(ctx, tailKey(upload), bytes.NewReader(buf.Bytes()), int64(buf.Len()), "application/octet-stream")
+		if err != nil {
+			return TusUpload{}, err
+		}
+	}
+	upload, err = s.advanceTus(ctx, upload, parts, offset, buf.Len())
+	if err != nil {
+		return TusUpload{}, err
+	}
+	return mapTusUpload(upload), nil
+}
+
+// flushPart stores data as the next part and moves the upload to offset,
+// the tail it held is now in the part
+func (s *Service) flushPart(ctx context.Context, upload database.TusUpload, parts []tusPart, data []byte, offset int64) (database.TusUpload, []tusPart, error) {
+	number := int32(len(parts) + 1)
+	part, err := s.storage.UploadPart(ctx, upload.FilePath, upload.MultipartID, number, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return upload, parts, err
+	}
+	parts = append(parts, tusPart{Number: part.Number, ETag: part.ETag, Size: part.Size})
+
+	hadTail := upload.TailSize > 0
+	upload, err = s.advanceTus(ctx, upload, parts, offset, 0)
+	if err != nil {
+		return upload, parts, err
+	}
+	if hadTail {
+		if err := s.storage.Delete(ctx, tailKey(upload)); err != nil {
+			log.Printf("failed to delete the tail of upload %s: %v", upload.ID, err)
+		}
+	}
+	return upload, parts, nil
+}
+
+func (s *Service) advanceTus(ctx context.Context, upload database.TusUpload, parts []tusPart, offset int64, tailSize int) (database.TusUpload, error) {
+	encoded, err := json.Marshal(parts)
+	if err != nil {
+		return upload, err
+	}
+	updated, err := s.store.AdvanceTusUpload(ctx, database.AdvanceTusUploadParams{
+		ID:             upload.ID,
+		PreviousOffset: upload.UploadOffset,
+		UploadOffset:   int32(offset),
+		Parts:          encoded,
+		TailSize:       int32(tailSize),
+		ExpiresAt:      time.Now().Add(tusLifetime),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return upload, ErrOffsetMismatch
+	}
+	return updated, err
+}
+
+// finishTus stores the last part, assembles the file and records it
+func (s *Service) finishTus(ctx context.Context, upload database.TusUpload, parts []tusPart, last []byte) (TusUpload, error) {
+	number := int32(len(parts) + 1)
+	part, err := s.storage.UploadPart(ctx, upload.FilePath, upload.MultipartID, number, bytes.NewReader(last), int64(len(last)))
+	if err != nil {
+		return TusUpload{}, err
+	}
+	parts = append(parts, tusPart{Number: part.Number, ETag: part.ETag, Size: part.Size})
+
+	completed := make([]storage.Part, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, storage.Part{Number: p.Number, ETag: p.ETag, Size: p.Size})
+	}
+	if err := s.storage.CompleteMultipart(ctx, upload.FilePath, upload.MultipartID, completed); err != nil {
+		return TusUpload{}, err
+	}
+	return s.recordTus(ctx, upload)
+}
+
+func (s *Service) recordTus(ctx context.Context, upload database.TusUpload) (TusUpload, error) {
+	obj, err := s.storage.Head(ctx, upload.FilePath)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	if obj.Size != int64(upload.FileSize) {
+		return TusUpload{}, fmt.Errorf("assembled %d bytes of %d for upload %s", obj.Size, upload.FileSize, upload.ID)
+	}
+	detected, err := s.fileService.CheckStored(ctx, scopes[upload.Scope], upload.FilePath, upload.FileName, obj.Size)
+	if errors.Is(err, file.ErrRejected) {
+		if discardErr := s.discardTus(ctx, upload); discardErr != nil {
+			log.Printf("failed to discard rejected upload %s: %v", upload.ID, discardErr)
+		}
+		return TusUpload{}, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+	}
+	if err != nil {
+		return TusUpload{}, err
+	}
+
+	_, err = s.record(ctx, upload.UserID, upload.Scope, upload.TargetID, file.FileMeta{
+		FileName: upload.FileName,
+		FileType: detected.Type,
+		FileSize: float64(upload.FileSize),
+		FilePath: upload.FilePath,
+	})
+	if err != nil {
+		return TusUpload{}, err
+	}
+
+	completed, err := s.store.CompleteTusUpload(ctx, upload.ID)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	if upload.TailSize > 0 {
+		if err := s.storage.Delete(ctx, tailKey(upload)); err != nil {
+			log.Printf("failed to delete the tail of upload %s: %v", upload.ID, err)
+		}
+	}
+	return mapTusUpload(completed), nil
+}
+
+// DeleteTus gives up an unfinished upload and frees what it stored
+func (s *Service) DeleteTus(ctx context.Context, userID, id uuid.UUID) error {
+	unlock, err := s.lockTus(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	upload, err := s.getTus(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if upload.CompletedAt != nil {
+		return fmt.Errorf("%w: upload is complete, delete the file instead", ErrInvalidUpload)
+	}
+	return s.discardTus(ctx, upload)
+}
+
+// discardTus frees everything an unfinished upload stored
+func (s *Service) discardTus(ctx context.Context, upload database.TusUpload) error {
+	if err := s.storage.AbortMultipart(ctx, upload.FilePath, upload.MultipartID); err != nil {
+		return err
+	}
+	for _, key := range []string{tailKey(upload), upload.FilePath} {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return s.store.DeleteTusUpload(ctx, upload.ID)
+}
+
+// lockTus keeps a single request at a time writing to the upload, across
+// instances. Two writers would otherwise store the same part number.
+func (s *Service) lockTus(ctx context.Context, id uuid.UUID) (func(), error) {
+	key := tusLockPrefix + id.String()
+	locked, err := s.redis.SetNX(ctx, key, 1, tusLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrLocked
+	}
+	return func() {
+		if err := s.redis.Del(context.WithoutCancel(ctx), key).Err(); err != nil {
+			log.Printf("failed to unlock upload %s: %v", id, err)
+		}
+	}, nil
+}
@@ -0,0 +1,362 @@
+// Package upload lets clients put files straight into the object store.
+// The client asks for an intent, uploads to the presigned url it returns,
+// then confirms it; the server checks the stored object against the intent
+// before recording it where the scope says.
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github/abdallemo/solveit-saas/internal/chat"
+	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/editor"
+	"github/abdallemo/solveit-saas/internal/file"
+	"github/abdallemo/solveit-saas/internal/storage"
+	"github/abdallemo/solveit-saas/internal/task"
+	"github/abdallemo/solveit-saas/internal/workspace"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// urlLifetime is how long the client has to start the upload
+	urlLifetime = 15 * time.Minute
+	// intentLifetime is how long the client has to confirm it, the object
+	// of an intent still unconfirmed by then is deleted
+	intentLifetime = time.Hour
+	maxNameLength  = 255
+)
+
+var (
+	ErrNotFound      = errors.New("upload not found")
+	ErrForbidden     = errors.New("not allowed to upload there")
+	ErrInvalidUpload = errors.New("invalid upload")
+	ErrExpired       = errors.New("upload expired")
+	ErrNotUploaded   = errors.New("file was not uploaded")
+)
+
+// scopes maps each scope to the prefix of its keys, the same ones the
+// multipart uploads use
+var scopes = map[database.UploadScope]string{
+	database.UploadScopeTASKDRAFT: "task",
+	database.UploadScopeWORKSPACE: "workspace",
+	database.UploadScopeCHAT:      "mentorship",
+	database.UploadScopeEDITOR:    "editor-images",
+}
+
+type IntentParams struct {
+	Scope    database.UploadScope `json:"scope"`
+	TargetID *uuid.UUID           `json:"targetId"` // the workspace or mentorship session
+	FileName string               `json:"fileName"`
+	FileType string               `json:"fileType"`
+	FileSize int64                `json:"fileSize"`
+}
+
+type Intent struct {
+	ID        uuid.UUID         `json:"id"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"` // to send with the upload
+	FilePath  string            `json:"filePath"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+type Confirmed struct {
+	File file.FileMeta       `json:"file"`
+	Chat *chat.ChatWithFiles `json:"chat,omitempty"` // the message carrying a chat upload
+	URL  string              `json:"url,omitempty"`  // the media path of an editor image
+}
+
+type Service struct {
+	store            *database.Queries
+	storage          storage.Storage
+	redis            *redis.Client
+	fileService      *file.Service
+	taskService      *task.Service
+	workspaceService *workspace.Service
+	editorService    *editor.Service
+	chatService      *chat.Service
+}
+
+func NewService(
+	store *database.Queries,
+	storage storage.Storage,
+	redisClient *redis.Client,
+	fileService *file.Service,
+	taskService *task.Service,
+	workspaceService *workspace.Service,
+	editorService *editor.Service,
+	chatService *chat.Service,
+) *Service {
+	return &Service{
+		store:            store,
+		storage:          storage,
+		redis:            redisClient,
+		fileService:      fileService,
+		taskService:      taskService,
+		workspaceService: workspaceService,
+		editorService:    editorService,
+		chatService:      chatService,
+	}
+}
+
+// CreateIntent reserves a key for the file and presigns a PUT to it that
+// only accepts the declared size and type
+func (s *Service) CreateIntent(ctx context.Context, userID uuid.UUID, params IntentParams) (Intent, error) {
+	key, name, err := s.newKey(ctx, userID, params)
+	if err != nil {
+		return Intent{}, err
+	}
+
+	url, err := s.storage.Presign(ctx, key, storage.PresignOptions{
+		Method:        http.MethodPut,
+		Expires:       urlLifetime,
+		ContentType:   params.FileType,
+		ContentLength: params.FileSize,
+	})
+	if err != nil {
+		return Intent{}, err
+	}
+
+	intent, err := s.store.CreateUploadIntent(ctx, database.CreateUploadIntentParams{
+		UserID:    userID,
+		Scope:     params.Scope,
+		TargetID:  params.TargetID,
+		FileName:  name,
+		FileType:  params.FileType,
+		FileSize:  int32(params.FileSize),
+		FilePath:  key,
+		ExpiresAt: time.Now().Add(intentLifetime),
+	})
+	if err != nil {
+		return Intent{}, err
+	}
+
+	return Intent{
+		ID:        intent.ID,
+		URL:       url,
+		Method:    http.MethodPut,
+		Headers:   map[string]string{"Content-Type": params.FileType},
+		FilePath:  intent.FilePath,
+		ExpiresAt: intent.ExpiresAt,
+	}, nil
+}
+
+// Confirm checks the uploaded object against the intent and records the
+// file in the scope of the intent. An object that does not match is
+// deleted along with the intent.
+func (s *Service) Confirm(ctx context.Context, userID, intentID uuid.UUID) (Confirmed, error) {
+	intent, err := s.store.GetUploadIntent(ctx, intentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Confirmed{}, ErrNotFound
+	}
+	if err != nil {
+		return Confirmed{}, err
+	}
+	if intent.UserID != userID {
+		return Confirmed{}, ErrNotFound
+	}
+	if intent.ConfirmedAt != nil {
+		return Confirmed{}, fmt.Errorf("%w: already confirmed", ErrInvalidUpload)
+	}
+	if !intent.ExpiresAt.After(time.Now()) {
+		return Confirmed{}, ErrExpired
+	}
+
+	obj, err := s.storage.Head(ctx, intent.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Confirmed{}, ErrNotUploaded
+	}
+	if err != nil {
+		return Confirmed{}, err
+	}
+	if reason := mismatch(intent, obj); reason != "" {
+		s.discard(ctx, intent)
+		return Confirmed{}, fmt.Errorf("%w: %s", ErrInvalidUpload, reason)
+	}
+	detected, err := s.fileService.CheckStored(ctx, scopes[intent.Scope], intent.FilePath, intent.FileName, obj.Size)
+	if errors.Is(err, file.ErrRejected) {
+		s.discard(ctx, intent)
+		return Confirmed{}, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+	}
+	if err != nil {
+		return Confirmed{}, err
+	}
+
+	// confirming first keeps two concurrent calls from recording it twice
+	intent, err = s.store.ConfirmUploadIntent(ctx, intentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Confirmed{}, fmt.Errorf("%w: already confirmed or expired", ErrInvalidUpload)
+	}
+	if err != nil {
+		return Confirmed{}, err
+	}
+
+	confirmed, err := s.record(ctx, intent.UserID, intent.Scope, intent.TargetID, file.FileMeta{
+		FileName: intent.FileName,
+		FileType: detected.Type,
+		FileSize: float64(intent.FileSize),
+		FilePath: intent.FilePath,
+	})
+	if err != nil {
+		// let the client retry rather than leave the object unreferenced
+		if releaseErr := s.store.ReleaseUploadIntent(ctx, intent.ID); releaseErr != nil {
+			log.Printf("failed to release upload intent %s: %v", intent.ID, releaseErr)
+		}
+		return Confirmed{}, err
+	}
+	return confirmed, nil
+}
+
+// newKey validates the file about to be uploaded and picks the key to store
+// it under, along with its cleaned up name
+func (s *Service) newKey(ctx context.Context, userID uuid.UUID, params IntentParams) (key, name string, err error) {
+	prefix, ok := scopes[params.Scope]
+	if !ok {
+		return "", "", fmt.Errorf("%w: unknown scope %q", ErrInvalidUpload, params.Scope)
+	}
+	name, err = cleanName(params.FileName)
+	if err != nil {
+		return "", "", err
+	}
+	if params.FileSize <= 0 || params.FileSize >= file.MaxFileSize {
+		return "", "", fmt.Errorf("%w: file size must be between 1 byte and 50MB", ErrInvalidUpload)
+	}
+	if err := checkType(params.Scope, params.FileType); err != nil {
+		return "", "", err
+	}
+	if err := s.authorize(ctx, userID, params.Scope, params.TargetID); err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%s/%s-%s", prefix, uuid.New(), name), name, nil
+}
+
+// record adds the stored file to the target of the scope
+func (s *Service) record(ctx context.Context, userID uuid.UUID, scope database.UploadScope, targetID *uuid.UUID, meta file.FileMeta) (Confirmed, error) {
+	confirmed := Confirmed{File: meta}
+
+	// the target may have changed hands since the upload started
+	if err := s.authorize(ctx, userID, scope, targetID); err != nil {
+		return Confirmed{}, err
+	}
+	if err := s.fileService.TrackScan(ctx, userID, meta.FilePath); err != nil {
+		return Confirmed{}, err
+	}
+
+	switch scope {
+	case database.UploadScopeTASKDRAFT:
+		if err := s.taskService.AddDraftTaskFiles(ctx, userID, []file.FileMeta{meta}); err != nil {
+			return Confirmed{}, err
+		}
+	case database.UploadScopeWORKSPACE:
+		if err := s.workspaceService.AddFiles(ctx, *targetID, userID, []file.FileMeta{meta}); err != nil {
+			return Confirmed{}, err
+		}
+	case database.UploadScopeEDITOR:
+		editorFile, err := s.editorService.AddEditorFile(ctx, meta)
+		if err != nil {
+			return Confirmed{}, err
+		}
+		confirmed.URL = editorFile.Url
+	case database.UploadScopeCHAT:
+		sentTo, err := s.chatService.Counterpart(ctx, *targetID, userID)
+		if err != nil {
+			return Confirmed{}, err
+		}
+		msg, err := s.chatService.CreateChatWithFiles(ctx, "", "chat_message",
+			*targetID, userID, sentTo, nil, []file.FileMeta{meta})
+		if err != nil {
+			return Confirmed{}, err
+		}
+		confirmed.Chat = &msg
+	default:
+		return Confirmed{}, fmt.Errorf("%w: unknown scope %q", ErrInvalidUpload, scope)
+	}
+	return confirmed, nil
+}
+
+// authorize checks that the user may add files to the target of the scope
+func (s *Service) authorize(ctx context.Context, userID uuid.UUID, scope database.UploadScope, targetID *uuid.UUID) error {
+	switch scope {
+	case database.UploadScopeWORKSPACE, database.UploadScopeCHAT:
+		if targetID == nil {
+			return fmt.Errorf("%w: %s uploads need a target", ErrInvalidUpload, strings.ToLower(string(scope)))
+		}
+	}
+
+	var err error
+	switch scope {
+	case database.UploadScopeWORKSPACE:
+		err = s.workspaceService.CanUpload(ctx, *targetID, userID)
+	case database.UploadScopeCHAT:
+		_, err = s.chatService.Counterpart(ctx, *targetID, userID)
+	}
+	switch {
+	case errors.Is(err, workspace.ErrNotFound), errors.Is(err, chat.ErrNotFound):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case errors.Is(err, workspace.ErrForbidden), errors.Is(err, chat.ErrForbidden):
+		return fmt.Errorf("%w: %v", ErrForbidden, err)
+	}
+	return err
+}
+
+// discard deletes the object and the intent of a rejected upload
+func (s *Service) discard(ctx context.Context, intent database.UploadIntent) {
+	if err := s.storage.Delete(ctx, intent.FilePath); err != nil {
+		log.Printf("failed to delete rejected upload %s: %v", intent.FilePath, err)
+		return
+	}
+	if err := s.store.DeleteUploadIntent(ctx, intent.ID); err != nil {
+		log.Printf("failed to delete upload intent %s: %v", intent.ID, err)
+	}
+}
+
+// mismatch tells how the stored object differs from the intent, if it does
+func mismatch(intent database.UploadIntent, obj storage.Object) string {
+	if obj.Size != int64(intent.FileSize) {
+		return fmt.Sprintf("uploaded %d bytes, expected %d", obj.Size, intent.FileSize)
+	}
+	stored, _, err := mime.ParseMediaType(obj.ContentType)
+	if err != nil {
+		return "uploaded file has no valid content type"
+	}
+	declared, _, _ := mime.ParseMediaType(intent.FileType)
+	// stores that do not keep the type, like the local one, report it as
+	// generic bytes when the extension says nothing
+	if stored != declared && stored != "application/octet-stream" {
+		return fmt.Sprintf("uploaded %s, expected %s", stored, declared)
+	}
+	return ""
+}
+
+func cleanName(name string) (string, error) {
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), `\`, "/"))
+	if name == "" || name == "." || name == "/" || name == ".." {
+		return "", fmt.Errorf("%w: missing file name", ErrInvalidUpload)
+	}
+	if len(name) > maxNameLength {
+		return "", fmt.Errorf("%w: file name is longer than %d bytes", ErrInvalidUpload, maxNameLength)
+	}
+	return name, nil
+}
+
+func checkType(scope database.UploadScope, fileType string) error {
+	mediaType, _, err := mime.ParseMediaType(fileType)
+	if err != nil || !strings.Contains(mediaType, "/") {
+		return fmt.Errorf("%w: invalid file type %q", ErrInvalidUpload, fileType)
+	}
+	if scope == database.UploadScopeEDITOR && !strings.HasPrefix(mediaType, "image/") {
+		return fmt.Errorf("%w: editor uploads must be images", ErrInvalidUpload)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const (
+	recordSize = 4096 // the only record of a push message, rs of RFC 8188
+	// header (salt, rs, idlen, keyid), delimiter and tag of the record
+	recordOverhead = 16 + 4 + 1 + 65 + 1 + 16
+	maxPayload     = recordSize - recordOverhead
+)
+
+// encrypt seals payload for the subscription as a single aes128gcm record,
+// following RFC 8291.
+func encrypt(uaPublic, authSecret, payload []byte) ([]byte, error) {
+	if len(payload) > maxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return seal(asKey, salt, uaPublic, authSecret, payload)
+}
+
+// seal encrypts with the given application server key and salt
+func seal(asKey *ecdh.PrivateKey, salt, uaPublic, authSecret, payload []byte) ([]byte, error) {
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, ErrInvalidSubscription
+	}
+	ecdhSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	// IKM = HKDF(auth_secret, ecdh_secret, "WebPush: info" || 0x00 || ua_public || as_public, 32)
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := hkdf.Key(sha256.New, ecdhSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, 0, recordOverhead+len(payload))
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(asPublic)))
+	body = append(body, asPublic...)
+
+	// 0x02 marks the last (and only) record, no padding follows
+	plaintext := append(payload[:len(payload):len(payload)], 0x02)
+	return gcm.Seal(body, nonce, plaintext, nil), nil
+}
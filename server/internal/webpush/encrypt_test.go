@@ -0,0 +1,75 @@
+package webpush
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"errors"
+	"testing"
+)
+
+// the example of RFC 8291, appendix A
+const (
+	rfcPlaintext  = "When I grow up, I want to be a watermelon"
+	rfcAuthSecret = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcUAPrivate  = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfcUAPublic   = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcASPrivate  = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcASPublic   = "BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8"
+	rfcSalt       = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcMessage    = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := decodeBase64(s)
+	if err != nil {
+		t.Fatalf("decoding %q: %v", s, err)
+	}
+	return b
+}
+
+func TestSealRFC8291(t *testing.T) {
+	asKey, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcASPrivate))
+	if err != nil {
+		t.Fatalf("application server key: %v", err)
+	}
+	if !bytes.Equal(asKey.PublicKey().Bytes(), mustDecode(t, rfcASPublic)) {
+		t.Fatal("application server public key does not match the example")
+	}
+	uaKey, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcUAPrivate))
+	if err != nil {
+		t.Fatalf("user agent key: %v", err)
+	}
+	uaPublic := mustDecode(t, rfcUAPublic)
+	if !bytes.Equal(uaKey.PublicKey().Bytes(), uaPublic) {
+		t.Fatal("user agent public key does not match the example")
+	}
+
+	got, err := seal(asKey, mustDecode(t, rfcSalt), uaPublic, mustDecode(t, rfcAuthSecret), []byte(rfcPlaintext))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if want := mustDecode(t, rfcMessage); !bytes.Equal(got, want) {
+		t.Errorf("seal = %x\nwant   %x", got, want)
+	}
+}
+
+func TestEncryptSizes(t *testing.T) {
+	uaKey, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcUAPrivate))
+	if err != nil {
+		t.Fatalf("user agent key: %v", err)
+	}
+	uaPublic := uaKey.PublicKey().Bytes()
+	authSecret := mustDecode(t, rfcAuthSecret)
+
+	body, err := encrypt(uaPublic, authSecret, make([]byte, maxPayload))
+	if err != nil {
+		t.Fatalf("encrypt of the largest payload: %v", err)
+	}
+	if len(body) != recordSize {
+		t.Errorf("record is %d bytes, want %d", len(body), recordSize)
+	}
+	if _, err := encrypt(uaPublic, authSecret, make([]byte, maxPayload+1)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("encrypt past the limit error = %v, want ErrPayloadTooLarge", err)
+	}
+}
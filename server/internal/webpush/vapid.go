@@ -0,0 +1,82 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const vapidTTL = 12 * time.Hour // push services reject tokens valid for over 24h
+
+// parseVAPIDKey reads the base64url private key, as printed by
+// `web-push generate-vapid-keys`, and returns it with its raw public key.
+func parseVAPIDKey(privateKey string) (*ecdsa.PrivateKey, []byte, error) {
+	d, err := decodeBase64(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	public := key.PublicKey().Bytes()
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}, public, nil
+}
+
+// authorization returns the RFC 8292 Authorization header for endpoint
+func (s *Service) authorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}{
+		Aud: u.Scheme + "://" + u.Host,
+		Exp: time.Now().Add(vapidTTL).Unix(),
+		Sub: s.config.Subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.signer, hash[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+
+	return fmt.Sprintf("vapid t=%s.%s, k=%s", unsigned,
+		base64.RawURLEncoding.EncodeToString(signature),
+		base64.RawURLEncoding.EncodeToString(s.publicKey)), nil
+}
+
+// decodeBase64 accepts the padded and unpadded url alphabets browsers use
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,306 @@
+// Package webpush delivers notifications to the browsers of users without
+// an open tab through the Web Push protocol
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github/abdallemo/solveit-saas/internal/api/websocket"
+	"github/abdallemo/solveit-saas/internal/database"
+
+	"github.com/google/uuid"
+)
+
+const (
+	pushTTL        = 24 * time.Hour // how long a push service keeps an undelivered message
+	requestTimeout = 10 * time.Second
+	maxBodyLength  = 1000 // characters of the content sent in a push
+)
+
+// pushHosts are the push services endpoints may point at, anything else
+// would have the server post to arbitrary urls
+var pushHosts = []string{
+	"fcm.googleapis.com",
+	"push.services.mozilla.com",
+	"push.apple.com",
+	"notify.windows.com",
+}
+
+var (
+	ErrNotConfigured       = errors.New("web push is not configured")
+	ErrInvalidSubscription = errors.New("invalid push subscription")
+	ErrNotFound            = errors.New("push subscription not found")
+	ErrPayloadTooLarge     = errors.New("push payload is too large")
+)
+
+type Config struct {
+	PublicKey  string // base64url, handed to the browser as applicationServerKey
+	PrivateKey string // base64url
+	Subject    string // mailto: or https: contact of the sender
+}
+
+// Subscription is the JSON of a browser PushSubscription
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Payload is what the service worker receives in its push event
+type Payload struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	EventType string `json:"eventType,omitempty"`
+	URL       string `json:"url"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type Service struct {
+	store     *database.Queries
+	config    Config
+	signer    *ecdsa.PrivateKey
+	publicKey []byte
+	client    *http.Client
+}
+
+func NewService(store *database.Queries, config Config) (*Service, error) {
+	s := &Service{
+		store:  store,
+		config: config,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+	if config.PrivateKey == "" {
+		return s, nil
+	}
+
+	signer, public, err := parseVAPIDKey(config.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	if config.PublicKey != "" {
+		if given, err := decodeBase64(config.PublicKey); err != nil || !bytes.Equal(given, public) {
+			return nil, errors.New("VAPID public key does not match the private key")
+		}
+	}
+	s.signer, s.publicKey = signer, public
+	return s, nil
+}
+
+// Enabled reports whether VAPID keys are configured
+func (s *Service) Enabled() bool {
+	return s.signer != nil
+}
+
+// PublicKey is the applicationServerKey browsers subscribe with
+func (s *Service) PublicKey() (string, error) {
+	if !s.Enabled() {
+		return "", ErrNotConfigured
+	}
+	return base64.RawURLEncoding.EncodeToString(s.publicKey), nil
+}
+
+// Subscribe stores the subscription of a device, a browser subscribing
+// again replaces its previous keys.
+func (s *Service) Subscribe(ctx context.Context, userID uuid.UUID, sub Subscription, userAgent string) (database.PushSubscription, error) {
+	if !s.Enabled() {
+		return database.PushSubscription{}, ErrNotConfigured
+	}
+	if err := validateSubscription(sub); err != nil {
+		return database.PushSubscription{}, err
+	}
+
+	params := database.UpsertPushSubscriptionParams{
+		UserID:   userID,
+		Endpoint: sub.Endpoint,
+		P256dh:   sub.Keys.P256dh,
+		Auth:     sub.Keys.Auth,
+	}
+	if userAgent != "" {
+		params.UserAgent = &userAgent
+	}
+	return s.store.UpsertPushSubscription(ctx, params)
+}
+
+func (s *Service) Unsubscribe(ctx context.Context, userID uuid.UUID, endpoint string) error {
+	deleted, err := s.store.DeletePushSubscription(ctx, database.DeletePushSubscriptionParams{
+		UserID:   userID,
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func validateSubscription(sub Subscription) error {
+	u, err := url.Parse(sub.Endpoint)
+	if err != nil || u.Scheme != "https" || !knownPushHost(u.Hostname()) {
+		return fmt.Errorf("%w: unsupported endpoint", ErrInvalidSubscription)
+	}
+	if key, err := decodeBase64(sub.Keys.P256dh); err != nil || len(key) != 65 || key[0] != 0x04 {
+		return fmt.Errorf("%w: p256dh must be an uncompressed P-256 key", ErrInvalidSubscription)
+	}
+	if auth, err := decodeBase64(sub.Keys.Auth); err != nil || len(auth) != 16 {
+		return fmt.Errorf("%w: auth must be 16 bytes", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+func knownPushHost(host string) bool {
+	for _, known := range pushHosts {
+		if host == known || strings.HasSuffix(host, "."+known) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyOffline pushes a notification to every device of a user who has
+// no open tab. It is registered on the notification hub.
+func (s *Service) NotifyOffline(userID string, msg websocket.Message) {
+	if !s.Enabled() {
+		return
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return
+	}
+
+	payload, err := marshalPayload(Payload{
+		ID:        msg.ID,
+		Title:     msg.Subject,
+		Body:      truncate(msg.Content, maxBodyLength),
+		EventType: msg.EventType,
+		URL:       "/dashboard/notifications",
+		CreatedAt: msg.CreatedAt,
+	})
+	if err != nil {
+		log.Printf("Marshal error for push of %s: %v", msg.ID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*requestTimeout)
+	defer cancel()
+	if err := s.SendToUser(ctx, id, payload); err != nil {
+		log.Printf("Failed to push notification %s to %s: %v", msg.ID, userID, err)
+	}
+}
+
+// marshalPayload encodes p, shortening the body and then the title until it
+// fits in a single record. Multibyte text and escaping can make the JSON
+// several times longer than the text in it.
+func marshalPayload(p Payload) ([]byte, error) {
+	for {
+		data, err := json.Marshal(p)
+		if err != nil || len(data) <= maxPayload {
+			return data, err
+		}
+		// a character takes at most 6 bytes, as an escape like \u003c, so
+		// this cuts little more than needed and is repeated until it fits
+		drop := (len(data)-maxPayload+5)/6 + len("...")
+		switch {
+		case p.Body != "":
+			p.Body = truncate(p.Body, utf8.RuneCountInString(p.Body)-drop)
+		case p.Title != "":
+			p.Title = truncate(p.Title, utf8.RuneCountInString(p.Title)-drop)
+		default:
+			return nil, ErrPayloadTooLarge
+		}
+	}
+}
+
+// truncate cuts s to n characters, marking it as cut. Nothing is left of it
+// when n is not positive.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	return string(runes[:n]) + "..."
+}
+
+// SendToUser pushes payload to all devices of the user, pruning the
+// subscriptions the push service no longer knows.
+func (s *Service) SendToUser(ctx context.Context, userID uuid.UUID, payload []byte) error {
+	subs, err := s.store.ListPushSubscriptions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		status, err := s.send(ctx, sub, payload)
+		switch {
+		case err != nil:
+			log.Printf("Push to subscription %s failed: %v", sub.ID, err)
+		case status == http.StatusNotFound || status == http.StatusGone:
+			log.Printf("Pruning expired push subscription %s", sub.ID)
+			if err := s.store.PrunePushSubscription(ctx, sub.ID); err != nil {
+				log.Printf("Failed to prune push subscription %s: %v", sub.ID, err)
+			}
+		case status >= 300:
+			log.Printf("Push service refused subscription %s with status %d", sub.ID, status)
+		default:
+			if err := s.store.TouchPushSubscription(ctx, sub.ID); err != nil {
+				log.Printf("Failed to touch push subscription %s: %v", sub.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Service) send(ctx context.Context, sub database.PushSubscription, payload []byte) (int, error) {
+	uaPublic, err := decodeBase64(sub.P256dh)
+	if err != nil {
+		return 0, ErrInvalidSubscription
+	}
+	authSecret, err := decodeBase64(sub.Auth)
+	if err != nil {
+		return 0, ErrInvalidSubscription
+	}
+	body, err := encrypt(uaPublic, authSecret, payload)
+	if err != nil {
+		return 0, err
+	}
+	authorization, err := s.authorization(sub.Endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprint(int(pushTTL.Seconds())))
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,50 @@
+package webpush
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMarshalPayload(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		body  string
+		// whether the text has to be cut
+		cut bool
+	}{
+		{name: "short", title: "A new task", body: "hello"},
+		{name: "longest body", title: "A new task", body: truncate(strings.Repeat("a", 2000), maxBodyLength)},
+		{name: "multibyte body", title: "A new task", body: truncate(strings.Repeat("😀", 2000), maxBodyLength), cut: true},
+		{name: "escaped body", title: "A new task", body: truncate(strings.Repeat("<", 2000), maxBodyLength), cut: true},
+		{name: "escaped title", title: strings.Repeat("<", 2000), body: "", cut: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := marshalPayload(Payload{ID: "id", Title: tt.title, Body: tt.body, URL: "/dashboard/notifications"})
+			if err != nil {
+				t.Fatalf("marshalPayload: %v", err)
+			}
+			if len(data) > maxPayload {
+				t.Fatalf("payload is %d bytes, more than %d", len(data), maxPayload)
+			}
+			var got Payload
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("payload is not JSON: %v", err)
+			}
+			if !utf8.ValidString(got.Body) || !utf8.ValidString(got.Title) {
+				t.Error("text was cut inside a character")
+			}
+			cut := got.Title != tt.title || got.Body != tt.body
+			if cut != tt.cut {
+				t.Errorf("text cut = %v, want %v", cut, tt.cut)
+			}
+			if cut && !strings.HasSuffix(got.Title+got.Body, "...") {
+				t.Error("cut text is not marked as cut")
+			}
+		})
+	}
+}
@@ -9,9 +9,7 @@ import (
 
 	"github/abdallemo/solveit-saas/internal/database"
 	"github/abdallemo/solveit-saas/internal/file"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github/abdallemo/solveit-saas/internal/storage"
 )
 
 func (w *Worker) StartDraftMediaCleanupJob(ctx context.Context, timeBetweenChecks time.Duration) {
@@ -25,7 +23,7 @@ func (w *Worker) StartDraftMediaCleanupJob(ctx context.Context, timeBetweenCheck
 			return
 		case <-ticker.C:
 			log.Println("Running scheduled draft task media cleanup")
-			taskDrafts, err := w.store.GetAllTaskDrafts(ctx, time.Now().Add(-7*time.Hour*24))
+			taskDrafts, err := w.store.GetAllTaskDrafts(context.WithoutCancel(ctx), time.Now().Add(-7*time.Hour*24))
 			if err != nil {
 				log.Println("error getting draft tasks")
 				continue
@@ -35,7 +33,7 @@ func (w *Worker) StartDraftMediaCleanupJob(ctx context.Context, timeBetweenCheck
 			for _, draftTask := range taskDrafts {
 				wg.Add(1)
 
-				go runCleanup(wg, w.store, w.s3, draftTask)
+				go runCleanup(wg, w.store, w.storage, draftTask)
 
 			}
 			wg.Wait()
@@ -43,7 +41,7 @@ func (w *Worker) StartDraftMediaCleanupJob(ctx context.Context, timeBetweenCheck
 	}
 }
 
-func runCleanup(wg *sync.WaitGroup, store *database.Queries, s3client *s3.Client, draftTask database.TaskDraft) {
+func runCleanup(wg *sync.WaitGroup, store *database.Queries, objects storage.Storage, draftTask database.TaskDraft) {
 	defer wg.Done()
 	files := []file.FileMeta{}
 	_, err := json.Marshal(draftTask.UploadedFiles)
@@ -52,12 +50,9 @@ func runCleanup(wg *sync.WaitGroup, store *database.Queries, s3client *s3.Client
 		return
 	}
 	for _, file := range files {
-		_, err = s3client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-			Bucket: aws.String("solveit"),
-			Key:    aws.String(file.FilePath),
-		})
+		err = objects.Delete(context.TODO(), file.FilePath)
 		if err != nil {
-			log.Printf("Error Deleting from S3: %v", err.Error())
+			log.Printf("Error Deleting from storage: %v", err.Error())
 			return
 		}
 
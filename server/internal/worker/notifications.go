@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const digestLockKey = "lock:notification-digest"
+
+// StartNotificationDigestJob sends the due notification digests, one
+// instance at a time.
+func (w *Worker) StartNotificationDigestJob(ctx context.Context, timeBetweenChecks time.Duration) {
+	ticker := time.NewTicker(timeBetweenChecks)
+	log.Println("Starting Background Job for notification digests")
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Notification digest shutting down...")
+			return
+		case <-ticker.C:
+			runCtx := context.WithoutCancel(ctx)
+			locked, err := w.redis.SetNX(runCtx, digestLockKey, 1, timeBetweenChecks).Result()
+			if err != nil || !locked {
+				continue
+			}
+
+			sent, err := w.notifications.SendDigests(runCtx)
+			if err != nil {
+				log.Printf("Error sending notification digests: %v", err)
+			}
+			if sent > 0 {
+				log.Printf("Queued %d notification digests", sent)
+			}
+		}
+	}
+}
@@ -2,26 +2,26 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"log"
 	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github/abdallemo/solveit-saas/internal/storage"
 )
 
 func (w *Worker) StartFileGarbageCollectorJob(ctx context.Context, timeBetweenChecks time.Duration) {
 	ticker := time.NewTicker(timeBetweenChecks)
 	log.Println("Starting Background Job for file garbage collection")
 	defer ticker.Stop()
-	go w.runCleanup(ctx)
+	w.runCleanup(context.WithoutCancel(ctx))
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("File garbage collection shutting down...")
 			return
 		case <-ticker.C:
-			go w.runCleanup(ctx)
+			w.runCleanup(context.WithoutCancel(ctx))
 		}
 	}
 }
@@ -54,20 +54,13 @@ func (w *Worker) deleteUnreferencedS3Files(ctx context.Context) {
 	log.Println("Starting S3 Unreferenced file cleanup")
 
 	s3Files := map[string]struct{}{}
-	paginator := s3.NewListObjectsV2Paginator(w.s3, &s3.ListObjectsV2Input{
-		Bucket: aws.String("solveit"),
-		Prefix: aws.String(""),
+	err := w.storage.List(ctx, "", func(obj storage.Object) error {
+		s3Files[obj.Key] = struct{}{}
+		return nil
 	})
-
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			log.Printf("Error listing S3 objects: %v", err)
-			return
-		}
-		for _, obj := range page.Contents {
-			s3Files[*obj.Key] = struct{}{}
-		}
+	if err != nil {
+		log.Printf("Error listing S3 objects: %v", err)
+		return
 	}
 
 	dbFiles := map[string]struct{}{}
@@ -76,6 +69,11 @@ func (w *Worker) deleteUnreferencedS3Files(ctx context.Context) {
 		w.store.GetAllWorkspaceFilePaths,
 		w.store.GetAllChatFilePaths,
 		w.store.GetAllMediaFilePaths,
+		// uploaded straight to the store and not confirmed yet
+		w.store.GetAllPendingUploadFilePaths,
+		w.store.GetAllPendingTusFilePaths,
+		// kept for whoever looks into the infected files
+		w.store.GetAllQuarantinedFilePaths,
 	}
 	for _, fetch := range tables {
 		paths, err := fetch(ctx)
@@ -90,15 +88,15 @@ func (w *Worker) deleteUnreferencedS3Files(ctx context.Context) {
 	deletedCount := 0
 	for key := range s3Files {
 		if _, exists := dbFiles[key]; !exists {
-			_, err := w.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
-				Bucket: aws.String("solveit"),
-				Key:    aws.String(key),
-			})
+			err := w.storage.Delete(ctx, key)
 			if err != nil {
 				log.Printf("Failed to delete Unreferenced S3 file %s: %v", key, err)
 			} else {
 				log.Printf("Deleted Unreferenced S3 file: %s", key)
 				deletedCount++
+				if err := w.store.DeleteFileScan(ctx, key); err != nil {
+					log.Printf("Failed to delete the scan of %s: %v", key, err)
+				}
 			}
 		}
 	}
@@ -129,11 +127,8 @@ func (w *Worker) removeMissingS3FileRecords(ctx context.Context) {
 		}
 
 		for _, filePath := range paths {
-			_, err := w.s3.HeadObject(ctx, &s3.HeadObjectInput{
-				Bucket: aws.String("solveit"),
-				Key:    aws.String(filePath),
-			})
-			if err != nil {
+			_, err := w.storage.Head(ctx, filePath)
+			if errors.Is(err, storage.ErrNotFound) {
 				log.Printf("File missing in S3, deleting DB record: %s", filePath)
 				err = table.delete(ctx, filePath)
 				if err != nil {
@@ -142,6 +137,9 @@ func (w *Worker) removeMissingS3FileRecords(ctx context.Context) {
 					deleteCount++
 				}
 
+			} else if err != nil {
+				// an unreachable store is no reason to forget the file
+				log.Printf("Failed to check %s in storage: %v", filePath, err)
 			}
 		}
 		log.Printf("totoal found %d deleted %d", len(paths), deleteCount)
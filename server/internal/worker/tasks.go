@@ -9,8 +9,9 @@ import (
 	"sync"
 	"time"
 
-	"github/abdallemo/solveit-saas/internal/api/websocket"
 	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/mailer"
+	"github/abdallemo/solveit-saas/internal/notification"
 	"github/abdallemo/solveit-saas/internal/utils"
 
 	"github.com/google/uuid"
@@ -18,13 +19,17 @@ import (
 
 var re = regexp.MustCompile(`^(\d+)([hdwmy])$`)
 
+// deadlineWarning is how long before the deadline a solver is reminded, a
+// quarter of the time given for shorter tasks
+const deadlineWarning = 24 * time.Hour
+
 // StartDeadlineEnforcerJob initializes the ticker and spawns the worker with a lock mechanism
 func (w *Worker) StartDeadlineEnforcerJob(ctx context.Context, concurrency int, timeBetweenChecks time.Duration) {
 	ticker := time.NewTicker(timeBetweenChecks)
 	log.Println("Starting Background Job for task deadline enforcement")
 	defer ticker.Stop()
 
-	go w.runDeadlineCheck(ctx, concurrency)
+	w.runDeadlineCheck(context.WithoutCancel(ctx), concurrency)
 
 	for {
 		select {
@@ -32,7 +37,7 @@ func (w *Worker) StartDeadlineEnforcerJob(ctx context.Context, concurrency int,
 			log.Println("Task deadline enforcement shutting down...")
 			return
 		case <-ticker.C:
-			go w.runDeadlineCheck(ctx, concurrency)
+			w.runDeadlineCheck(context.WithoutCancel(ctx), concurrency)
 		}
 	}
 }
@@ -79,6 +84,7 @@ func (w *Worker) checkAndEnforceDeadline(ctx context.Context, task database.Task
 	// log.Printf("Processing task %v check against %v", task.ID, nowUTC)
 
 	if nowUTC.Before(tmUTC) {
+		w.warnDeadline(ctx, task, nowUTC, tmUTC)
 		return
 	}
 
@@ -97,51 +103,74 @@ func (w *Worker) checkAndEnforceDeadline(ctx context.Context, task database.Task
 	if blockedSolver.ID != uuid.Nil {
 		log.Printf("Blocked user %v from task %v", blockedSolver.UserID, blockedSolver.TaskID)
 
-		notif, err := w.notifySolverAndResetTaskTx(ctx, *task.SolverID, task.Title, task.ID)
-		if err != nil {
-			log.Printf("Error notifying solver/resetting task: %v", err)
+		if err := w.store.ResetTaskInfo(ctx, task.ID); err != nil {
+			log.Printf("Error resetting task %v: %v", task.ID, err)
 			return
 		}
 
-		w.wsNotif.SendToUser(task.SolverID.String(), websocket.Message{
-			ID:         notif.ID.String(),
-			Content:    notif.Content,
-			ReceiverID: notif.ReceiverID,
-			SenderID:   notif.SenderID,
-			Subject:    *notif.Subject,
-			Method:     string(notif.Method),
-			Read:       notif.Read,
-			CreatedAt:  notif.CreatedAt.String(),
+		_, err := w.notifications.Deliver(ctx, notification.Delivery{
+			ReceiverID: *task.SolverID,
+			EventType:  notification.EventTaskBlocked,
+			Subject:    "Blocked From A Task",
+			Content:    fmt.Sprintf("You are blocked from task: %v. You can no longer submit it but you can still access your previous work.", task.Title),
+			Email: &mailer.Email{
+				Kind: mailer.KindBlockedFromTask,
+				Data: map[string]any{"TaskTitle": task.Title, "TaskID": task.ID.String()},
+			},
 		})
+		if err != nil {
+			log.Printf("Error notifying solver of task %v: %v", task.ID, err)
+		}
+
+		if _, err := w.taskFeed.PublishReopened(ctx, task.ID); err != nil {
+			log.Printf("Error publishing reopened task %v to the feed: %v", task.ID, err)
+		}
 	}
 }
 
-func (w *Worker) notifySolverAndResetTaskTx(ctx context.Context, SolverID uuid.UUID, Title string, TaskID uuid.UUID) (database.Notification, error) {
-	tx, err := w.dbConn.Begin(ctx)
-	if err != nil {
-		return database.Notification{}, err
+// warnDeadline notifies the solver once when the deadline of the task is
+// getting close.
+func (w *Worker) warnDeadline(ctx context.Context, task database.Task, nowUTC, tmUTC time.Time) {
+	remaining := tmUTC.Sub(nowUTC)
+	if remaining > min(deadlineWarning, tmUTC.Sub(*task.AssignedAt)/4) {
+		return
 	}
-	defer tx.Rollback(ctx)
 
-	qtx := w.store.WithTx(tx)
-
-	notif, err := qtx.ProcessSystemNotification(ctx, database.ProcessSystemNotificationParams{
-		SenderID:   "solveit@org.com",
-		ReceiverID: SolverID.String(),
-		Subject:    utils.ToStringPtr("Blocked From A Task"),
-		Content:    fmt.Sprintf("You are blocked from task: %v. You can no longer submit it but you can still access your previous work.", Title),
-		Read:       false,
-	})
+	key := fmt.Sprintf("deadline:warned:%s:%s", task.ID, task.SolverID)
+	fresh, err := w.redis.SetNX(ctx, key, 1, remaining+time.Hour).Result()
 	if err != nil {
-		return database.Notification{}, fmt.Errorf("failed to save notification: %w", err)
+		log.Printf("Error marking deadline warning of task %v: %v", task.ID, err)
+		return
+	}
+	if !fresh {
+		return
 	}
 
-	err = w.store.ResetTaskInfo(ctx, TaskID)
+	_, err = w.notifications.Deliver(ctx, notification.Delivery{
+		ReceiverID: *task.SolverID,
+		EventType:  notification.EventDeadlineWarning,
+		Subject:    "Deadline Approaching",
+		Content:    fmt.Sprintf("Task %v is due in %v. Submit before the deadline to avoid being blocked from it.", task.Title, formatRemaining(remaining)),
+		Email: &mailer.Email{
+			Kind: mailer.KindDeadlineWarning,
+			Data: map[string]any{
+				"TaskTitle": task.Title,
+				"TaskID":    task.ID.String(),
+				"Deadline":  tmUTC.Format("Jan 2, 2006 15:04 MST"),
+				"Remaining": formatRemaining(remaining),
+			},
+		},
+	})
 	if err != nil {
-		return database.Notification{}, fmt.Errorf("failed to reset task info: %w", err)
+		log.Printf("Error warning solver of task %v: %v", task.ID, err)
 	}
+}
 
-	return notif, tx.Commit(ctx)
+func formatRemaining(d time.Duration) string {
+	if d >= 2*time.Hour {
+		return fmt.Sprintf("%d hours", int(d.Round(time.Hour).Hours()))
+	}
+	return fmt.Sprintf("%d minutes", max(1, int(d.Round(time.Minute).Minutes())))
 }
 
 func parseDuration(lowerValue string, assignedAt *time.Time) (int, string, time.Time, error) {
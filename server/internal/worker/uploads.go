@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	expiredUploadsBatch = 500
+	// finished uploads are only kept to answer the clients asking again
+	finishedUploadsRetention = 7 * 24 * time.Hour
+)
+
+func (w *Worker) StartUploadCleanupJob(ctx context.Context, timeBetweenChecks time.Duration) {
+	ticker := time.NewTicker(timeBetweenChecks)
+	defer ticker.Stop()
+	log.Println("Starting Background Job for expired uploads cleanup")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Uploads cleanup shutting down...")
+			return
+		case <-ticker.C:
+			w.cleanupUploadIntents(context.WithoutCancel(ctx))
+			w.cleanupTusUploads(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+// cleanupUploadIntents deletes the objects of the intents that were never
+// confirmed, whether or not the client uploaded them
+func (w *Worker) cleanupUploadIntents(ctx context.Context) {
+	expired, err := w.store.ListExpiredUploadIntents(ctx, expiredUploadsBatch)
+	if err != nil {
+		log.Printf("Failed to fetch expired upload intents: %v", err)
+		return
+	}
+
+	deleted := 0
+	for _, intent := range expired {
+		if err := w.storage.Delete(ctx, intent.FilePath); err != nil {
+			log.Printf("Failed to delete expired upload %s: %v", intent.FilePath, err)
+			continue
+		}
+		if err := w.store.DeleteUploadIntent(ctx, intent.ID); err != nil {
+			log.Printf("Failed to delete upload intent %s: %v", intent.ID, err)
+			continue
+		}
+		deleted++
+	}
+
+	before := time.Now().Add(-finishedUploadsRetention)
+	pruned, err := w.store.PruneConfirmedUploadIntents(ctx, &before)
+	if err != nil {
+		log.Printf("Failed to prune confirmed upload intents: %v", err)
+	}
+	if deleted > 0 || pruned > 0 {
+		log.Printf("Upload intents cleanup: %d expired deleted, %d confirmed pruned", deleted, pruned)
+	}
+}
+
+// cleanupTusUploads gives up the resumable uploads left idle, with the
+// parts, tail and file they stored
+func (w *Worker) cleanupTusUploads(ctx context.Context) {
+	expired, err := w.store.ListExpiredTusUploads(ctx, expiredUploadsBatch)
+	if err != nil {
+		log.Printf("Failed to fetch expired tus uploads: %v", err)
+		return
+	}
+
+	deleted := 0
+	for _, upload := range expired {
+		if err := w.storage.AbortMultipart(ctx, upload.FilePath, upload.MultipartID); err != nil {
+			log.Printf("Failed to abort multipart upload of %s: %v", upload.FilePath, err)
+			continue
+		}
+		if err := w.storage.Delete(ctx, upload.FilePath+".part"); err != nil {
+			log.Printf("Failed to delete the tail of %s: %v", upload.FilePath, err)
+			continue
+		}
+		if err := w.storage.Delete(ctx, upload.FilePath); err != nil {
+			log.Printf("Failed to delete expired upload %s: %v", upload.FilePath, err)
+			continue
+		}
+		if err := w.store.DeleteTusUpload(ctx, upload.ID); err != nil {
+			log.Printf("Failed to delete tus upload %s: %v", upload.ID, err)
+			continue
+		}
+		deleted++
+	}
+
+	before := time.Now().Add(-finishedUploadsRetention)
+	pruned, err := w.store.PruneCompletedTusUploads(ctx, &before)
+	if err != nil {
+		log.Printf("Failed to prune completed tus uploads: %v", err)
+	}
+	if deleted > 0 || pruned > 0 {
+		log.Printf("Tus uploads cleanup: %d expired deleted, %d completed pruned", deleted, pruned)
+	}
+}
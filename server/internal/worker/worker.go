@@ -1,26 +1,30 @@
-// Package worker defines all background job and cron jobs for the server
+// Package worker defines all background job and cron jobs for the server.
+// Jobs run until their context is done, a run in progress is detached from
+// it so that a shutdown lets the run finish before the job returns.
 package worker
 
 import (
 	"sync"
 
-	"github/abdallemo/solveit-saas/internal/api/websocket"
 	"github/abdallemo/solveit-saas/internal/database"
+	"github/abdallemo/solveit-saas/internal/notification"
+	"github/abdallemo/solveit-saas/internal/storage"
+	"github/abdallemo/solveit-saas/internal/taskfeed"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Worker struct {
-	store   *database.Queries
-	s3      *s3.Client
-	redis   *redis.Client
-	wsNotif *websocket.WsNotification
-	dbConn  *pgxpool.Pool
-	mu      sync.RWMutex
+	store         *database.Queries
+	storage       storage.Storage
+	redis         *redis.Client
+	notifications *notification.Service
+	taskFeed      *taskfeed.Service
+	dbConn        *pgxpool.Pool
+	mu            sync.RWMutex
 }
 
-func NewWorker(store *database.Queries, s3 *s3.Client, redis *redis.Client, wsNotif *websocket.WsNotification, dbConn *pgxpool.Pool) *Worker {
-	return &Worker{store: store, s3: s3, redis: redis, wsNotif: wsNotif, dbConn: dbConn}
+func NewWorker(store *database.Queries, storage storage.Storage, redis *redis.Client, notifications *notification.Service, taskFeed *taskfeed.Service, dbConn *pgxpool.Pool) *Worker {
+	return &Worker{store: store, storage: storage, redis: redis, notifications: notifications, taskFeed: taskFeed, dbConn: dbConn}
 }
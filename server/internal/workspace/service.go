@@ -10,6 +10,12 @@ import (
 	"github/abdallemo/solveit-saas/internal/file" // your file service package
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNotFound  = errors.New("workspace not found")
+	ErrForbidden = errors.New("only the solver can upload to the workspace")
 )
 
 type Service struct {
@@ -24,26 +30,46 @@ func NewService(store *database.Queries, fs *file.Service) *Service {
 func (s *Service) CreateFiles(ctx context.Context, workspaceID, userID uuid.UUID, files []*multipart.FileHeader) ([]file.FileMeta, []file.FailedFileError, error) {
 
 	uploadID := uuid.New()
-	uploaded, failed := s.fileService.ProcessBatchUpload(files, "workspace", uploadID)
-
-	if len(uploaded) > 0 {
-		batch := file.NewFileBatch(uploaded)
-		err := s.store.CreateWorkspaceFiles(ctx, database.CreateWorkspaceFilesParams{
-			WorkspaceID:  workspaceID,
-			UploadedByID: userID,
-			FileName:     batch.Names,
-			FileType:     batch.Types,
-			FileSize:     batch.Sizes,
-			FilePath:     batch.Paths,
-		})
-		if err != nil {
-			return nil, nil, err
-		}
+	uploaded, failed := s.fileService.ProcessBatchUpload(files, "workspace", uploadID, userID)
+
+	if err := s.AddFiles(ctx, workspaceID, userID, uploaded); err != nil {
+		return nil, nil, err
 	}
 
 	return uploaded, failed, nil
 }
 
+// AddFiles records stored files in the workspace
+func (s *Service) AddFiles(ctx context.Context, workspaceID, userID uuid.UUID, files []file.FileMeta) error {
+	if len(files) == 0 {
+		return nil
+	}
+	batch := file.NewFileBatch(files)
+	return s.store.CreateWorkspaceFiles(ctx, database.CreateWorkspaceFilesParams{
+		WorkspaceID:  workspaceID,
+		UploadedByID: userID,
+		FileName:     batch.Names,
+		FileType:     batch.Types,
+		FileSize:     batch.Sizes,
+		FilePath:     batch.Paths,
+	})
+}
+
+// CanUpload checks that the user is the solver working in the workspace
+func (s *Service) CanUpload(ctx context.Context, workspaceID, userID uuid.UUID) error {
+	solverID, err := s.store.GetWorkspaceSolver(ctx, workspaceID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if solverID != userID {
+		return ErrForbidden
+	}
+	return nil
+}
+
 func (s *Service) DeleteWorkspaceFiles(ctx context.Context, filePath string, workspaceId uuid.UUID) error {
 
 	err := s.store.DeleteWorkspaceFile(ctx, database.DeleteWorkspaceFileParams{
@@ -0,0 +1,18 @@
+CREATE TYPE "public"."call_status" AS ENUM('RINGING', 'ACTIVE', 'REJECTED', 'MISSED', 'CANCELED', 'ENDED');--> statement-breakpoint
+CREATE TABLE "mentor_session_calls" (
+	"id" uuid PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+	"session_id" uuid NOT NULL,
+	"caller_id" uuid NOT NULL,
+	"callee_id" uuid NOT NULL,
+	"status" "call_status" DEFAULT 'RINGING' NOT NULL,
+	"started_at" timestamp with time zone DEFAULT now() NOT NULL,
+	"answered_at" timestamp with time zone,
+	"ended_at" timestamp with time zone,
+	"duration_seconds" integer DEFAULT 0 NOT NULL
+);
+--> statement-breakpoint
+ALTER TABLE "mentor_session_calls" ADD CONSTRAINT "mentor_session_calls_session_id_mentor_session_id_fk" FOREIGN KEY ("session_id") REFERENCES "public"."mentor_session"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "mentor_session_calls" ADD CONSTRAINT "mentor_session_calls_caller_id_users_id_fk" FOREIGN KEY ("caller_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "mentor_session_calls" ADD CONSTRAINT "mentor_session_calls_callee_id_users_id_fk" FOREIGN KEY ("callee_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+CREATE INDEX "mentor_session_calls_sessionId_idx" ON "mentor_session_calls" USING btree ("session_id");--> statement-breakpoint
+CREATE UNIQUE INDEX "mentor_session_calls_live_idx" ON "mentor_session_calls" USING btree ("session_id") WHERE "mentor_session_calls"."status" in ('RINGING', 'ACTIVE');
\ No newline at end of file
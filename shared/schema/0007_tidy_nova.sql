@@ -0,0 +1,25 @@
+CREATE TYPE "public"."digest_frequency" AS ENUM('HOURLY', 'DAILY', 'NEVER');--> statement-breakpoint
+CREATE TYPE "public"."notification_channel" AS ENUM('IN_APP', 'EMAIL', 'DIGEST', 'MUTED');--> statement-breakpoint
+CREATE TABLE "notification_preferences" (
+	"id" uuid PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+	"user_id" uuid NOT NULL,
+	"event_type" text NOT NULL,
+	"channel" "notification_channel" NOT NULL,
+	"updated_at" timestamp with time zone DEFAULT now() NOT NULL
+);
+--> statement-breakpoint
+CREATE TABLE "notification_settings" (
+	"user_id" uuid PRIMARY KEY NOT NULL,
+	"digest_frequency" "digest_frequency" DEFAULT 'DAILY' NOT NULL,
+	"timezone" text DEFAULT 'UTC' NOT NULL,
+	"quiet_hours_start" integer,
+	"quiet_hours_end" integer,
+	"last_digest_at" timestamp with time zone,
+	"updated_at" timestamp with time zone DEFAULT now() NOT NULL
+);
+--> statement-breakpoint
+ALTER TABLE "notifications" ADD COLUMN "event_type" text DEFAULT 'general' NOT NULL;--> statement-breakpoint
+ALTER TABLE "notifications" ADD COLUMN "digested_at" timestamp with time zone;--> statement-breakpoint
+ALTER TABLE "notification_preferences" ADD CONSTRAINT "notification_preferences_user_id_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "notification_settings" ADD CONSTRAINT "notification_settings_user_id_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+CREATE UNIQUE INDEX "notification_preferences_user_event_idx" ON "notification_preferences" USING btree ("user_id","event_type");
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE "task_feed_subscriptions" (
+	"user_id" uuid PRIMARY KEY NOT NULL,
+	"category_ids" uuid[] DEFAULT '{}' NOT NULL,
+	"created_at" timestamp with time zone DEFAULT now() NOT NULL,
+	"updated_at" timestamp with time zone DEFAULT now() NOT NULL
+);
+--> statement-breakpoint
+ALTER TABLE "task_feed_subscriptions" ADD CONSTRAINT "task_feed_subscriptions_user_id_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;
\ No newline at end of file
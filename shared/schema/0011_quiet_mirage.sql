@@ -0,0 +1,28 @@
+CREATE TABLE "mentorship_chat_edits" (
+	"id" uuid PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+	"chat_id" uuid NOT NULL,
+	"previous_message" text,
+	"edited_at" timestamp with time zone DEFAULT now() NOT NULL
+);
+--> statement-breakpoint
+CREATE TABLE "mentorship_chat_reactions" (
+	"id" uuid PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+	"chat_id" uuid NOT NULL,
+	"user_id" uuid NOT NULL,
+	"emoji" text NOT NULL,
+	"created_at" timestamp with time zone DEFAULT now() NOT NULL
+);
+--> statement-breakpoint
+CREATE TABLE "mentorship_chat_replies" (
+	"chat_id" uuid PRIMARY KEY NOT NULL,
+	"reply_to_id" uuid NOT NULL
+);
+--> statement-breakpoint
+ALTER TABLE "mentorship_chat_edits" ADD CONSTRAINT "mentorship_chat_edits_chat_id_mentorship_chats_id_fk" FOREIGN KEY ("chat_id") REFERENCES "public"."mentorship_chats"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "mentorship_chat_reactions" ADD CONSTRAINT "mentorship_chat_reactions_chat_id_mentorship_chats_id_fk" FOREIGN KEY ("chat_id") REFERENCES "public"."mentorship_chats"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "mentorship_chat_reactions" ADD CONSTRAINT "mentorship_chat_reactions_user_id_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "mentorship_chat_replies" ADD CONSTRAINT "mentorship_chat_replies_chat_id_mentorship_chats_id_fk" FOREIGN KEY ("chat_id") REFERENCES "public"."mentorship_chats"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "mentorship_chat_replies" ADD CONSTRAINT "mentorship_chat_replies_reply_to_id_mentorship_chats_id_fk" FOREIGN KEY ("reply_to_id") REFERENCES "public"."mentorship_chats"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+CREATE INDEX "mentorship_chat_edits_chatId_idx" ON "mentorship_chat_edits" USING btree ("chat_id");--> statement-breakpoint
+CREATE UNIQUE INDEX "mentorship_chat_reactions_unique_idx" ON "mentorship_chat_reactions" USING btree ("chat_id","user_id","emoji");--> statement-breakpoint
+CREATE INDEX "mentorship_chat_replies_replyToId_idx" ON "mentorship_chat_replies" USING btree ("reply_to_id");
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE INDEX "mentorship_chats_session_created_idx" ON "mentorship_chats" USING btree ("seesion_id","created_at","id");--> statement-breakpoint
+CREATE INDEX "mentorship_chats_message_search_idx" ON "mentorship_chats" USING gin (to_tsvector('simple', coalesce("message", '')));
\ No newline at end of file
@@ -0,0 +1,19 @@
+CREATE TYPE "public"."upload_scope" AS ENUM('TASK_DRAFT', 'WORKSPACE', 'CHAT', 'EDITOR');--> statement-breakpoint
+CREATE TABLE "upload_intents" (
+	"id" uuid PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+	"user_id" uuid NOT NULL,
+	"scope" "upload_scope" NOT NULL,
+	"target_id" uuid,
+	"file_name" text NOT NULL,
+	"file_type" text NOT NULL,
+	"file_size" integer NOT NULL,
+	"file_path" text NOT NULL,
+	"expires_at" timestamp with time zone NOT NULL,
+	"confirmed_at" timestamp with time zone,
+	"created_at" timestamp with time zone DEFAULT now() NOT NULL,
+	CONSTRAINT "upload_intents_file_path_unique" UNIQUE("file_path")
+);
+--> statement-breakpoint
+ALTER TABLE "upload_intents" ADD CONSTRAINT "upload_intents_user_id_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+CREATE INDEX "upload_intents_userId_idx" ON "upload_intents" USING btree ("user_id");--> statement-breakpoint
+CREATE INDEX "upload_intents_expires_at_idx" ON "upload_intents" USING btree ("expires_at");
\ No newline at end of file
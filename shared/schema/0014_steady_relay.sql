@@ -0,0 +1,22 @@
+CREATE TABLE "tus_uploads" (
+	"id" uuid PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+	"user_id" uuid NOT NULL,
+	"scope" "upload_scope" NOT NULL,
+	"target_id" uuid,
+	"file_name" text NOT NULL,
+	"file_type" text NOT NULL,
+	"file_size" integer NOT NULL,
+	"file_path" text NOT NULL,
+	"multipart_id" text NOT NULL,
+	"upload_offset" integer DEFAULT 0 NOT NULL,
+	"parts" jsonb DEFAULT '[]' NOT NULL,
+	"tail_size" integer DEFAULT 0 NOT NULL,
+	"expires_at" timestamp with time zone NOT NULL,
+	"completed_at" timestamp with time zone,
+	"created_at" timestamp with time zone DEFAULT now() NOT NULL,
+	CONSTRAINT "tus_uploads_file_path_unique" UNIQUE("file_path")
+);
+--> statement-breakpoint
+ALTER TABLE "tus_uploads" ADD CONSTRAINT "tus_uploads_user_id_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+CREATE INDEX "tus_uploads_userId_idx" ON "tus_uploads" USING btree ("user_id");--> statement-breakpoint
+CREATE INDEX "tus_uploads_expires_at_idx" ON "tus_uploads" USING btree ("expires_at");
\ No newline at end of file
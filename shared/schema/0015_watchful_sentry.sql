@@ -0,0 +1,23 @@
+CREATE TABLE "file_scans" (
+	"file_path" text PRIMARY KEY NOT NULL,
+	"user_id" uuid,
+	"status" "file_status" DEFAULT 'PENDING' NOT NULL,
+	"signature" text,
+	"attempts" integer DEFAULT 0 NOT NULL,
+	"last_error" text,
+	"quarantine_path" text,
+	"scanned_at" timestamp with time zone,
+	"created_at" timestamp with time zone DEFAULT now() NOT NULL,
+	"updated_at" timestamp with time zone DEFAULT now() NOT NULL
+);
+--> statement-breakpoint
+ALTER TABLE "file_scans" ADD CONSTRAINT "file_scans_user_id_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE set null ON UPDATE no action;--> statement-breakpoint
+CREATE INDEX "file_scans_status_idx" ON "file_scans" USING btree ("status","updated_at");--> statement-breakpoint
+INSERT INTO "file_scans" ("file_path")
+SELECT "file_path" FROM "task_files"
+UNION SELECT "file_path" FROM "solution_workspace_files"
+UNION SELECT "file_path" FROM "mentorship_chat_files"
+UNION SELECT "file_path" FROM "editor_files"
+UNION SELECT f->>'filePath' FROM "task_drafts", jsonb_array_elements("task_drafts"."uploadedFiles") f WHERE f->>'filePath' IS NOT NULL
+ON CONFLICT DO NOTHING;--> statement-breakpoint
+UPDATE "solution_workspace_files" SET "status" = 'PENDING';
\ No newline at end of file
@@ -0,0 +1,92 @@
+CREATE VIEW "public"."mentorship_chat_details" AS (
+    SELECT
+      c.id,
+      c.created_at,
+      c.seesion_id,
+      c.message,
+      c.sent_by,
+      c.sent_to,
+      c.read_at,
+      c.pending,
+      c.is_deleted,
+
+      (
+        json_build_object(
+          'id', u.id,
+          'name', u.name,
+          'role', u.role,
+          'image', u.image,
+          'email', u.email
+        )
+      )::jsonb AS chat_owner,
+
+      COALESCE(
+        (
+          SELECT jsonb_agg(
+            jsonb_build_object(
+              'id', f.id,
+              'fileName', f.file_name,
+              'fileType', f.file_type,
+              'fileSize', f.file_size,
+              'filePath', f.file_path,
+              'uploadedAt', f.uploaded_at,
+              'uploadedById', f.uploaded_by_id,
+              'chatId', f.chat_id
+            )
+          )
+          FROM mentorship_chat_files f
+          WHERE f.chat_id = c.id
+        ),
+        '[]'::jsonb
+      )::jsonb AS chat_files,
+
+      COALESCE(
+        (
+          SELECT jsonb_agg(
+            jsonb_build_object(
+              'previousMessage', e.previous_message,
+              'editedAt', e.edited_at
+            )
+            ORDER BY e.edited_at
+          )
+          FROM mentorship_chat_edits e
+          WHERE e.chat_id = c.id
+        ),
+        '[]'::jsonb
+      )::jsonb AS chat_edits,
+
+      COALESCE(
+        (
+          SELECT jsonb_agg(
+            jsonb_build_object(
+              'emoji', r.emoji,
+              'count', r.count,
+              'userIds', r.user_ids
+            )
+            ORDER BY r.first_at
+          )
+          FROM (
+            SELECT emoji, COUNT(*) AS count, array_agg(user_id ORDER BY created_at) AS user_ids, MIN(created_at) AS first_at
+            FROM mentorship_chat_reactions
+            WHERE chat_id = c.id
+            GROUP BY emoji
+          ) r
+        ),
+        '[]'::jsonb
+      )::jsonb AS chat_reactions,
+
+      (
+        SELECT jsonb_build_object(
+          'id', p.id,
+          'message', CASE WHEN COALESCE(p.is_deleted, false) THEN NULL ELSE p.message END,
+          'sentBy', p.sent_by,
+          'isDeleted', COALESCE(p.is_deleted, false)
+        )
+        FROM mentorship_chat_replies rp
+        JOIN mentorship_chats p ON p.id = rp.reply_to_id
+        WHERE rp.chat_id = c.id
+      )::jsonb AS reply_to
+
+    FROM mentorship_chats c
+    JOIN users u ON u.id = c.sent_by
+  );
\ No newline at end of file
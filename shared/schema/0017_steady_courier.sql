@@ -0,0 +1,2 @@
+ALTER TABLE "notifications" ADD COLUMN "dispatched_at" timestamp with time zone;--> statement-breakpoint
+UPDATE "notifications" SET "dispatched_at" = "created_at";
\ No newline at end of file
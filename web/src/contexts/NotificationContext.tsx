@@ -9,12 +9,15 @@ import {
   ReactNode,
   SetStateAction,
   useContext,
+  useRef,
   useState,
 } from "react";
+import { toast } from "sonner";
 
 type NotificationContextType = {
   setMessages: Dispatch<SetStateAction<Message[]>>;
   messages: Message[];
+  announcements: Announcement[];
 };
 
 const NotificationContext = createContext<NotificationContextType | undefined>(
@@ -36,6 +39,16 @@ export type Message = {
   method: "SYSTEM" | "EMAIL";
   read: boolean;
 };
+
+export type Announcement = {
+  messageType: "announcement" | "announcement_expired";
+  id: string;
+  title: string;
+  content: string;
+  role?: string;
+  expiresAt: string;
+  createdAt: string;
+};
 export const NotificationProvider = ({
   children,
   initailAllNotifications,
@@ -44,6 +57,8 @@ export const NotificationProvider = ({
   const [messages, setMessages] = useState<Message[]>(
     (initailAllNotifications ?? []).slice(0, 3),
   );
+  const [announcements, setAnnouncements] = useState<Announcement[]>([]);
+  const shownAnnouncements = useRef(new Set<string>());
 
   useWebSocket<Message>(
     `${env.NEXT_PUBLIC_GO_API_WS_URL}/notification?user_id=${user.id}`,
@@ -59,11 +74,35 @@ export const NotificationProvider = ({
     },
   );
 
+  // the active announcements of the user's role come first on every
+  // connect, so one already shown is told apart by its id
+  useWebSocket<Announcement>(`${env.NEXT_PUBLIC_GO_API_WS_URL}/announce`, {
+    onMessage: (msg) => {
+      if (msg.messageType === "announcement_expired") {
+        shownAnnouncements.current.delete(msg.id);
+        toast.dismiss(msg.id);
+        setAnnouncements((prev) => prev.filter((a) => a.id !== msg.id));
+        return;
+      }
+      if (shownAnnouncements.current.has(msg.id)) {
+        return;
+      }
+      shownAnnouncements.current.add(msg.id);
+      toast.info(msg.title, {
+        id: msg.id,
+        description: msg.content,
+        duration: Infinity,
+      });
+      setAnnouncements((prev) => [msg, ...prev]);
+    },
+  });
+
   return (
     <NotificationContext.Provider
       value={{
         messages,
         setMessages,
+        announcements,
       }}
     >
       {children}
@@ -8,9 +8,11 @@ import {
   Dispatch,
   ReactNode,
   SetStateAction,
+  useCallback,
   useContext,
   useState,
 } from "react";
+import { toast } from "sonner";
 
 
 export type commentType = {
@@ -22,10 +24,23 @@ export type commentType = {
   owner: publicUserType;
 };
 
+// what the comments socket pushes, the go api stores and moderates what is
+// sent to it and broadcasts the result to everyone on the task
+type CommentEvent =
+  | (commentType & {
+      messageType: "comment_created" | "comment_updated" | "comment_deleted";
+    })
+  | {
+      messageType: "comment_rejected" | "comment_failed";
+      taskId: string;
+      reason: string;
+    };
+
 type CommentContextType = {
   comments: commentType[];
   setComments: Dispatch<SetStateAction<commentType[]>>;
-  send: (data: commentType) => void;
+  sendComment: (content: string) => void;
+  isSending: boolean;
 };
 
 const CommentContext = createContext<CommentContextType | undefined>(undefined);
@@ -50,24 +65,63 @@ export const CommentProvider = ({
       return dateA - dateB;
     })
   );
+  const [isSending, setIsSending] = useState(false);
 
-  const { send } = useWebSocket<commentType>(
+  const { send, connectionState } = useWebSocket<
+    CommentEvent | { content: string }
+  >(
     `${env.NEXT_PUBLIC_GO_API_WS_URL}/comments?task_id=${taskId}`,
     {
-      onMessage: (comment) => {
-        if (comment.userId !== userId) {
-          setComments((prev) => [...prev, comment]);
+      onMessage: (msg) => {
+        if (!("messageType" in msg)) return;
+        switch (msg.messageType) {
+          case "comment_created": {
+            const { messageType: _event, ...comment } = msg;
+            if (comment.userId === userId) setIsSending(false);
+            setComments((prev) =>
+              prev.some((c) => c.id === comment.id) ? prev : [...prev, comment]
+            );
+            return;
+          }
+          case "comment_updated": {
+            const { messageType: _event, ...comment } = msg;
+            setComments((prev) =>
+              prev.map((c) => (c.id === comment.id ? comment : c))
+            );
+            return;
+          }
+          case "comment_deleted":
+            setComments((prev) => prev.filter((c) => c.id !== msg.id));
+            return;
+          case "comment_rejected":
+          case "comment_failed":
+            setIsSending(false);
+            toast.error(msg.reason);
+            return;
         }
       },
     }
   );
 
+  const sendComment = useCallback(
+    (content: string) => {
+      if (connectionState !== "connected") {
+        toast.error("Comments are reconnecting, try again in a moment");
+        return;
+      }
+      setIsSending(true);
+      send({ content });
+    },
+    [send, connectionState]
+  );
+
   return (
     <CommentContext.Provider
       value={{
         comments,
         setComments,
-        send,
+        sendComment,
+        isSending,
       }}>
       {children}
     </CommentContext.Provider>
@@ -43,7 +43,8 @@ type WorkspaceContextType = {
   >;
   comments: commentType[];
   setComments: Dispatch<SetStateAction<commentType[]>>;
-  send: (data: commentType) => void;
+  sendComment: (content: string) => void;
+  isSending: boolean;
 };
 
 const WorkspaceContext = createContext<WorkspaceContextType | undefined>(
@@ -20,6 +20,7 @@ import {
   pgEnum,
   pgSequence,
   pgTable,
+  pgView,
   real,
   serial,
   text,
@@ -92,6 +93,33 @@ export const BookingSatatusEnum = pgEnum("booking_status", [
   "PAID",
   "CANCELED",
 ]);
+export const CallStatusEnum = pgEnum("call_status", [
+  "RINGING",
+  "ACTIVE",
+  "REJECTED",
+  "MISSED",
+  "CANCELED",
+  "ENDED",
+]);
+
+export const NotificationChannelEnum = pgEnum("notification_channel", [
+  "IN_APP",
+  "EMAIL",
+  "DIGEST",
+  "MUTED",
+]);
+export const DigestFrequencyEnum = pgEnum("digest_frequency", [
+  "HOURLY",
+  "DAILY",
+  "NEVER",
+]);
+
+export const UploadScopeEnum = pgEnum("upload_scope", [
+  "TASK_DRAFT",
+  "WORKSPACE",
+  "CHAT",
+  "EDITOR",
+]);
 
 export const FeedbackCategory = pgEnum("feedback_category", [
   "TASK",
@@ -835,6 +863,15 @@ export const MentorshipChatTable = pgTable(
   },
   (mentorshipChats) => [
     index("mentorship_chats_sessionId_idx").on(mentorshipChats.sessionId),
+    index("mentorship_chats_session_created_idx").on(
+      mentorshipChats.sessionId,
+      mentorshipChats.createdAt,
+      mentorshipChats.id,
+    ),
+    index("mentorship_chats_message_search_idx").using(
+      "gin",
+      sql`to_tsvector('simple', coalesce(${mentorshipChats.message}, ''))`,
+    ),
   ],
 );
 
@@ -866,6 +903,210 @@ export const MentorshipChatFilesTable = pgTable(
   ],
 );
 
+export const MentorshipChatEditTable = pgTable(
+  "mentorship_chat_edits",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    chatId: uuid("chat_id")
+      .notNull()
+      .references(() => MentorshipChatTable.id, { onDelete: "cascade" }),
+    previousMessage: text("previous_message"),
+    editedAt: timestamp("edited_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+  },
+  (mentorshipChatEdits) => [
+    index("mentorship_chat_edits_chatId_idx").on(mentorshipChatEdits.chatId),
+  ],
+);
+
+export const MentorshipChatReactionTable = pgTable(
+  "mentorship_chat_reactions",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    chatId: uuid("chat_id")
+      .notNull()
+      .references(() => MentorshipChatTable.id, { onDelete: "cascade" }),
+    userId: uuid("user_id")
+      .notNull()
+      .references(() => UserTable.id, { onDelete: "cascade" }),
+    emoji: text("emoji").notNull(),
+    createdAt: timestamp("created_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+  },
+  (mentorshipChatReactions) => [
+    uniqueIndex("mentorship_chat_reactions_unique_idx").on(
+      mentorshipChatReactions.chatId,
+      mentorshipChatReactions.userId,
+      mentorshipChatReactions.emoji,
+    ),
+  ],
+);
+
+export const MentorshipChatReplyTable = pgTable(
+  "mentorship_chat_replies",
+  {
+    chatId: uuid("chat_id")
+      .primaryKey()
+      .references(() => MentorshipChatTable.id, { onDelete: "cascade" }),
+    replyToId: uuid("reply_to_id")
+      .notNull()
+      .references(() => MentorshipChatTable.id, { onDelete: "cascade" }),
+  },
+  (mentorshipChatReplies) => [
+    index("mentorship_chat_replies_replyToId_idx").on(
+      mentorshipChatReplies.replyToId,
+    ),
+  ],
+);
+
+// mentorship_chat_details is a chat message as the clients get it, with its
+// sender, files, edits, reactions and the message it replies to
+export const MentorshipChatDetailsView = pgView("mentorship_chat_details", {
+  id: uuid("id").notNull(),
+  createdAt: timestamp("created_at", { mode: "date", withTimezone: true }),
+  sessionId: uuid("seesion_id").notNull(),
+  message: text("message"),
+  sentBy: uuid("sent_by").notNull(),
+  sentTo: uuid("sent_to").notNull(),
+  readAt: timestamp("read_at", { mode: "date", withTimezone: true }),
+  pending: boolean("pending"),
+  isDeleted: boolean("is_deleted"),
+  chatOwner: jsonb("chat_owner"),
+  chatFiles: jsonb("chat_files"),
+  chatEdits: jsonb("chat_edits"),
+  chatReactions: jsonb("chat_reactions"),
+  replyTo: jsonb("reply_to"),
+}).as(
+  sql`
+    SELECT
+      c.id,
+      c.created_at,
+      c.seesion_id,
+      c.message,
+      c.sent_by,
+      c.sent_to,
+      c.read_at,
+      c.pending,
+      c.is_deleted,
+
+      (
+        json_build_object(
+          'id', u.id,
+          'name', u.name,
+          'role', u.role,
+          'image', u.image,
+          'email', u.email
+        )
+      )::jsonb AS chat_owner,
+
+      COALESCE(
+        (
+          SELECT jsonb_agg(
+            jsonb_build_object(
+              'id', f.id,
+              'fileName', f.file_name,
+              'fileType', f.file_type,
+              'fileSize', f.file_size,
+              'filePath', f.file_path,
+              'uploadedAt', f.uploaded_at,
+              'uploadedById', f.uploaded_by_id,
+              'chatId', f.chat_id
+            )
+          )
+          FROM mentorship_chat_files f
+          WHERE f.chat_id = c.id
+        ),
+        '[]'::jsonb
+      )::jsonb AS chat_files,
+
+      COALESCE(
+        (
+          SELECT jsonb_agg(
+            jsonb_build_object(
+              'previousMessage', e.previous_message,
+              'editedAt', e.edited_at
+            )
+            ORDER BY e.edited_at
+          )
+          FROM mentorship_chat_edits e
+          WHERE e.chat_id = c.id
+        ),
+        '[]'::jsonb
+      )::jsonb AS chat_edits,
+
+      COALESCE(
+        (
+          SELECT jsonb_agg(
+            jsonb_build_object(
+              'emoji', r.emoji,
+              'count', r.count,
+              'userIds', r.user_ids
+            )
+            ORDER BY r.first_at
+          )
+          FROM (
+            SELECT emoji, COUNT(*) AS count, array_agg(user_id ORDER BY created_at) AS user_ids, MIN(created_at) AS first_at
+            FROM mentorship_chat_reactions
+            WHERE chat_id = c.id
+            GROUP BY emoji
+          ) r
+        ),
+        '[]'::jsonb
+      )::jsonb AS chat_reactions,
+
+      (
+        SELECT jsonb_build_object(
+          'id', p.id,
+          'message', CASE WHEN COALESCE(p.is_deleted, false) THEN NULL ELSE p.message END,
+          'sentBy', p.sent_by,
+          'isDeleted', COALESCE(p.is_deleted, false)
+        )
+        FROM mentorship_chat_replies rp
+        JOIN mentorship_chats p ON p.id = rp.reply_to_id
+        WHERE rp.chat_id = c.id
+      )::jsonb AS reply_to
+
+    FROM mentorship_chats c
+    JOIN users u ON u.id = c.sent_by
+  `,
+);
+
+export const MentorshipCallTable = pgTable(
+  "mentor_session_calls",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    sessionId: uuid("session_id")
+      .notNull()
+      .references(() => MentorshipSessionTable.id, { onDelete: "cascade" }),
+    callerId: uuid("caller_id")
+      .notNull()
+      .references(() => UserTable.id, { onDelete: "cascade" }),
+    calleeId: uuid("callee_id")
+      .notNull()
+      .references(() => UserTable.id, { onDelete: "cascade" }),
+    status: CallStatusEnum().default("RINGING").notNull(),
+    startedAt: timestamp("started_at", {
+      mode: "date",
+      withTimezone: true,
+    })
+      .notNull()
+      .defaultNow(),
+    answeredAt: timestamp("answered_at", { mode: "date", withTimezone: true }),
+    endedAt: timestamp("ended_at", { mode: "date", withTimezone: true }),
+    durationSeconds: integer("duration_seconds").default(0).notNull(),
+  },
+  (mentorSessionCalls) => [
+    index("mentor_session_calls_sessionId_idx").on(
+      mentorSessionCalls.sessionId,
+    ),
+    uniqueIndex("mentor_session_calls_live_idx")
+      .on(mentorSessionCalls.sessionId)
+      .where(sql`${mentorSessionCalls.status} in ('RINGING', 'ACTIVE')`),
+  ],
+);
+
 export const RulesTable = pgTable("ai_rules", {
   id: uuid("id").primaryKey().defaultRandom(),
   rule: text("rule").notNull(),
@@ -939,12 +1180,208 @@ export const notifications = pgTable("notifications", {
   content: text("content").notNull(),
   method: NotificationMethodsEnum("method").notNull(),
   read: boolean("read").notNull().default(false),
+  eventType: text("event_type").notNull().default("general"),
+  digestedAt: timestamp("digested_at", { mode: "date", withTimezone: true }),
+  dispatchedAt: timestamp("dispatched_at", {
+    mode: "date",
+    withTimezone: true,
+  }),
   createdAt: timestamp("created_at", {
     mode: "date",
     withTimezone: true,
   }).defaultNow(),
 });
 
+export const NotificationPreferenceTable = pgTable(
+  "notification_preferences",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    userId: uuid("user_id")
+      .notNull()
+      .references(() => UserTable.id, { onDelete: "cascade" }),
+    eventType: text("event_type").notNull(),
+    channel: NotificationChannelEnum().notNull(),
+    updatedAt: timestamp("updated_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+  },
+  (preferences) => [
+    uniqueIndex("notification_preferences_user_event_idx").on(
+      preferences.userId,
+      preferences.eventType,
+    ),
+  ],
+);
+
+export const PushSubscriptionTable = pgTable(
+  "push_subscriptions",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    userId: uuid("user_id")
+      .notNull()
+      .references(() => UserTable.id, { onDelete: "cascade" }),
+    endpoint: text("endpoint").notNull().unique(),
+    p256dh: text("p256dh").notNull(),
+    auth: text("auth").notNull(),
+    userAgent: text("user_agent"),
+    createdAt: timestamp("created_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+    lastUsedAt: timestamp("last_used_at", {
+      mode: "date",
+      withTimezone: true,
+    }),
+  },
+  (subscriptions) => [
+    index("push_subscriptions_userId_idx").on(subscriptions.userId),
+  ],
+);
+
+export const AnnouncementTable = pgTable(
+  "announcements",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    senderId: uuid("sender_id").references(() => UserTable.id, {
+      onDelete: "set null",
+    }),
+    role: UserRole("role").$type<UserRoleType>(),
+    title: text("title").notNull(),
+    content: text("content").notNull(),
+    expiresAt: timestamp("expires_at", {
+      mode: "date",
+      withTimezone: true,
+    }).notNull(),
+    createdAt: timestamp("created_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+  },
+  (announcements) => [
+    index("announcements_expires_at_idx").on(announcements.expiresAt),
+  ],
+);
+
+export const UploadIntentTable = pgTable(
+  "upload_intents",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    userId: uuid("user_id")
+      .notNull()
+      .references(() => UserTable.id, { onDelete: "cascade" }),
+    scope: UploadScopeEnum("scope").notNull(),
+    targetId: uuid("target_id"),
+    fileName: text("file_name").notNull(),
+    fileType: text("file_type").notNull(),
+    fileSize: integer("file_size").notNull(),
+    filePath: text("file_path").notNull().unique(),
+    expiresAt: timestamp("expires_at", { mode: "date", withTimezone: true })
+      .notNull(),
+    confirmedAt: timestamp("confirmed_at", {
+      mode: "date",
+      withTimezone: true,
+    }),
+    createdAt: timestamp("created_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+  },
+  (uploadIntents) => [
+    index("upload_intents_userId_idx").on(uploadIntents.userId),
+    index("upload_intents_expires_at_idx").on(uploadIntents.expiresAt),
+  ],
+);
+
+export const TusUploadTable = pgTable(
+  "tus_uploads",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    userId: uuid("user_id")
+      .notNull()
+      .references(() => UserTable.id, { onDelete: "cascade" }),
+    scope: UploadScopeEnum("scope").notNull(),
+    targetId: uuid("target_id"),
+    fileName: text("file_name").notNull(),
+    fileType: text("file_type").notNull(),
+    fileSize: integer("file_size").notNull(),
+    filePath: text("file_path").notNull().unique(),
+    multipartId: text("multipart_id").notNull(),
+    uploadOffset: integer("upload_offset").default(0).notNull(),
+    parts: jsonb("parts")
+      .default("[]")
+      .notNull()
+      .$type<{ number: number; etag: string; size: number }[]>(),
+    tailSize: integer("tail_size").default(0).notNull(),
+    expiresAt: timestamp("expires_at", { mode: "date", withTimezone: true })
+      .notNull(),
+    completedAt: timestamp("completed_at", {
+      mode: "date",
+      withTimezone: true,
+    }),
+    createdAt: timestamp("created_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+  },
+  (tusUploads) => [
+    index("tus_uploads_userId_idx").on(tusUploads.userId),
+    index("tus_uploads_expires_at_idx").on(tusUploads.expiresAt),
+  ],
+);
+
+export const FileScanTable = pgTable(
+  "file_scans",
+  {
+    filePath: text("file_path").primaryKey(),
+    userId: uuid("user_id").references(() => UserTable.id, {
+      onDelete: "set null",
+    }),
+    status: fileUploadStatusEnum("status").default("PENDING").notNull(),
+    signature: text("signature"),
+    attempts: integer("attempts").default(0).notNull(),
+    lastError: text("last_error"),
+    quarantinePath: text("quarantine_path"),
+    scannedAt: timestamp("scanned_at", { mode: "date", withTimezone: true }),
+    createdAt: timestamp("created_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+    updatedAt: timestamp("updated_at", { mode: "date", withTimezone: true })
+      .notNull()
+      .defaultNow(),
+  },
+  (fileScans) => [
+    index("file_scans_status_idx").on(fileScans.status, fileScans.updatedAt),
+  ],
+);
+
+export const TaskFeedSubscriptionTable = pgTable("task_feed_subscriptions", {
+  userId: uuid("user_id")
+    .primaryKey()
+    .references(() => UserTable.id, { onDelete: "cascade" }),
+  categoryIds: uuid("category_ids").array().default([]).notNull(),
+  createdAt: timestamp("created_at", { mode: "date", withTimezone: true })
+    .notNull()
+    .defaultNow(),
+  updatedAt: timestamp("updated_at", { mode: "date", withTimezone: true })
+    .notNull()
+    .defaultNow(),
+});
+
+export const NotificationSettingsTable = pgTable("notification_settings", {
+  userId: uuid("user_id")
+    .primaryKey()
+    .references(() => UserTable.id, { onDelete: "cascade" }),
+  digestFrequency: DigestFrequencyEnum("digest_frequency")
+    .default("DAILY")
+    .notNull(),
+  timezone: text("timezone").default("UTC").notNull(),
+  quietHoursStart: integer("quiet_hours_start"),
+  quietHoursEnd: integer("quiet_hours_end"),
+  lastDigestAt: timestamp("last_digest_at", {
+    mode: "date",
+    withTimezone: true,
+  }),
+  updatedAt: timestamp("updated_at", { mode: "date", withTimezone: true })
+    .notNull()
+    .defaultNow(),
+});
+
 export const logTable = pgTable("logs", {
   id: uuid("id").primaryKey().defaultRandom(),
   createdAt: timestamp("createdAt", {
@@ -30,6 +30,9 @@ export const env = createEnv({
     }),
     GMAIL_APP_EMAIL: z.string({ required_error: "GMAIL_APP_EMAIL is missing" }),
     GO_API_URL: z.string({ required_error: "GO_API_URL is missing" }),
+    SERVICE_API_KEY: z.string({
+      required_error: "SERVICE_API_KEY is missing",
+    }),
     STRIPE_SOLVER_PLUS_PRICE_ID: z.string({
       required_error: "STRIPE_SOLVER_PLUS_PRICE_ID is missing",
     }),
@@ -26,7 +26,6 @@ export default function MentorshipWorkspace({
     mentorshipSession: session,
     setUploadingFiles,
     chats,
-    sentTo,
     setChats,
     send,
     filePreview,
@@ -85,8 +84,6 @@ export default function MentorshipWorkspace({
         extraBody: {
           message: messageInput,
           sessionId: session.id,
-          sentBy: user?.id!,
-          sentTo: sentTo!,
         },
       });
 
@@ -3,7 +3,8 @@ import db from "@/drizzle/db";
 import { notifications } from "@/drizzle/schemas";
 import { createTransporter } from "@/lib/email/createTransporter";
 import { generateSystemEmailTemplate } from "@/lib/email/templates/basicSystem";
-import { goApiClient } from "@/lib/go-api/server";
+import { env } from "@/env/server";
+import { goServiceApiClient } from "@/lib/go-api/server";
 import { logger } from "@/lib/logging/winston";
 import { and, eq } from "drizzle-orm";
 import { revalidatePath } from "next/cache";
@@ -41,9 +42,12 @@ export async function processSystemNotification({
     })
     .returning();
 
-  await goApiClient.request("/send-notification", {
+  // routed as the app rather than as whoever raised it, so it also goes out
+  // from webhooks and jobs without a user session
+  await goServiceApiClient.request("/send-notification", {
     method: "POST",
-    body: JSON.stringify(result[0]),
+    headers: { "X-Service-Key": env.SERVICE_API_KEY },
+    body: JSON.stringify({ id: result[0].id }),
   });
   return;
 }
@@ -84,6 +88,33 @@ export async function sendNotificationByEmail({
   }
 }
 
+// processEmailNotification emails a notification unless the receiver muted
+// general notifications, batches them in the digest, or gets them by email
+// already from the system notification sent along with it
+export async function processEmailNotification(props: emaiProps) {
+  const receiver = await db.query.UserTable.findFirst({
+    where: (table, fn) => fn.eq(table.email, props.receiverEmail),
+    columns: { id: true },
+  });
+  if (receiver) {
+    const preference = await db.query.NotificationPreferenceTable.findFirst({
+      where: (table, fn) =>
+        fn.and(
+          fn.eq(table.userId, receiver.id),
+          fn.eq(table.eventType, "general"),
+        ),
+      columns: { channel: true },
+    });
+    if (preference && preference.channel !== "IN_APP") {
+      logger.info(
+        `Email notification to ${props.receiverEmail} skipped, channel ${preference.channel}`,
+      );
+      return;
+    }
+  }
+  return sendNotificationByEmail(props);
+}
+
 export async function deleteNotification({
   id,
   receiverId,
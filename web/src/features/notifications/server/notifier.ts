@@ -1,4 +1,4 @@
-import { processSystemNotification, sendNotificationByEmail } from "./action";
+import { processEmailNotification, processSystemNotification } from "./action";
 
 export type emailBodyType = { content: string; subject: string };
 export type systemBodyType = { content: string; subject: string };
@@ -29,7 +29,7 @@ export function Notifier(options?: { sender: string }): NotificationBuilder {
       return methods;
     },
     email: ({ receiverEmail, content, subject }) => {
-      void sendNotificationByEmail({
+      void processEmailNotification({
         sender,
         receiverEmail,
         body: { content, subject },
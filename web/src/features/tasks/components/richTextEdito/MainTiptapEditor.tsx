@@ -2,7 +2,11 @@
 import "@/components/tiptap-node/blockquote-node/blockquote-node.scss";
 import "@/components/tiptap-node/paragraph-node/paragraph-node.scss";
 import { EditorUploadedFileType } from "@/features/media/media-types";
-import { useDeleteFileGeneric, useFileUpload } from "@/hooks/useFile";
+import {
+  fetchScannedFileUrl,
+  useDeleteFileGeneric,
+  useFileUpload,
+} from "@/hooks/useFile";
 import { cn } from "@/lib/utils/cn";
 import { Transaction } from "@tiptap/pm/state";
 import { Editor, EditorContent, JSONContent, useEditor } from "@tiptap/react";
@@ -41,7 +45,8 @@ export default function PostingEditor({
       url: "/editor/files",
     });
     console.log(res);
-    return res;
+    // the server hands back the media path, it is shown once scanned
+    return { ...res, url: await fetchScannedFileUrl(res.url) };
   };
   const myMediaCleanupFunction = async (resourceId: string): Promise<void> => {
     await deleteFile({ filePath: resourceId });
@@ -25,7 +25,6 @@ import FileUploadSolver from "@/features/media/components/FileUploadSolver";
 import { useIsMobile } from "@/hooks/use-mobile";
 import useCurrentUser from "@/hooks/useCurrentUser";
 import { getColorClass } from "@/lib/utils/utils";
-import { useMutation } from "@tanstack/react-query";
 import {
   Code2,
   FileText,
@@ -37,7 +36,6 @@ import {
 import { usePathname, useRouter } from "next/navigation";
 import { Ref, useEffect, useRef, useState } from "react";
 import TextareaAutosize from "react-textarea-autosize";
-import { createTaskComment } from "../../server/action";
 
 export default function WorkspaceSidebar({
   open,
@@ -87,18 +85,9 @@ function SideBarForm() {
   const router = useRouter();
   const pathName = usePathname();
   const [comment, setComment] = useState("");
-  const { currentWorkspace, comments, send, setComments } = useWorkspace();
+  const { currentWorkspace, comments, sendComment, isSending } = useWorkspace();
   const { monacoEditor } = useFeatureFlags();
   const latestCommentRef = useRef<HTMLDivElement>(null);
-  const { mutateAsync: createTaskCommentMuta, isPending } = useMutation({
-    mutationFn: createTaskComment,
-    onSuccess: (data) => {
-      if (data) {
-        setComments((prev) => [...prev, data]);
-        send(data);
-      }
-    },
-  });
 
   useEffect(() => {
     if (latestCommentRef.current) {
@@ -110,13 +99,7 @@ function SideBarForm() {
     if (!comment.trim()) return;
 
     setComment("");
-    await createTaskCommentMuta({
-      comment,
-      taskId: currentWorkspace?.taskId!,
-      userId: user?.id!,
-      posterId: currentWorkspace?.task.posterId,
-      solverId: currentWorkspace?.solverId,
-    });
+    sendComment(comment);
   }
 
   const handleKeyPress = (e: React.KeyboardEvent) => {
@@ -183,7 +166,7 @@ function SideBarForm() {
               type="button"
               size="sm"
               onClick={handleSendComment}
-              disabled={!comment.trim() || isPending}
+              disabled={!comment.trim() || isSending}
               className="self-end rounded-xl shadow-sm hover:shadow-md transition-all duration-200"
             >
               <Send className="h-4 w-4" />
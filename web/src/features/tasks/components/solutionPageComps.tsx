@@ -28,7 +28,6 @@ import { CommentCard } from "@/features/tasks/components/richTextEdito/Workspace
 import GetStatusBadge from "@/features/tasks/components/taskStatusBadge";
 import {
   acceptSolution,
-  createTaskComment,
   requestRefund,
   submitFeadback,
 } from "@/features/tasks/server/action";
@@ -219,7 +218,7 @@ export default function SolutionPageComps({
   user: User;
   isFeedbackSumbited: boolean;
 }) {
-  const { comments, setComments, send } = useComments();
+  const { comments, sendComment, isSending } = useComments();
   const [comment, setComment] = useState("");
   const latestCommentRef = useRef<HTMLDivElement>(null);
   const files = solution.solutionFiles.map((f) => {
@@ -246,15 +245,6 @@ export default function SolutionPageComps({
     }
   };
 
-  const { mutateAsync: createTaskCommentMuta, isPending } = useMutation({
-    mutationFn: createTaskComment,
-    onSuccess: (data) => {
-      if (data) {
-        setComments((prev) => [...prev, data]);
-        send(data);
-      }
-    },
-  });
   const { mutateAsync: submitFeadbackMutation, isPending: isSubmiting } =
     useMutation({
       mutationFn: submitFeadback,
@@ -275,13 +265,7 @@ export default function SolutionPageComps({
   async function handleSendComment() {
     if (!comment.trim()) return;
     setComment("");
-    await createTaskCommentMuta({
-      comment,
-      taskId: solution?.taskId,
-      userId: user?.id!,
-      posterId: solution.taskSolution.posterId,
-      solverId: solution.taskSolution.solverId,
-    });
+    sendComment(comment);
   }
 
   return (
@@ -390,7 +374,7 @@ export default function SolutionPageComps({
                   <Button
                     className="self-end"
                     onClick={handleSendComment}
-                    disabled={!comment.trim() || isPending}
+                    disabled={!comment.trim() || isSending}
                   >
                     <Send className="h-4 w-4" />
                   </Button>
@@ -12,7 +12,6 @@ import {
   SolutionFilesTable,
   SolutionTable,
   TaskCategoryTable,
-  TaskCommentTable,
   TaskDeadlineTable,
   TaskDraftTable,
   TaskFileTable,
@@ -931,52 +930,6 @@ export async function requestRefund(values: {
   }
 }
 
-export async function createTaskComment(values: {
-  taskId: string;
-  userId: string;
-  comment: string;
-  posterId?: string;
-  solverId?: string | null;
-}) {
-  try {
-    const { comment, taskId, userId, posterId, solverId } = values;
-    if (!taskId || !userId || !comment || !posterId || !solverId) {
-      throw new Error("all field are required ");
-    }
-    if (!(posterId !== userId || solverId !== userId)) {
-      console.warn(
-        `posterId !== userId || solverId !== userId`,
-        posterId == userId,
-      );
-      throw new UnauthorizedError();
-    }
-
-    const id = await db
-      .insert(TaskCommentTable)
-      .values({
-        content: comment,
-        taskId,
-        userId,
-      })
-      .returning({ id: TaskCommentTable.id });
-    const result = await db.query.TaskCommentTable.findFirst({
-      where: (tb, fn) => fn.eq(tb.id, id[0].id),
-      with: {
-        owner: {
-          columns: publicUserColumns,
-        },
-      },
-    });
-    revalidatePath(`/`);
-    return result;
-  } catch (error) {
-    logger.error("unable to create comment", {
-      message: (error as Error)?.message,
-      stack: (error as Error)?.stack,
-    });
-  }
-}
-
 export async function submitFeadback({
   comment,
   feedbackType,
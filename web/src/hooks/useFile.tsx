@@ -1,6 +1,7 @@
 import { UploadOptions, UploadResponse } from "@/features/media/media-types";
 import { UploadedFileMeta } from "@/features/media/media-types";
 import { goApiClient } from "@/lib/go-api/client";
+import { wait } from "@/lib/utils/common";
 import { Time } from "@/lib/utils/date-time";
 import { useMutation, useQuery } from "@tanstack/react-query";
 import { toast } from "sonner";
@@ -240,6 +241,24 @@ export function useFileStream(key: string | null) {
   });
 }
 
+// fetchScannedFileUrl presigns a freshly uploaded file, the server only does
+// so once its malware scan passed and answers 409 while it runs
+export async function fetchScannedFileUrl(mediaPath: string, attempts = 30) {
+  for (let i = 0; i < attempts; i++) {
+    const response = await goApiClient.request<{
+      url: string;
+      validTime: number;
+    }>(`${mediaPath}?type=presigned`, { method: "GET" }, "json");
+
+    if (response.data) return response.data.url;
+    if (response.status !== 409) {
+      throw new Error(response.error || "Failed to fetch presigned URL");
+    }
+    await wait(2000);
+  }
+  throw new Error("The file is still being scanned");
+}
+
 export function useFileUrl(key: string | null) {
   const {
     data: res,
@@ -2,7 +2,7 @@ import db from "@/drizzle/db";
 import * as schema from "@/drizzle/schemas";
 import { env } from "@/env/server";
 import { getVerificationEmailBody } from "@/features/auth/register/components/emailVerificationMessage";
-import { Notifier } from "@/features/notifications/server/notifier";
+import { sendNotificationByEmail } from "@/features/notifications/server/action";
 import { CreateUserSubsciption } from "@/features/subscriptions/server/action";
 import { Time } from "@/lib/utils/utils";
 import { betterAuth } from "better-auth";
@@ -36,9 +36,12 @@ export const auth = betterAuth({
 
   emailVerification: {
     sendVerificationEmail: async ({ user, token, url }) => {
-      Notifier().email({
-        subject: "Please verify your email address",
-        content: getVerificationEmailBody(url),
+      // not a notification, sent whatever the user's preferences
+      void sendNotificationByEmail({
+        body: {
+          subject: "Please verify your email address",
+          content: getVerificationEmailBody(url),
+        },
         receiverEmail: user.email,
       });
     },
@@ -10,3 +10,10 @@ export const goApiClient = new GoApiClient(async () => {
   });
   return session?.token || null;
 }, env.GO_API_URL);
+
+// goServiceApiClient calls the api as the app rather than as the user of the
+// request, for routes that check the service key instead of a session
+export const goServiceApiClient = new GoApiClient(
+  async () => null,
+  env.GO_API_URL,
+);
@@ -9,7 +9,26 @@ export type signalType =
   | "cancelScreen"
   | "syncScreen"
   | "syncCamera"
-  | "join";
+  | "join"
+  | callEventType;
+
+// the call itself is driven by the server, it answers every one of these
+// with the call row, and with "error" when the call is not in a state for it
+export type callEventType =
+  | "ring"
+  | "accept"
+  | "reject"
+  | "end"
+  | "timeout"
+  | "error";
+
+export type CallRecord = {
+  id: string;
+  session_id: string;
+  caller_id: string;
+  callee_id: string;
+  status: "RINGING" | "ACTIVE" | "REJECTED" | "MISSED" | "ENDED" | "CANCELED";
+};
 
 export type SignalMessage = {
   from: string;
@@ -1,7 +1,12 @@
 import { getTurnCredentials } from "@/lib/cloudflare";
 import { wait } from "@/lib/utils/utils";
 import { SignalHandler, SignalingService } from "@/lib/webrtc/signaling";
-import { connType, SignalMessage } from "@/lib/webrtc/types";
+import {
+  CallRecord,
+  callEventType,
+  connType,
+  SignalMessage,
+} from "@/lib/webrtc/types";
 
 type WebRTCState = {
   localStream: MediaStream | null;
@@ -546,6 +551,9 @@ export class WebRTCManager implements SignalHandler {
   private micOn = true;
   private currentError: WebRTCState["error"] = null;
   private callStarted = false;
+  // the call this participant is in, and the one the peer is ringing them with
+  private callId: string | null = null;
+  private incomingCallId: string | null = null;
 
   constructor({ userId, sessionId }: ManagerOptions) {
     this.userId = userId;
@@ -600,6 +608,10 @@ export class WebRTCManager implements SignalHandler {
   };
 
   public async handle(msg: SignalMessage) {
+    if (isCallEvent(msg.type)) {
+      await this.handleCallEvent(msg);
+      return;
+    }
     if (
       msg.from === this.userId ||
       (msg.to !== this.userId && msg.to !== "broadcast")
@@ -615,6 +627,61 @@ export class WebRTCManager implements SignalHandler {
     }
   }
 
+  // the server sends the call events to both participants, whoever sent them
+  private async handleCallEvent(msg: SignalMessage) {
+    if (msg.type === "error") {
+      this.currentError = {
+        type: "signaling",
+        message: msg.payload?.reason ?? "Signaling error",
+      };
+      this.notify();
+      return;
+    }
+
+    const call = msg.payload as CallRecord;
+    switch (msg.type) {
+      case "ring":
+        if (call.callee_id !== this.userId) {
+          this.callId = call.id;
+          return;
+        }
+        this.incomingCallId = call.id;
+        // both joined before either ring went through
+        if (this.callStarted) await this.sendCallSignal("accept");
+        return;
+      case "accept":
+        this.callId = call.id;
+        this.incomingCallId = null;
+        return;
+      case "reject":
+      case "timeout":
+      case "end":
+        if (this.incomingCallId === call.id) this.incomingCallId = null;
+        if (this.callId !== call.id) return;
+        this.callId = null;
+        if (msg.type !== "end") {
+          this.currentError = {
+            type: "signaling",
+            message:
+              msg.type === "reject" ? "The call was declined" : "No answer",
+          };
+        }
+        await this.closeCall();
+        return;
+    }
+  }
+
+  private async sendCallSignal(type: callEventType) {
+    await this.signaling.send({
+      from: this.userId,
+      to: "broadcast",
+      type,
+      payload: null,
+      sessionId: this.sessionId,
+      connectionType: "camera",
+    });
+  }
+
   public subscribe(cb: Subscriber) {
     this.subscribers.add(cb);
     this.notify();
@@ -673,6 +740,8 @@ export class WebRTCManager implements SignalHandler {
       this.signaling.connect();
     }
     try {
+      // offers are only relayed once the server knows about the call
+      await this.sendCallSignal(this.incomingCallId ? "accept" : "ring");
       await this.cameraWorker.startCameraCall();
       await this.screenWorker.init();
       this.notify();
@@ -724,6 +793,14 @@ export class WebRTCManager implements SignalHandler {
       console.error("Error sending leave signal:", err);
     }
 
+    this.callId = null;
+    await this.closeCall();
+  };
+
+  // closeCall tears down the call on this side only, the server already
+  // ended it or is told by leaveCall
+  private async closeCall() {
+    this.callStarted = false;
     await this.cameraWorker.close();
     await this.screenWorker.close();
     // this.signaling.close();
@@ -734,5 +811,18 @@ export class WebRTCManager implements SignalHandler {
 
     // const key = `${this.userId}_${this.sessionId}`;
     // delete managers[key];
-  };
+  }
+}
+
+const callEvents: callEventType[] = [
+  "ring",
+  "accept",
+  "reject",
+  "end",
+  "timeout",
+  "error",
+];
+
+function isCallEvent(type: SignalMessage["type"]): type is callEventType {
+  return (callEvents as string[]).includes(type);
 }
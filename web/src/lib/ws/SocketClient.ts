@@ -1,3 +1,5 @@
+import { authClient } from "@/lib/auth-client";
+
 export type ConnectionState = "connecting" | "connected" | "disconnected";
 
 export interface SocketClientOptions<MsgType extends object> {
@@ -37,10 +39,21 @@ export class SocketClient<MsgType extends object> {
     if (typeof window === "undefined") return;
 
     this.cleanup();
+    this.setState("connecting");
+    void this.open();
+  };
+
+  private open = async () => {
+    // a fresh token on every attempt, the last one may have expired
+    const url = await withToken(this.url);
+    if (this.isIntentionallyClosing) {
+      // closed while the token was on its way
+      this.isIntentionallyClosing = false;
+      return;
+    }
 
-    const ws = new WebSocket(this.url);
+    const ws = new WebSocket(url);
     this.ws = ws;
-    this.setState("connecting");
 
     ws.onopen = () => {
       this.setState("connected");
@@ -144,3 +157,19 @@ export class SocketClient<MsgType extends object> {
     );
   };
 }
+
+// The go api reads the JWT from the "token" query param on websocket
+// upgrades, browsers can't set an Authorization header on them.
+async function withToken(url: string) {
+  try {
+    const res = await authClient.token();
+    const token = res.data?.token;
+    if (!token) return url;
+    const withAuth = new URL(url);
+    withAuth.searchParams.set("token", token);
+    return withAuth.toString();
+  } catch (error) {
+    console.error("Failed to fetch the websocket token:", error);
+    return url;
+  }
+}